@@ -2,17 +2,71 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/topology"
 	"github.com/nats-io/nats.go/jetstream"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 )
 
+const (
+	// metricsAddr is the address of the HTTP server exposing /metrics.
+	metricsAddr = ":8080"
+	// readHeaderTimeout bounds how long the metrics server waits for request headers.
+	readHeaderTimeout = 5 * time.Second
+	// defaultTopologyFile is the topology applied on startup unless TOPOLOGY_FILE is set.
+	defaultTopologyFile = "config/topology.yaml"
+	// topologyTimeout bounds planning and applying the topology.
+	topologyTimeout = 10 * time.Second
+	// shutdownTimeout bounds draining the clients and stopping the metrics server on shutdown.
+	shutdownTimeout = 30 * time.Second
+	// publishInterval is the time between messages published by the demo publisher.
+	publishInterval = time.Second
+)
+
+// setupMetrics registers client and runtime metrics and serves them on metricsAddr.
+func setupMetrics(logger *zap.Logger, cfg *nats.Config) (*http.Server, error) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}), //nolint: exhaustruct
+	)
+
+	metrics, err := nats.NewMetrics(reg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics: %w", err)
+	}
+
+	cfg.Metrics = metrics
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{})) //nolint: exhaustruct
+
+	srv := &http.Server{ //nolint: exhaustruct
+		Addr:              metricsAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Metrics server failed", zap.Error(err))
+		}
+	}()
+
+	return srv, nil
+}
+
 func setupClients(cfg *nats.Config) (
 	*nats.SimpleNatsClient,
 	*nats.JetStreamClient,
@@ -24,21 +78,28 @@ func setupClients(cfg *nats.Config) (
 		return nil, nil, nil, fmt.Errorf("failed to create simple client: %w", err)
 	}
 
-	jsClient, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
-		Name:     "TEST_JETSREAM",
-		Subjects: []string{"test.jetstream1.>"},
-	})
+	if err := setupTopology(cfg.Logger, simpleClient, false); err != nil {
+		simpleClient.Close(context.Background())
+
+		return nil, nil, nil, err
+	}
+
+	// Streams are declared in the topology file, the clients only bind to them
+	external := nats.WithStreamOwnership(nats.StreamExternal)
+
+	jsClient, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{Name: "TEST_JETSREAM"}, external) //nolint: exhaustruct
 	if err != nil {
 		simpleClient.Close(context.Background())
 
 		return nil, nil, nil, fmt.Errorf("failed to create jetstream client: %w", err)
 	}
 
-	dedupeClient, err := nats.NewDedupJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
-		Name:       "TEST_DEDUPE",
-		Subjects:   []string{"test.dedupe1.>"},
-		Duplicates: time.Minute,
-	})
+	dedupeClient, err := nats.NewDedupJetStreamClient(cfg,
+		jetstream.StreamConfig{Name: "TEST_DEDUPE"}, //nolint: exhaustruct
+		nats.WithJetStreamOptions(external),
+		// The demo publishes raw payloads without an identifier, so only publish retries are deduplicated
+		nats.WithMsgIDFunc(nats.RandomMsgID),
+	)
 	if err != nil {
 		simpleClient.Close(context.Background())
 		jsClient.Close(context.Background())
@@ -49,29 +110,110 @@ func setupClients(cfg *nats.Config) (
 	return simpleClient, jsClient, dedupeClient, nil
 }
 
+// setupTopology applies the topology file using the connection of client.
+// With dryRun the plan is only logged.
+func setupTopology(logger *zap.Logger, client *nats.SimpleNatsClient, dryRun bool) error {
+	path := os.Getenv("TOPOLOGY_FILE")
+	if path == "" {
+		path = defaultTopologyFile
+	}
+
+	topo, err := topology.Load(path)
+	if err != nil {
+		return fmt.Errorf("failed to load topology: %w", err)
+	}
+
+	js, err := client.JetStream()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), topologyTimeout)
+	defer cancel()
+
+	if dryRun {
+		plan, err := topo.Plan(ctx, js)
+		if err != nil {
+			return fmt.Errorf("failed to plan topology: %w", err)
+		}
+
+		logger.Info("Topology plan", zap.String("file", path), zap.Stringer("plan", plan))
+
+		return nil
+	}
+
+	plan, err := topo.Apply(ctx, js)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Topology applied", zap.String("file", path), zap.Stringer("plan", plan))
+
+	return nil
+}
+
+// planTopology logs the topology plan without applying it.
+func planTopology(cfg *nats.Config) error {
+	client, err := nats.NewSimpleNatsClient(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create simple client: %w", err)
+	}
+	defer client.Close(context.Background())
+
+	return setupTopology(cfg.Logger, client, true)
+}
+
 func setupSubscriptions(
 	logger *zap.Logger,
 	simpleClient *nats.SimpleNatsClient,
 ) {
-	if err := simpleClient.Subscribe("simple.events", func(data []byte) {
+	if _, err := simpleClient.Subscribe("simple.events", func(data []byte) {
 		logger.Info("Simple client received message", zap.String("data", string(data)))
 	}); err != nil {
 		logger.Error("Failed to subscribe with simple client", zap.Error(err))
 	}
 }
 
+// publishMessages publishes the current time every publishInterval until ctx is done.
 func publishMessages(
+	ctx context.Context,
 	logger *zap.Logger,
 	simpleClient *nats.SimpleNatsClient,
 ) {
+	ticker := time.NewTicker(publishInterval)
+	defer ticker.Stop()
+
 	for {
-		message := []byte(time.Now().String())
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := simpleClient.PublishToStream(ctx, "simple.events", []byte(now.String())); err != nil &&
+				ctx.Err() == nil {
+				logger.Error("Failed to publish with simple client", zap.Error(err))
+			}
+		}
+	}
+}
+
+// closer is a client released on shutdown.
+type closer interface {
+	Close(ctx context.Context) error
+}
 
-		if err := simpleClient.PublishToStream(context.Background(), "simple.events", message); err != nil {
-			logger.Error("Failed to publish with simple client", zap.Error(err))
+// shutdown closes clients and then the metrics server, all within shutdownTimeout.
+func shutdown(logger *zap.Logger, metricsServer *http.Server, clients ...closer) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	for _, client := range clients {
+		if err := client.Close(ctx); err != nil {
+			logger.Error("Failed to close client", zap.Error(err))
 		}
+	}
 
-		time.Sleep(time.Second)
+	if err := metricsServer.Shutdown(ctx); err != nil {
+		logger.Error("Failed to shut down metrics server", zap.Error(err))
 	}
 }
 
@@ -83,22 +225,40 @@ func main() {
 		}
 	}()
 
+	if os.Getenv("TOPOLOGY_DRY_RUN") == "true" {
+		if err := planTopology(cfg); err != nil {
+			cfg.Logger.Fatal("Failed to plan topology", zap.Error(err))
+		}
+
+		return
+	}
+
+	metricsServer, err := setupMetrics(cfg.Logger, cfg)
+	if err != nil {
+		cfg.Logger.Fatal("Failed to setup metrics", zap.Error(err))
+	}
+
 	simpleClient, jsClient, dedupeClient, err := setupClients(cfg)
 	if err != nil {
 		cfg.Logger.Fatal("Failed to setup clients", zap.Error(err))
 	}
-	defer func() {
-		simpleClient.Close(context.Background())
-		jsClient.Close(context.Background())
-		dedupeClient.Close(context.Background())
-	}()
 
 	setupSubscriptions(cfg.Logger, simpleClient)
 
-	go publishMessages(cfg.Logger, simpleClient)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	<-sigCh
+	published := make(chan struct{})
+
+	go func() {
+		defer close(published)
+		publishMessages(ctx, cfg.Logger, simpleClient)
+	}()
+
+	<-ctx.Done()
 	cfg.Logger.Info("Shutting down...")
+
+	// Stop publishing before the clients drain
+	<-published
+	shutdown(cfg.Logger, metricsServer, simpleClient, jsClient, dedupeClient)
 }
@@ -0,0 +1,341 @@
+package eventprocessor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCloudEvent is returned when a message is not a valid CloudEvents 1.0 event.
+var ErrInvalidCloudEvent = errors.New("invalid CloudEvent")
+
+// CloudEvents attributes used by the protocol bindings.
+const (
+	// CloudEventsSpecVersion is the supported CloudEvents specification version.
+	CloudEventsSpecVersion = "1.0"
+	// CloudEventsContentType is the content type of structured mode messages.
+	CloudEventsContentType = "application/cloudevents+json"
+
+	cloudEventsHeaderPrefix = "ce-"
+	// schemaVersionExtension carries Event.SchemaVersion as a CloudEvents extension attribute.
+	schemaVersionExtension = "schemaversion"
+)
+
+// cloudEventsAttributes are the attributes and structured mode members mapped onto Event fields.
+// All others are kept in Event.Extensions.
+var cloudEventsAttributes = map[string]bool{
+	"specversion":          true,
+	"id":                   true,
+	"source":               true,
+	"type":                 true,
+	"time":                 true,
+	"datacontenttype":      true,
+	schemaVersionExtension: true,
+	"data":                 true,
+	"data_base64":          true,
+}
+
+// CloudEventsMode selects how an Event is encoded as a CloudEvent.
+type CloudEventsMode int
+
+const (
+	// CloudEventsBinary carries attributes in ce-* headers and the payload as the message body.
+	CloudEventsBinary CloudEventsMode = iota
+	// CloudEventsStructured carries attributes and payload together in a JSON body.
+	CloudEventsStructured
+)
+
+// structuredCloudEvent is the JSON format of a structured mode CloudEvent.
+type structuredCloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            string          `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	SchemaVersion   string          `json:"schemaversion,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      []byte          `json:"data_base64,omitempty"`
+}
+
+// EncodeCloudEvent encodes event as a CloudEvent in mode, returning the message headers and body.
+// Event.Headers are sent as plain message headers in both modes and Event.Extensions as extension attributes.
+func EncodeCloudEvent(event Event, mode CloudEventsMode) (Header, []byte, error) { //nolint: funlen
+	if err := event.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidCloudEvent, err)
+	}
+
+	for name := range event.Extensions {
+		if err := validateExtensionName(name); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	header := Header{}
+	for key, value := range event.Headers {
+		header.Set(key, value)
+	}
+
+	switch mode {
+	case CloudEventsBinary:
+		header.Set(cloudEventsHeaderPrefix+"specversion", CloudEventsSpecVersion)
+		header.Set(cloudEventsHeaderPrefix+"id", event.ID)
+		header.Set(cloudEventsHeaderPrefix+"source", event.Source)
+		header.Set(cloudEventsHeaderPrefix+"type", event.Type)
+
+		if !event.Time.IsZero() {
+			header.Set(cloudEventsHeaderPrefix+"time", event.Time.Format(time.RFC3339Nano))
+		}
+
+		if event.SchemaVersion != "" {
+			header.Set(cloudEventsHeaderPrefix+schemaVersionExtension, event.SchemaVersion)
+		}
+
+		for name, value := range event.Extensions {
+			header.Set(cloudEventsHeaderPrefix+name, value)
+		}
+
+		if event.ContentType != "" {
+			header.Set(EventContentTypeHeader, event.ContentType)
+		}
+
+		return header, event.Data, nil
+	case CloudEventsStructured:
+		body := structuredCloudEvent{
+			SpecVersion:     CloudEventsSpecVersion,
+			ID:              event.ID,
+			Source:          event.Source,
+			Type:            event.Type,
+			Time:            "",
+			DataContentType: event.ContentType,
+			SchemaVersion:   event.SchemaVersion,
+			Data:            nil,
+			DataBase64:      nil,
+		}
+
+		if !event.Time.IsZero() {
+			body.Time = event.Time.Format(time.RFC3339Nano)
+		}
+
+		if isJSONContentType(event.ContentType) && json.Valid(event.Data) {
+			body.Data = event.Data
+		} else if len(event.Data) > 0 {
+			body.DataBase64 = event.Data
+		}
+
+		data, err := body.encode(event.Extensions)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		header.Set(EventContentTypeHeader, CloudEventsContentType)
+
+		return header, data, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown CloudEvents mode %d: %w", mode, ErrInvalidCloudEvent)
+	}
+}
+
+// DecodeCloudEvent decodes a binary or structured mode CloudEvent from the headers and body of a message,
+// detecting the mode from the content type.
+// Returns an error wrapping ErrInvalidCloudEvent if required attributes are missing.
+func DecodeCloudEvent(header Header, data []byte) (Event, error) {
+	attrs := make(map[string]string, len(header))
+	headers := make(map[string]string, len(header))
+
+	var contentType string
+
+	for key := range header {
+		switch lower := strings.ToLower(key); {
+		case strings.HasPrefix(lower, cloudEventsHeaderPrefix):
+			attrs[strings.TrimPrefix(lower, cloudEventsHeaderPrefix)] = header.Get(key)
+		case strings.EqualFold(key, EventContentTypeHeader):
+			contentType = header.Get(key)
+		default:
+			headers[key] = header.Get(key)
+		}
+	}
+
+	if len(headers) == 0 {
+		headers = nil
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == CloudEventsContentType {
+		return structuredCloudEvent{}.decode(data, headers) //nolint: exhaustruct
+	}
+
+	event := Event{
+		ID:            attrs["id"],
+		Type:          attrs["type"],
+		Source:        attrs["source"],
+		Time:          time.Time{},
+		ContentType:   contentType,
+		SchemaVersion: attrs[schemaVersionExtension],
+		Headers:       headers,
+		Extensions:    nil,
+		Data:          data,
+	}
+
+	for name, value := range attrs {
+		if !cloudEventsAttributes[name] {
+			event.Extensions = setExtension(event.Extensions, name, value)
+		}
+	}
+
+	return validateCloudEvent(event, attrs["specversion"], attrs["time"])
+}
+
+// encode marshals the structured mode body with extensions as additional top-level members.
+func (s structuredCloudEvent) encode(extensions map[string]string) ([]byte, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode CloudEvent: %w", err)
+	}
+
+	if len(extensions) == 0 {
+		return data, nil
+	}
+
+	// Members are kept as raw JSON so the data member is copied verbatim
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, fmt.Errorf("failed to encode CloudEvent: %w", err)
+	}
+
+	for name, value := range extensions {
+		if members[name], err = json.Marshal(value); err != nil {
+			return nil, fmt.Errorf("failed to encode CloudEvent: %w", err)
+		}
+	}
+
+	data, err = json.Marshal(members)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode CloudEvent: %w", err)
+	}
+
+	return data, nil
+}
+
+// decode parses a structured mode body into an Event with headers.
+// Extension members that are not strings keep their JSON text, such as 42 or true.
+func (s structuredCloudEvent) decode(data []byte, headers map[string]string) (Event, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return Event{}, fmt.Errorf("failed to decode CloudEvent: %w: %w", ErrInvalidCloudEvent, err)
+	}
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Event{}, fmt.Errorf("failed to decode CloudEvent: %w: %w", ErrInvalidCloudEvent, err)
+	}
+
+	event := Event{
+		ID:            s.ID,
+		Type:          s.Type,
+		Source:        s.Source,
+		Time:          time.Time{},
+		ContentType:   s.DataContentType,
+		SchemaVersion: s.SchemaVersion,
+		Headers:       headers,
+		Extensions:    nil,
+		Data:          s.DataBase64,
+	}
+
+	for name, raw := range members {
+		if cloudEventsAttributes[name] {
+			continue
+		}
+
+		value := string(raw)
+
+		var text string
+		if json.Unmarshal(raw, &text) == nil {
+			value = text
+		}
+
+		event.Extensions = setExtension(event.Extensions, name, value)
+	}
+
+	if len(s.Data) > 0 {
+		event.Data = s.Data
+
+		// Non-JSON payloads such as text are carried as JSON strings
+		var text string
+		if !isJSONContentType(s.DataContentType) && json.Unmarshal(s.Data, &text) == nil {
+			event.Data = []byte(text)
+		}
+	}
+
+	return validateCloudEvent(event, s.SpecVersion, s.Time)
+}
+
+// validateCloudEvent checks the spec version and required attributes and parses the time attribute.
+func validateCloudEvent(event Event, specVersion, rawTime string) (Event, error) {
+	if specVersion != CloudEventsSpecVersion {
+		return Event{}, fmt.Errorf("unsupported specversion %q: %w", specVersion, ErrInvalidCloudEvent)
+	}
+
+	if err := event.Validate(); err != nil {
+		return Event{}, fmt.Errorf("%w: %w", ErrInvalidCloudEvent, err)
+	}
+
+	if rawTime != "" {
+		t, err := time.Parse(time.RFC3339Nano, rawTime)
+		if err != nil {
+			return Event{}, fmt.Errorf("invalid time %q: %w", rawTime, ErrInvalidCloudEvent)
+		}
+
+		event.Time = t
+	}
+
+	return event, nil
+}
+
+// validateExtensionName checks that name is a valid CloudEvents extension attribute name:
+// lowercase letters and digits, and not an attribute mapped onto an Event field.
+func validateExtensionName(name string) error {
+	if name == "" || cloudEventsAttributes[name] {
+		return fmt.Errorf("reserved extension name %q: %w", name, ErrInvalidCloudEvent)
+	}
+
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return fmt.Errorf("invalid extension name %q: %w", name, ErrInvalidCloudEvent)
+		}
+	}
+
+	return nil
+}
+
+// setExtension sets the extension name to value, allocating extensions when nil.
+func setExtension(extensions map[string]string, name, value string) map[string]string {
+	if extensions == nil {
+		extensions = make(map[string]string)
+	}
+
+	extensions[name] = value
+
+	return extensions
+}
+
+// isJSONContentType reports whether contentType is JSON, which is implied when it is empty.
+func isJSONContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// CloudEvent decodes the binary or structured mode CloudEvent carried by the message.
+// Returns an error wrapping ErrInvalidCloudEvent if required attributes are missing.
+func (m *Message) CloudEvent() (Event, error) {
+	return DecodeCloudEvent(m.Headers, m.Data)
+}
@@ -0,0 +1,123 @@
+package eventprocessor_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloudEventRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	event := eventprocessor.Event{
+		ID:            "evt-1",
+		Type:          "orders.created",
+		Source:        "/orders",
+		Time:          time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		ContentType:   "application/json",
+		SchemaVersion: "2",
+		Headers:       map[string]string{"Tenant": "acme"},
+		Extensions:    map[string]string{"traceparent": "00-abc-def-01", "partitionkey": "o-1", "subject": "o-1"},
+		Data:          []byte(`{"id":"o-1","total":12345678901234567890}`),
+	}
+
+	tests := []struct {
+		name string
+		mode eventprocessor.CloudEventsMode
+	}{
+		{name: "binary", mode: eventprocessor.CloudEventsBinary},
+		{name: "structured", mode: eventprocessor.CloudEventsStructured},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			header, data, err := eventprocessor.EncodeCloudEvent(event, tt.mode)
+			require.NoError(t, err)
+
+			// The content type uses the same header as plain events
+			assert.NotEmpty(t, header.Get(eventprocessor.EventContentTypeHeader))
+			assert.Empty(t, header.Values("content-type"))
+
+			got, err := eventprocessor.DecodeCloudEvent(header, data)
+			require.NoError(t, err)
+			assert.True(t, event.Time.Equal(got.Time))
+
+			got.Time = event.Time
+			assert.Equal(t, event, got)
+		})
+	}
+}
+
+func TestCloudEventExtensions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("InvalidNames", func(t *testing.T) {
+		t.Parallel()
+
+		for _, name := range []string{"", "id", "schemaversion", "data", "Tenant", "trace-id"} {
+			event := eventprocessor.NewEvent("orders.created", "/orders", nil)
+			event.Extensions = map[string]string{name: "value"}
+
+			for _, mode := range []eventprocessor.CloudEventsMode{
+				eventprocessor.CloudEventsBinary,
+				eventprocessor.CloudEventsStructured,
+			} {
+				_, _, err := eventprocessor.EncodeCloudEvent(event, mode)
+				assert.ErrorIs(t, err, eventprocessor.ErrInvalidCloudEvent, name)
+			}
+		}
+	})
+
+	t.Run("Foreign", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			name   string
+			header eventprocessor.Header
+			data   string
+			want   map[string]string
+		}{
+			{
+				name: "structured with typed members",
+				header: eventprocessor.Header{
+					"content-type": []string{eventprocessor.CloudEventsContentType},
+				},
+				data: `{"specversion":"1.0","id":"1","source":"/x","type":"t",` +
+					`"dataschema":"https://example.com/t.json","sequence":42,"sampled":true}`,
+				want: map[string]string{"dataschema": "https://example.com/t.json", "sequence": "42", "sampled": "true"},
+			},
+			{
+				name: "binary with mixed case headers",
+				header: eventprocessor.Header{
+					"Ce-Specversion": []string{"1.0"},
+					"Ce-Id":          []string{"2"},
+					"Ce-Source":      []string{"/x"},
+					"Ce-Type":        []string{"t"},
+					"Ce-Tenant":      []string{"acme"},
+				},
+				data: "payload",
+				want: map[string]string{"tenant": "acme"},
+			},
+			{
+				name: "without extensions",
+				header: eventprocessor.Header{
+					"ce-specversion": []string{"1.0"},
+					"ce-id":          []string{"3"},
+					"ce-source":      []string{"/x"},
+					"ce-type":        []string{"t"},
+				},
+				data: "payload",
+				want: nil,
+			},
+		}
+		for _, tt := range tests {
+			got, err := eventprocessor.DecodeCloudEvent(tt.header, []byte(tt.data))
+			require.NoError(t, err, tt.name)
+			assert.Equal(t, tt.want, got.Extensions, tt.name)
+		}
+	})
+}
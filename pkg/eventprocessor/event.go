@@ -0,0 +1,166 @@
+package eventprocessor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidEvent is returned when an event is missing required attributes.
+var ErrInvalidEvent = errors.New("invalid event")
+
+// eventIDBytes is the number of random bytes in IDs generated by NewEvent.
+const eventIDBytes = 16
+
+// Event is a message envelope carrying metadata alongside the payload.
+// Brokers map its attributes onto message headers so they survive end to end.
+type Event struct {
+	// ID uniquely identifies the event, it is also used as the deduplication ID where supported
+	ID string
+	// Type describes what happened, for example "orders.created"
+	Type string
+	// Source identifies the producer of the event
+	Source string
+	// Time is when the event occurred, zero when unknown
+	Time time.Time
+	// ContentType is the media type of Data, for example "application/json"
+	ContentType string
+	// SchemaVersion is the version of the schema Data conforms to
+	SchemaVersion string
+	// Headers are additional application headers
+	Headers map[string]string
+	// Extensions are CloudEvents extension attributes by lowercase name, carried only as a CloudEvent
+	Extensions map[string]string
+	// Data is the event payload
+	Data []byte
+}
+
+// NewEvent creates an event with a random ID and the current time.
+func NewEvent(eventType, source string, data []byte) Event {
+	id := make([]byte, eventIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		panic("failed to generate event ID: " + err.Error())
+	}
+
+	return Event{
+		ID:            hex.EncodeToString(id),
+		Type:          eventType,
+		Source:        source,
+		Time:          time.Now().UTC(),
+		ContentType:   "",
+		SchemaVersion: "",
+		Headers:       nil,
+		Extensions:    nil,
+		Data:          data,
+	}
+}
+
+// Validate checks that the event has an ID, type and source.
+func (e Event) Validate() error {
+	switch {
+	case e.ID == "":
+		return fmt.Errorf("missing ID: %w", ErrInvalidEvent)
+	case e.Type == "":
+		return fmt.Errorf("missing type: %w", ErrInvalidEvent)
+	case e.Source == "":
+		return fmt.Errorf("missing source: %w", ErrInvalidEvent)
+	}
+
+	return nil
+}
+
+// EventPublisher publishes events with their envelope attributes.
+type EventPublisher interface {
+	// PublishEvent publishes event to topic.
+	// Returns an error if the event is invalid or the publish operation fails
+	PublishEvent(ctx context.Context, topic string, event Event) error
+}
+
+// Headers carrying the Event envelope attributes.
+const (
+	EventIDHeader            = "Event-Id"
+	EventTypeHeader          = "Event-Type"
+	EventSourceHeader        = "Event-Source"
+	EventTimeHeader          = "Event-Time"
+	EventContentTypeHeader   = "Content-Type"
+	EventSchemaVersionHeader = "Event-Schema-Version"
+)
+
+// Header maps the event onto message headers: its application headers and its attributes.
+// Application headers never override the envelope headers.
+func (e Event) Header() Header {
+	header := Header{}
+	for key, value := range e.Headers {
+		header.Set(key, value)
+	}
+
+	header.Set(EventIDHeader, e.ID)
+	header.Set(EventTypeHeader, e.Type)
+	header.Set(EventSourceHeader, e.Source)
+
+	if !e.Time.IsZero() {
+		header.Set(EventTimeHeader, e.Time.Format(time.RFC3339Nano))
+	}
+
+	if e.ContentType != "" {
+		header.Set(EventContentTypeHeader, e.ContentType)
+	}
+
+	if e.SchemaVersion != "" {
+		header.Set(EventSchemaVersionHeader, e.SchemaVersion)
+	}
+
+	return header
+}
+
+// DecodeEvent reads the event envelope from the headers and payload of a message.
+// Headers other than the envelope headers are returned in Event.Headers.
+// Returns an error wrapping ErrInvalidEvent if the message was not published as an event.
+func DecodeEvent(header Header, data []byte) (Event, error) {
+	event := Event{ //nolint: exhaustruct
+		ID:            header.Get(EventIDHeader),
+		Type:          header.Get(EventTypeHeader),
+		Source:        header.Get(EventSourceHeader),
+		ContentType:   header.Get(EventContentTypeHeader),
+		SchemaVersion: header.Get(EventSchemaVersionHeader),
+		Data:          data,
+	}
+
+	if raw := header.Get(EventTimeHeader); raw != "" {
+		t, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return Event{}, fmt.Errorf("failed to parse event time: %w", err)
+		}
+
+		event.Time = t
+	}
+
+	for key := range header {
+		switch key {
+		case EventIDHeader, EventTypeHeader, EventSourceHeader, EventTimeHeader,
+			EventContentTypeHeader, EventSchemaVersionHeader:
+			continue
+		}
+
+		if event.Headers == nil {
+			event.Headers = make(map[string]string, len(header))
+		}
+
+		event.Headers[key] = header.Get(key)
+	}
+
+	if err := event.Validate(); err != nil {
+		return Event{}, err
+	}
+
+	return event, nil
+}
+
+// Event decodes the event envelope carried by the message.
+// Returns an error wrapping ErrInvalidEvent if the message was not published as an event.
+func (m *Message) Event() (Event, error) {
+	return DecodeEvent(m.Headers, m.Data)
+}
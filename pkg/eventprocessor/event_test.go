@@ -0,0 +1,49 @@
+package eventprocessor_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEvent(t *testing.T) {
+	t.Parallel()
+
+	first := eventprocessor.NewEvent("orders.created", "orders-service", []byte("{}"))
+	second := eventprocessor.NewEvent("orders.created", "orders-service", []byte("{}"))
+
+	assert.Len(t, first.ID, 32)
+	assert.NotEqual(t, first.ID, second.ID)
+	assert.WithinDuration(t, time.Now(), first.Time, time.Second)
+	assert.NoError(t, first.Validate())
+}
+
+func TestEventValidate(t *testing.T) {
+	t.Parallel()
+
+	valid := eventprocessor.NewEvent("orders.created", "orders-service", nil)
+
+	tests := []struct {
+		name  string
+		edit  func(*eventprocessor.Event)
+		valid bool
+	}{
+		{name: "valid", edit: func(*eventprocessor.Event) {}, valid: true},
+		{name: "missing ID", edit: func(e *eventprocessor.Event) { e.ID = "" }, valid: false},
+		{name: "missing type", edit: func(e *eventprocessor.Event) { e.Type = "" }, valid: false},
+		{name: "missing source", edit: func(e *eventprocessor.Event) { e.Source = "" }, valid: false},
+		{name: "zero time", edit: func(e *eventprocessor.Event) { e.Time = time.Time{} }, valid: true},
+	}
+	for _, tt := range tests {
+		event := valid
+		tt.edit(&event)
+
+		if tt.valid {
+			assert.NoError(t, event.Validate(), tt.name)
+		} else {
+			assert.ErrorIs(t, event.Validate(), eventprocessor.ErrInvalidEvent, tt.name)
+		}
+	}
+}
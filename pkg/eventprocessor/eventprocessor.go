@@ -0,0 +1,28 @@
+// Package eventprocessor defines the broker-agnostic interface shared by all event processor implementations.
+package eventprocessor
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrShutdownIncomplete is returned by Close when in-flight work did not finish within the context deadline.
+var ErrShutdownIncomplete = errors.New("shutdown incomplete")
+
+// EventProcessor defines the interface for different event processing strategies.
+// It provides methods for publishing messages to streams and managing connections.
+type EventProcessor interface {
+	// PublishToStream publishes a message to a stream.
+	// ctx provides context for the operation
+	// topic is the stream or subject to publish to
+	// data is the message payload to publish
+	// Returns an error if the publish operation fails
+	PublishToStream(ctx context.Context, topic string, data []byte) error
+
+	// Close gracefully shuts down the event processor and its connections.
+	// It stops receiving new messages, waits for in-flight handlers and pending publishes to finish,
+	// and releases the connections.
+	// ctx bounds the shutdown operation
+	// Returns an error wrapping ErrShutdownIncomplete when work was left unfinished at the deadline
+	Close(ctx context.Context) error
+}
@@ -0,0 +1,238 @@
+package eventprocessortest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// redeliveryWait is the ack wait and nak delay used by the suite, short enough to observe redeliveries.
+const redeliveryWait = 200 * time.Millisecond
+
+// ConsumeContext controls a running consumer.
+type ConsumeContext interface {
+	// Stop stops delivering messages to the consumer handler.
+	Stop()
+}
+
+// Streaming is an EventProcessor with durable consumers, such as the JetStream clients or the memory broker.
+// O is the consumer option type and C the consumer handle of the implementation.
+type Streaming[O any, C ConsumeContext] interface {
+	eventprocessor.EventProcessor
+	// CreateConsumer starts a durable consumer delivering stored messages to handler.
+	CreateConsumer(ctx context.Context, name string, handler eventprocessor.Handler, opts ...O) (C, error)
+}
+
+// StreamingFactory creates a ready Streaming for a single test storing messages published to subjects
+// matching pattern.
+type StreamingFactory[O any, C ConsumeContext] func(t *testing.T, pattern string) Streaming[O, C]
+
+// RunConsumers runs the durable consumer suite against implementations created by factory:
+// ack, nak redelivery, term, replay and resuming a durable consumer.
+// prefix namespaces the subjects used by the suite and ackWait returns the option setting the consumer ack wait.
+func RunConsumers[O any, C ConsumeContext]( //nolint: funlen
+	t *testing.T,
+	prefix string,
+	factory StreamingFactory[O, C],
+	ackWait func(time.Duration) O,
+) {
+	t.Helper()
+
+	t.Run("AckAndResume", func(t *testing.T) {
+		t.Parallel()
+		s, subject := open(t, factory, prefix+".ack")
+		publish(t, s, subject, "1", "2", "3")
+
+		got := &deliveries{mu: sync.Mutex{}, msgs: nil}
+		cc := consume(t, s, "acker", got.ack, ackWait(redeliveryWait))
+		got.waitFor(t, 3)
+		cc.Stop()
+		assert.Equal(t, []string{"1", "2", "3"}, got.data())
+
+		// A restarted durable consumer resumes after its acked messages
+		publish(t, s, subject, "4")
+
+		resumed := &deliveries{mu: sync.Mutex{}, msgs: nil}
+		consume(t, s, "acker", resumed.ack, ackWait(redeliveryWait))
+		resumed.waitFor(t, 1)
+		time.Sleep(quietPeriod)
+		assert.Equal(t, []string{"4"}, resumed.data())
+	})
+
+	t.Run("NakRedelivers", func(t *testing.T) {
+		t.Parallel()
+		s, subject := open(t, factory, prefix+".nak")
+		publish(t, s, subject, "retry")
+
+		got := &deliveries{mu: sync.Mutex{}, msgs: nil}
+		consume(t, s, "naker", func(ctx context.Context, msg *eventprocessor.Message) eventprocessor.Result {
+			got.ack(ctx, msg)
+
+			if msg.Metadata.NumDelivered == 1 {
+				return eventprocessor.Nak(redeliveryWait, errors.New("not yet"))
+			}
+
+			return eventprocessor.Ack()
+		})
+		got.waitFor(t, 2)
+		time.Sleep(quietPeriod)
+		assert.Equal(t, []uint64{1, 2}, got.numDelivered())
+	})
+
+	t.Run("TermStopsRedelivery", func(t *testing.T) {
+		t.Parallel()
+		s, subject := open(t, factory, prefix+".term")
+		publish(t, s, subject, "poison", "next")
+
+		got := &deliveries{mu: sync.Mutex{}, msgs: nil}
+		consume(t, s, "termer", func(ctx context.Context, msg *eventprocessor.Message) eventprocessor.Result {
+			got.ack(ctx, msg)
+
+			if string(msg.Data) == "poison" {
+				return eventprocessor.Term(errors.New("poison"))
+			}
+
+			return eventprocessor.Ack()
+		}, ackWait(redeliveryWait))
+		got.waitFor(t, 2)
+		time.Sleep(2 * redeliveryWait)
+		assert.Equal(t, []string{"poison", "next"}, got.data())
+	})
+
+	t.Run("NewConsumerReplays", func(t *testing.T) {
+		t.Parallel()
+		s, subject := open(t, factory, prefix+".replay")
+		publish(t, s, subject, "1", "2")
+
+		first := &deliveries{mu: sync.Mutex{}, msgs: nil}
+		consume(t, s, "first", first.ack)
+		first.waitFor(t, 2)
+
+		// Acks of one consumer do not affect another, which replays the stored messages
+		second := &deliveries{mu: sync.Mutex{}, msgs: nil}
+		consume(t, s, "second", second.ack)
+		second.waitFor(t, 2)
+		assert.Equal(t, []string{"1", "2"}, second.data())
+		assert.Equal(t, []uint64{1, 2}, second.streamSequences())
+	})
+}
+
+// open creates a Streaming for the subjects below base, returning it with the subject to publish to.
+func open[O any, C ConsumeContext](
+	t *testing.T,
+	factory StreamingFactory[O, C],
+	base string,
+) (Streaming[O, C], string) {
+	t.Helper()
+
+	s := factory(t, base+".>")
+	t.Cleanup(func() { _ = s.Close(context.Background()) })
+
+	return s, base + ".events"
+}
+
+// publish publishes values to subject in order.
+func publish(t *testing.T, s eventprocessor.EventProcessor, subject string, values ...string) {
+	t.Helper()
+
+	for _, v := range values {
+		require.NoError(t, s.PublishToStream(context.Background(), subject, []byte(v)))
+	}
+}
+
+// consume starts a consumer stopped when the test ends.
+func consume[O any, C ConsumeContext](
+	t *testing.T,
+	s Streaming[O, C],
+	name string,
+	handler eventprocessor.Handler,
+	opts ...O,
+) C {
+	t.Helper()
+
+	cc, err := s.CreateConsumer(context.Background(), name, handler, opts...)
+	require.NoError(t, err)
+	t.Cleanup(cc.Stop)
+
+	return cc
+}
+
+// delivery is a message seen by a consumer handler.
+type delivery struct {
+	data         string
+	streamSeq    uint64
+	numDelivered uint64
+}
+
+// deliveries records the messages handled by a consumer.
+type deliveries struct {
+	mu   sync.Mutex
+	msgs []delivery
+}
+
+// ack is a Handler recording and acknowledging every message.
+func (d *deliveries) ack(_ context.Context, msg *eventprocessor.Message) eventprocessor.Result {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.msgs = append(d.msgs, delivery{
+		data:         string(msg.Data),
+		streamSeq:    msg.Metadata.Sequence.Stream,
+		numDelivered: msg.Metadata.NumDelivered,
+	})
+
+	return eventprocessor.Ack()
+}
+
+// snapshot returns a copy of the recorded deliveries.
+func (d *deliveries) snapshot() []delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]delivery(nil), d.msgs...)
+}
+
+// data returns the payloads of the recorded deliveries.
+func (d *deliveries) data() []string {
+	var out []string
+	for _, m := range d.snapshot() {
+		out = append(out, m.data)
+	}
+
+	return out
+}
+
+// streamSequences returns the stream sequences of the recorded deliveries.
+func (d *deliveries) streamSequences() []uint64 {
+	var out []uint64
+	for _, m := range d.snapshot() {
+		out = append(out, m.streamSeq)
+	}
+
+	return out
+}
+
+// numDelivered returns the delivery counts of the recorded deliveries.
+func (d *deliveries) numDelivered() []uint64 {
+	var out []uint64
+	for _, m := range d.snapshot() {
+		out = append(out, m.numDelivered)
+	}
+
+	return out
+}
+
+// waitFor waits until at least n deliveries were recorded.
+func (d *deliveries) waitFor(t *testing.T, n int) {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		return len(d.snapshot()) >= n
+	}, waitTimeout, 10*time.Millisecond, "expected %d deliveries", n)
+}
@@ -0,0 +1,141 @@
+// Package eventprocessortest provides a behavioral test suite shared by all EventProcessor implementations.
+package eventprocessortest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// waitTimeout bounds how long the suite waits for asynchronous deliveries.
+	waitTimeout = 5 * time.Second
+	// quietPeriod is how long the suite waits to assert that nothing was delivered.
+	quietPeriod = 200 * time.Millisecond
+)
+
+// PubSub is an EventProcessor with core publish/subscribe support.
+type PubSub interface {
+	eventprocessor.EventProcessor
+	// Subscribe registers handler for messages on subjects matching pattern.
+	Subscribe(pattern string, handler func([]byte)) error
+}
+
+// Factory creates a ready PubSub for a single test.
+// Subjects used by the suite are prefixed with prefix so tests can share a server.
+type Factory func(t *testing.T) PubSub
+
+// Run runs the behavioral suite against implementations created by factory.
+// prefix namespaces the subjects used by the suite.
+func Run(t *testing.T, prefix string, factory Factory) {
+	t.Helper()
+
+	t.Run("PublishSubscribe", func(t *testing.T) {
+		t.Parallel()
+		ps := factory(t)
+		defer ps.Close(context.Background())
+
+		got := collect(t, ps, prefix+".basic")
+		require.NoError(t, ps.PublishToStream(context.Background(), prefix+".basic", []byte("hello")))
+
+		got.waitFor(t, 1)
+		assert.Equal(t, []string{"hello"}, got.values())
+	})
+
+	t.Run("Wildcards", func(t *testing.T) {
+		t.Parallel()
+		ps := factory(t)
+		defer ps.Close(context.Background())
+
+		single := collect(t, ps, prefix+".wild.*")
+		full := collect(t, ps, prefix+".wild.>")
+
+		ctx := context.Background()
+		require.NoError(t, ps.PublishToStream(ctx, prefix+".wild.a", []byte("a")))
+		require.NoError(t, ps.PublishToStream(ctx, prefix+".wild.a.b", []byte("a.b")))
+
+		full.waitFor(t, 2)
+		single.waitFor(t, 1)
+		assert.Equal(t, []string{"a", "a.b"}, full.values())
+
+		time.Sleep(quietPeriod)
+		assert.Equal(t, []string{"a"}, single.values())
+	})
+
+	t.Run("OrderPreserved", func(t *testing.T) {
+		t.Parallel()
+		ps := factory(t)
+		defer ps.Close(context.Background())
+
+		got := collect(t, ps, prefix+".order")
+		want := []string{"1", "2", "3", "4", "5"}
+
+		for _, v := range want {
+			require.NoError(t, ps.PublishToStream(context.Background(), prefix+".order", []byte(v)))
+		}
+
+		got.waitFor(t, len(want))
+		assert.Equal(t, want, got.values())
+	})
+
+	t.Run("CanceledContext", func(t *testing.T) {
+		t.Parallel()
+		ps := factory(t)
+		defer ps.Close(context.Background())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		assert.Error(t, ps.PublishToStream(ctx, prefix+".canceled", []byte("data")))
+	})
+
+	t.Run("PublishAfterClose", func(t *testing.T) {
+		t.Parallel()
+		ps := factory(t)
+		require.NoError(t, ps.Close(context.Background()))
+
+		assert.Error(t, ps.PublishToStream(context.Background(), prefix+".closed", []byte("data")))
+	})
+}
+
+// collector records payloads received by a subscription.
+type collector struct {
+	mu   sync.Mutex
+	data []string
+}
+
+// collect subscribes to pattern and records every payload.
+func collect(t *testing.T, ps PubSub, pattern string) *collector {
+	t.Helper()
+
+	c := &collector{mu: sync.Mutex{}, data: nil}
+	require.NoError(t, ps.Subscribe(pattern, func(data []byte) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.data = append(c.data, string(data))
+	}))
+
+	return c
+}
+
+// values returns a copy of the received payloads.
+func (c *collector) values() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.data...)
+}
+
+// waitFor waits until at least n payloads were received.
+func (c *collector) waitFor(t *testing.T, n int) {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		return len(c.values()) >= n
+	}, waitTimeout, 10*time.Millisecond, "expected %d messages", n)
+}
@@ -0,0 +1,76 @@
+// Package kafka implements the EventProcessor interface on top of Apache Kafka.
+package kafka
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+)
+
+// Compile-time check that Client implements EventProcessor.
+var _ eventprocessor.EventProcessor = (*Client)(nil)
+
+// Client implements the EventProcessor interface with a Kafka producer.
+type Client struct {
+	client *kgo.Client
+	config *Config
+	logger *zap.Logger
+}
+
+// NewClient creates a new Kafka producer client with the provided configuration.
+// Returns an error if the brokers cannot be reached.
+func NewClient(cfg *Config) (*Client, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	kc, err := kgo.NewClient(
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.ClientID(cfg.clientID()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka client: %w", err)
+	}
+
+	if err := kc.Ping(context.Background()); err != nil {
+		kc.Close()
+
+		return nil, fmt.Errorf("failed to connect to Kafka: %w", err)
+	}
+
+	return &Client{client: kc, config: cfg, logger: cfg.Logger}, nil
+}
+
+// PublishToStream implements the EventProcessor interface.
+// It synchronously produces a record to the given topic and waits for the broker acknowledgement.
+func (c *Client) PublishToStream(ctx context.Context, topic string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context error: %w", err)
+	}
+
+	record := &kgo.Record{Topic: topic, Value: data} //nolint: exhaustruct
+	if err := c.client.ProduceSync(ctx, record).FirstErr(); err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+
+	return nil
+}
+
+// Close implements the EventProcessor interface.
+// It flushes buffered records within the context deadline and closes the client.
+func (c *Client) Close(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context error: %w", err)
+	}
+
+	defer c.client.Close()
+
+	if err := c.client.Flush(ctx); err != nil {
+		return fmt.Errorf("failed to flush records: %w", err)
+	}
+
+	return nil
+}
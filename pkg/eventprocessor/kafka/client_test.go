@@ -0,0 +1,202 @@
+package kafka_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"go.uber.org/zap/zaptest"
+)
+
+const (
+	testTimeout  = 10 * time.Second
+	messageCount = 20
+)
+
+func newTestConfig(t *testing.T, topics ...string) *kafka.Config {
+	t.Helper()
+
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, topics...))
+	require.NoError(t, err)
+	t.Cleanup(cluster.Close)
+
+	return &kafka.Config{
+		Brokers:  cluster.ListenAddrs(),
+		ClientID: "test-client",
+		Logger:   zaptest.NewLogger(t),
+	}
+}
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			name string
+			cfg  *kafka.Config
+		}{
+			{name: "nil config", cfg: nil},
+			{name: "no brokers", cfg: &kafka.Config{Brokers: nil, ClientID: "", Logger: zaptest.NewLogger(t)}},
+			{name: "no logger", cfg: &kafka.Config{Brokers: []string{"localhost:9092"}, ClientID: "", Logger: nil}},
+		}
+		for _, tt := range tests {
+			_, err := kafka.NewClient(tt.cfg)
+			assert.ErrorIs(t, err, kafka.ErrInvalidConfig, tt.name)
+		}
+	})
+
+	t.Run("PublishConsume", func(t *testing.T) {
+		t.Parallel()
+		cfg := newTestConfig(t, "events")
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		client, err := kafka.NewClient(cfg)
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		for i := range messageCount {
+			require.NoError(t, client.PublishToStream(ctx, "events", []byte(strconv.Itoa(i))))
+		}
+
+		var (
+			mu       sync.Mutex
+			received []string
+		)
+		consumer, err := kafka.NewGroupConsumer(cfg, "test-group", []string{"events"},
+			func(_ context.Context, msg *kafka.Message) error {
+				mu.Lock()
+				defer mu.Unlock()
+				received = append(received, string(msg.Value))
+				if len(received) == messageCount {
+					cancel()
+				}
+
+				return nil
+			})
+		require.NoError(t, err)
+		defer consumer.Close(context.Background())
+
+		require.NoError(t, consumer.Run(ctx))
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, received, messageCount)
+		for i, v := range received {
+			assert.Equal(t, strconv.Itoa(i), v)
+		}
+	})
+
+	t.Run("HandlerErrorResumesFromFailedRecord", func(t *testing.T) {
+		t.Parallel()
+		cfg := newTestConfig(t, "orders")
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		client, err := kafka.NewClient(cfg)
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		for i := range 3 {
+			require.NoError(t, client.PublishToStream(ctx, "orders", []byte(strconv.Itoa(i))))
+		}
+
+		errBoom := errors.New("boom")
+		failing, err := kafka.NewGroupConsumer(cfg, "orders-group", []string{"orders"},
+			func(_ context.Context, msg *kafka.Message) error {
+				if string(msg.Value) == "1" {
+					return errBoom
+				}
+
+				return nil
+			})
+		require.NoError(t, err)
+		require.ErrorIs(t, failing.Run(ctx), errBoom)
+		require.NoError(t, failing.Close(context.Background()))
+
+		// A new member of the group starts at the record that failed
+		resumeCtx, resumeCancel := context.WithTimeout(ctx, testTimeout)
+		defer resumeCancel()
+
+		var first string
+		resumed, err := kafka.NewGroupConsumer(cfg, "orders-group", []string{"orders"},
+			func(_ context.Context, msg *kafka.Message) error {
+				if first == "" {
+					first = string(msg.Value)
+				}
+				resumeCancel()
+
+				return nil
+			})
+		require.NoError(t, err)
+		defer resumed.Close(context.Background())
+
+		require.NoError(t, resumed.Run(resumeCtx))
+		assert.Equal(t, "1", first)
+	})
+	t.Run("CloseFinishesHandledRecord", func(t *testing.T) {
+		t.Parallel()
+		cfg := newTestConfig(t, "payments")
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		client, err := kafka.NewClient(cfg)
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		for i := range 3 {
+			require.NoError(t, client.PublishToStream(ctx, "payments", []byte(strconv.Itoa(i))))
+		}
+
+		started := make(chan struct{})
+		var handled []string
+		consumer, err := kafka.NewGroupConsumer(cfg, "payments-group", []string{"payments"},
+			func(_ context.Context, msg *kafka.Message) error {
+				if len(handled) == 0 {
+					close(started)
+					time.Sleep(200 * time.Millisecond)
+				}
+				handled = append(handled, string(msg.Value))
+
+				return nil
+			})
+		require.NoError(t, err)
+
+		runErr := make(chan error, 1)
+		go func() { runErr <- consumer.Run(ctx) }()
+
+		// Close waits for the record being handled and commits it before leaving the group
+		<-started
+		require.NoError(t, consumer.Close(ctx))
+		require.NoError(t, <-runErr)
+		assert.Equal(t, []string{"0"}, handled)
+
+		resumeCtx, resumeCancel := context.WithTimeout(ctx, testTimeout)
+		defer resumeCancel()
+
+		var first string
+		resumed, err := kafka.NewGroupConsumer(cfg, "payments-group", []string{"payments"},
+			func(_ context.Context, msg *kafka.Message) error {
+				if first == "" {
+					first = string(msg.Value)
+				}
+				resumeCancel()
+
+				return nil
+			})
+		require.NoError(t, err)
+		defer resumed.Close(context.Background())
+
+		require.NoError(t, resumed.Run(resumeCtx))
+		assert.Equal(t, "1", first)
+	})
+}
@@ -0,0 +1,66 @@
+package kafka
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// DefaultClientID is the client ID reported to Kafka brokers when none is configured.
+const DefaultClientID = "event-processor"
+
+// Common errors.
+var (
+	// ErrInvalidConfig is returned when the configuration is invalid.
+	ErrInvalidConfig = errors.New("invalid configuration")
+)
+
+// Config holds configuration for Kafka event processors.
+type Config struct {
+	// Brokers are the seed broker addresses in host:port form
+	Brokers []string
+	// ClientID identifies this client to the brokers
+	ClientID string
+	// Logger is the configured zap logger instance
+	Logger *zap.Logger
+}
+
+// NewConfig creates a new configuration with values from environment.
+// KAFKA_BROKERS holds a comma-separated list of seed brokers.
+func NewConfig() *Config {
+	logger, err := zap.NewProduction()
+	if err != nil {
+		panic("failed to initialize logger: " + err.Error())
+	}
+
+	var brokers []string
+	if env := os.Getenv("KAFKA_BROKERS"); env != "" {
+		brokers = strings.Split(env, ",")
+	}
+
+	return &Config{
+		Brokers:  brokers,
+		ClientID: DefaultClientID,
+		Logger:   logger,
+	}
+}
+
+// validate checks that the configuration can be used to create a client.
+func (c *Config) validate() error {
+	if c == nil || len(c.Brokers) == 0 || c.Logger == nil {
+		return ErrInvalidConfig
+	}
+
+	return nil
+}
+
+// clientID returns the configured client ID or DefaultClientID.
+func (c *Config) clientID() string {
+	if c.ClientID == "" {
+		return DefaultClientID
+	}
+
+	return c.ClientID
+}
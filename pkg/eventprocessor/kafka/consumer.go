@@ -0,0 +1,199 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+)
+
+// Message is a consumed Kafka record passed to a Handler.
+type Message struct {
+	// Topic is the topic the record was produced to
+	Topic string
+	// Partition is the partition the record was read from
+	Partition int32
+	// Offset is the record offset within its partition
+	Offset int64
+	// Key is the record key, nil when unset
+	Key []byte
+	// Value is the record payload
+	Value []byte
+	// Headers are the record headers
+	Headers map[string]string
+	// Timestamp is the time the record was produced
+	Timestamp time.Time
+}
+
+// Handler processes a consumed record.
+// Returning an error stops the consumer without committing the record.
+type Handler func(ctx context.Context, msg *Message) error
+
+// GroupConsumer consumes topics as a member of a Kafka consumer group.
+// Offsets are committed only after records are handled, giving at-least-once delivery.
+type GroupConsumer struct {
+	client   *kgo.Client
+	handler  Handler
+	logger   *zap.Logger
+	stopping context.Context //nolint: containedctx
+	stop     context.CancelFunc
+	mu       sync.Mutex
+	running  chan struct{}
+}
+
+// NewGroupConsumer creates a consumer that joins group and reads topics from the earliest uncommitted offset.
+func NewGroupConsumer(cfg *Config, group string, topics []string, handler Handler) (*GroupConsumer, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	if group == "" || len(topics) == 0 || handler == nil {
+		return nil, fmt.Errorf("group, topics and handler are required: %w", ErrInvalidConfig)
+	}
+
+	kc, err := kgo.NewClient(
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.ClientID(cfg.clientID()),
+		kgo.ConsumerGroup(group),
+		kgo.ConsumeTopics(topics...),
+		kgo.DisableAutoCommit(),
+		kgo.BlockRebalanceOnPoll(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka client: %w", err)
+	}
+
+	stopping, stop := context.WithCancel(context.Background())
+
+	return &GroupConsumer{
+		client:   kc,
+		handler:  handler,
+		logger:   cfg.Logger,
+		stopping: stopping,
+		stop:     stop,
+		mu:       sync.Mutex{},
+		running:  nil,
+	}, nil
+}
+
+// Run polls records and passes them to the handler until ctx is canceled or the consumer is closed.
+// It returns the first handler error after committing the records handled before it.
+// The consumer must then be closed; a new consumer in the same group resumes from the failed record.
+// Run returns nil once Close is called, after committing the records handled so far.
+func (g *GroupConsumer) Run(ctx context.Context) error {
+	g.mu.Lock()
+	running := make(chan struct{})
+	g.running = running
+	g.mu.Unlock()
+
+	defer close(running)
+
+	pollCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stopPolling := context.AfterFunc(g.stopping, cancel)
+	defer stopPolling()
+
+	for {
+		fetches := g.client.PollFetches(pollCtx)
+		if fetches.IsClientClosed() || pollCtx.Err() != nil {
+			return nil
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			g.logger.Error("failed to fetch records",
+				zap.String("topic", topic),
+				zap.Int32("partition", partition),
+				zap.Error(err),
+			)
+		})
+
+		err := g.handle(ctx, fetches)
+		g.client.AllowRebalance()
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// handle runs the handler over all fetched records and commits the ones that succeeded.
+func (g *GroupConsumer) handle(ctx context.Context, fetches kgo.Fetches) error {
+	var (
+		handled    []*kgo.Record
+		handlerErr error
+	)
+
+	iter := fetches.RecordIter()
+	for !iter.Done() && g.stopping.Err() == nil {
+		record := iter.Next()
+		if err := g.handler(ctx, newMessage(record)); err != nil {
+			handlerErr = fmt.Errorf("failed to handle record %s/%d@%d: %w",
+				record.Topic, record.Partition, record.Offset, err)
+
+			break
+		}
+
+		handled = append(handled, record)
+	}
+
+	// Handled records are committed even when ctx was canceled mid-batch
+	if len(handled) > 0 {
+		if err := g.client.CommitRecords(context.WithoutCancel(ctx), handled...); err != nil {
+			return errors.Join(handlerErr, fmt.Errorf("failed to commit offsets: %w", err))
+		}
+	}
+
+	return handlerErr
+}
+
+// Close stops Run from polling and waits, within the context deadline, for the record being handled
+// and the commit of handled records, then leaves the consumer group and closes the client.
+// Records fetched but not yet handled are left uncommitted for the next member of the group.
+func (g *GroupConsumer) Close(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context error: %w", err)
+	}
+
+	defer g.client.Close()
+
+	g.stop()
+
+	g.mu.Lock()
+	running := g.running
+	g.mu.Unlock()
+
+	if running == nil {
+		return nil
+	}
+
+	select {
+	case <-running:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to finish handling records: %w: %w", eventprocessor.ErrShutdownIncomplete, ctx.Err())
+	}
+}
+
+// newMessage converts a kgo record into a Message.
+func newMessage(record *kgo.Record) *Message {
+	headers := make(map[string]string, len(record.Headers))
+	for _, h := range record.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+
+	return &Message{
+		Topic:     record.Topic,
+		Partition: record.Partition,
+		Offset:    record.Offset,
+		Key:       record.Key,
+		Value:     record.Value,
+		Headers:   headers,
+		Timestamp: record.Timestamp,
+	}
+}
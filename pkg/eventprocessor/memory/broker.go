@@ -0,0 +1,233 @@
+// Package memory provides an in-memory EventProcessor that mirrors the NATS clients without a server.
+// It is intended for unit tests and local development.
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+)
+
+// DefaultSubscriptionBuffer is the number of undelivered messages kept per core subscription
+// before new messages are dropped, mirroring a NATS slow consumer.
+const DefaultSubscriptionBuffer = 1024
+
+// ErrClosed is returned when the broker is used after Close.
+var ErrClosed = errors.New("broker closed")
+
+// Compile-time checks that Broker implements EventProcessor and EventPublisher.
+var (
+	_ eventprocessor.EventProcessor = (*Broker)(nil)
+	_ eventprocessor.EventPublisher = (*Broker)(nil)
+)
+
+// Message is a message stored by the broker.
+type Message struct {
+	// Subject is the subject the message was published to
+	Subject string
+	// Data is the message payload
+	Data []byte
+	// Headers are the headers the message was published with, nil when none were set
+	Headers eventprocessor.Header
+	// Sequence is the position of the message in the broker log, starting at 1
+	Sequence uint64
+	// Timestamp is the time the message was published
+	Timestamp time.Time
+}
+
+// Broker is an in-memory message broker.
+// Every published message is kept in a log so consumers can replay it,
+// while core subscriptions only receive messages published after they were created.
+type Broker struct {
+	mu        sync.Mutex
+	log       []*Message
+	subs      []*subscription
+	consumers map[string]*consumerState
+	changed   chan struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// NewBroker creates an empty in-memory broker.
+func NewBroker() *Broker {
+	return &Broker{
+		mu:        sync.Mutex{},
+		log:       nil,
+		subs:      nil,
+		consumers: make(map[string]*consumerState),
+		changed:   make(chan struct{}),
+		closed:    false,
+		wg:        sync.WaitGroup{},
+	}
+}
+
+// PublishToStream implements the EventProcessor interface.
+// It appends the message to the log and delivers it to all matching subscriptions.
+func (b *Broker) PublishToStream(ctx context.Context, subject string, data []byte) error {
+	return b.publish(ctx, subject, nil, data)
+}
+
+// PublishEvent implements the EventPublisher interface.
+// The event attributes are stored as message headers, so consumers read it back with Message.Event.
+func (b *Broker) PublishEvent(ctx context.Context, topic string, event eventprocessor.Event) error {
+	if err := event.Validate(); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	return b.publish(ctx, topic, event.Header(), event.Data)
+}
+
+// publish appends a message with header to the log and delivers it to all matching subscriptions.
+func (b *Broker) publish(ctx context.Context, subject string, header eventprocessor.Header, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context error: %w", err)
+	}
+
+	if err := eventprocessor.ValidateSubject(subject); err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return fmt.Errorf("failed to publish message: %w", ErrClosed)
+	}
+
+	msg := &Message{
+		Subject:   subject,
+		Data:      append([]byte(nil), data...),
+		Headers:   header,
+		Sequence:  uint64(len(b.log)) + 1,
+		Timestamp: time.Now(),
+	}
+	b.log = append(b.log, msg)
+
+	for _, sub := range b.subs {
+		if eventprocessor.MatchSubject(sub.pattern, subject) {
+			sub.deliver(msg.Data)
+		}
+	}
+
+	for _, state := range b.consumers {
+		if state.matches(subject) {
+			state.pending++
+		}
+	}
+
+	// Wake up consumers waiting for new messages
+	close(b.changed)
+	b.changed = make(chan struct{})
+
+	return nil
+}
+
+// Subscribe registers handler for messages published to subjects matching pattern.
+// Handlers run on a dedicated goroutine per subscription, in publish order.
+func (b *Broker) Subscribe(pattern string, handler func([]byte)) error {
+	if pattern == "" || handler == nil {
+		return fmt.Errorf("failed to subscribe: %w", eventprocessor.ErrInvalidSubject)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return fmt.Errorf("failed to subscribe: %w", ErrClosed)
+	}
+
+	sub := &subscription{
+		pattern: pattern,
+		ch:      make(chan []byte, DefaultSubscriptionBuffer),
+	}
+	b.subs = append(b.subs, sub)
+
+	b.wg.Add(1)
+
+	go func() {
+		defer b.wg.Done()
+
+		for data := range sub.ch {
+			handler(data)
+		}
+	}()
+
+	return nil
+}
+
+// Messages returns a snapshot of all stored messages matching pattern, in publish order.
+func (b *Broker) Messages(pattern string) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var out []Message
+
+	for _, msg := range b.log {
+		if eventprocessor.MatchSubject(pattern, msg.Subject) {
+			stored := *msg
+			stored.Headers = maps.Clone(msg.Headers)
+			out = append(out, stored)
+		}
+	}
+
+	return out
+}
+
+// Close implements the EventProcessor interface.
+// It stops all subscriptions and consumers and waits for running handlers within the context deadline.
+func (b *Broker) Close(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context error: %w", err)
+	}
+
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+
+		for _, sub := range b.subs {
+			close(sub.ch)
+		}
+
+		for _, state := range b.consumers {
+			if state.owner != nil {
+				state.owner.signal()
+			}
+		}
+
+		close(b.changed)
+	}
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to stop handlers: %w: %w", eventprocessor.ErrShutdownIncomplete, ctx.Err())
+	}
+}
+
+// subscription is a core pub/sub subscription without persistence.
+type subscription struct {
+	pattern string
+	ch      chan []byte
+}
+
+// deliver queues data for the subscription handler, dropping it when the buffer is full.
+func (s *subscription) deliver(data []byte) {
+	select {
+	case s.ch <- data:
+	default:
+	}
+}
@@ -0,0 +1,287 @@
+package memory_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/eventprocessortest"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTimeout = 5 * time.Second
+
+func TestBrokerSuite(t *testing.T) {
+	t.Parallel()
+
+	eventprocessortest.Run(t, "test.memory", func(_ *testing.T) eventprocessortest.PubSub {
+		return memory.NewBroker()
+	})
+	eventprocessortest.RunConsumers(t, "test.memory.consumers",
+		func(*testing.T, string) eventprocessortest.Streaming[memory.ConsumerOption, *memory.Consumer] {
+			return memory.NewBroker()
+		}, memory.WithAckWait)
+}
+
+func TestBroker(t *testing.T) { //nolint: funlen
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("InvalidSubject", func(t *testing.T) {
+		t.Parallel()
+		broker := memory.NewBroker()
+		defer broker.Close(ctx)
+
+		for _, subject := range []string{"", "orders.*", "orders.>", "orders..created"} {
+			err := broker.PublishToStream(ctx, subject, []byte("data"))
+			assert.ErrorIs(t, err, eventprocessor.ErrInvalidSubject, subject)
+		}
+	})
+
+	t.Run("ConsumerReplaysStoredMessages", func(t *testing.T) {
+		t.Parallel()
+		broker := memory.NewBroker()
+		defer broker.Close(ctx)
+
+		require.NoError(t, broker.PublishToStream(ctx, "orders.created", []byte("1")))
+		require.NoError(t, broker.PublishToStream(ctx, "payments.created", []byte("skip")))
+		require.NoError(t, broker.PublishToStream(ctx, "orders.updated", []byte("2")))
+
+		var (
+			mu   sync.Mutex
+			seen []string
+		)
+		consumer, err := broker.CreateConsumer(ctx, "orders", func(_ context.Context, msg *eventprocessor.Message) eventprocessor.Result {
+			mu.Lock()
+			defer mu.Unlock()
+			seen = append(seen, string(msg.Data))
+
+			return eventprocessor.Ack()
+		}, memory.WithFilterSubjects("orders.>"))
+		require.NoError(t, err)
+		defer consumer.Stop()
+
+		require.NoError(t, broker.PublishToStream(ctx, "orders.deleted", []byte("3")))
+
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return len(seen) == 3
+		}, testTimeout, 10*time.Millisecond)
+		assert.Equal(t, []string{"1", "2", "3"}, seen)
+		assert.Len(t, broker.Messages(">"), 4)
+	})
+
+	t.Run("EventHeaders", func(t *testing.T) {
+		t.Parallel()
+		broker := memory.NewBroker()
+		defer broker.Close(ctx)
+
+		event := eventprocessor.Event{ //nolint: exhaustruct
+			ID:            "evt-1",
+			Type:          "orders.created",
+			Source:        "test",
+			SchemaVersion: "2",
+			Headers:       map[string]string{"Tenant": "acme"},
+			Data:          []byte(`{"id":"o-1"}`),
+		}
+		require.NoError(t, broker.PublishEvent(ctx, "orders.created", event))
+		require.ErrorIs(t, broker.PublishEvent(ctx, "orders.created", eventprocessor.Event{}), //nolint: exhaustruct
+			eventprocessor.ErrInvalidEvent)
+
+		received := make(chan eventprocessor.Event, 1)
+		consumer, err := broker.CreateConsumer(ctx, "events",
+			func(_ context.Context, msg *eventprocessor.Message) eventprocessor.Result {
+				got, err := msg.Event()
+				assert.NoError(t, err)
+				received <- got
+
+				return eventprocessor.Ack()
+			})
+		require.NoError(t, err)
+		defer consumer.Stop()
+
+		select {
+		case got := <-received:
+			assert.Equal(t, event.ID, got.ID)
+			assert.Equal(t, event.SchemaVersion, got.SchemaVersion)
+			assert.Equal(t, "acme", got.Headers["Tenant"])
+			assert.Equal(t, event.Data, got.Data)
+		case <-time.After(testTimeout):
+			t.Fatal("event was not consumed")
+		}
+
+		stored := broker.Messages(">")
+		require.Len(t, stored, 1)
+		assert.Equal(t, "evt-1", stored[0].Headers.Get(eventprocessor.EventIDHeader))
+	})
+
+	t.Run("NumPending", func(t *testing.T) {
+		t.Parallel()
+		broker := memory.NewBroker()
+		defer broker.Close(ctx)
+
+		for _, subject := range []string{"orders.created", "payments.created", "orders.updated"} {
+			require.NoError(t, broker.PublishToStream(ctx, subject, []byte("old")))
+		}
+
+		var (
+			mu      sync.Mutex
+			pending []uint64
+		)
+		record := func(_ context.Context, msg *eventprocessor.Message) eventprocessor.Result {
+			mu.Lock()
+			defer mu.Unlock()
+			pending = append(pending, msg.Metadata.NumPending)
+
+			return eventprocessor.Ack()
+		}
+
+		// Messages matching the consumer are counted until delivered, later ones only WithDeliverNew
+		consumer, err := broker.CreateConsumer(ctx, "orders", record, memory.WithFilterSubjects("orders.>"))
+		require.NoError(t, err)
+		defer consumer.Stop()
+
+		fresh, err := broker.CreateConsumer(ctx, "new", record, memory.WithDeliverNew())
+		require.NoError(t, err)
+		defer fresh.Stop()
+
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return len(pending) == 2
+		}, testTimeout, 10*time.Millisecond)
+		assert.Equal(t, []uint64{1, 0}, pending)
+
+		require.NoError(t, broker.PublishToStream(ctx, "orders.deleted", []byte("new")))
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return len(pending) == 4
+		}, testTimeout, 10*time.Millisecond)
+		assert.Equal(t, []uint64{1, 0, 0, 0}, pending)
+	})
+
+	t.Run("AckSemantics", func(t *testing.T) {
+		t.Parallel()
+		broker := memory.NewBroker()
+		defer broker.Close(ctx)
+
+		require.NoError(t, broker.PublishToStream(ctx, "jobs.retry", []byte("retry")))
+		require.NoError(t, broker.PublishToStream(ctx, "jobs.poison", []byte("poison")))
+
+		var retried, poisoned atomic.Uint64
+		consumer, err := broker.CreateConsumer(ctx, "jobs", func(_ context.Context, msg *eventprocessor.Message) eventprocessor.Result {
+			if msg.Subject == "jobs.poison" {
+				poisoned.Add(1)
+
+				return eventprocessor.Term(errors.New("poison"))
+			}
+
+			retried.Store(msg.Metadata.NumDelivered)
+			if msg.Metadata.NumDelivered < 3 {
+				return eventprocessor.Nak(10*time.Millisecond, errors.New("not yet"))
+			}
+
+			return eventprocessor.Ack()
+		}, memory.WithFilterSubjects("jobs.*"))
+		require.NoError(t, err)
+		defer consumer.Stop()
+
+		require.Eventually(t, func() bool {
+			return retried.Load() == 3
+		}, testTimeout, 10*time.Millisecond)
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, uint64(1), poisoned.Load())
+	})
+
+	t.Run("DurableConsumerResumes", func(t *testing.T) {
+		t.Parallel()
+		broker := memory.NewBroker()
+		defer broker.Close(ctx)
+
+		for _, v := range []string{"1", "2", "3"} {
+			require.NoError(t, broker.PublishToStream(ctx, "events.tick", []byte(v)))
+		}
+
+		// The first run acks only the first message and keeps naking the rest
+		var naked atomic.Bool
+		filter := memory.WithFilterSubjects("events.>")
+		consumer, err := broker.CreateConsumer(ctx, "durable", func(_ context.Context, msg *eventprocessor.Message) eventprocessor.Result {
+			if msg.Metadata.Sequence.Stream == 1 {
+				return eventprocessor.Ack()
+			}
+			naked.Store(true)
+
+			return eventprocessor.Nak(10*time.Millisecond, errors.New("not now"))
+		}, filter)
+		require.NoError(t, err)
+
+		_, err = broker.CreateConsumer(ctx, "durable", func(context.Context, *eventprocessor.Message) eventprocessor.Result {
+			return eventprocessor.Ack()
+		}, filter)
+		require.ErrorIs(t, err, memory.ErrConsumerActive)
+
+		require.Eventually(t, naked.Load, testTimeout, 10*time.Millisecond)
+		consumer.Stop()
+		<-consumer.Done()
+
+		var (
+			mu      sync.Mutex
+			resumed []uint64
+		)
+		consumer, err = broker.CreateConsumer(ctx, "durable", func(_ context.Context, msg *eventprocessor.Message) eventprocessor.Result {
+			mu.Lock()
+			defer mu.Unlock()
+			resumed = append(resumed, msg.Metadata.Sequence.Stream)
+
+			return eventprocessor.Ack()
+		}, filter)
+		require.NoError(t, err)
+		defer consumer.Stop()
+
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return len(resumed) == 2
+		}, testTimeout, 10*time.Millisecond)
+		assert.Equal(t, []uint64{2, 3}, resumed)
+	})
+
+	t.Run("CloseStopsConsumers", func(t *testing.T) {
+		t.Parallel()
+		broker := memory.NewBroker()
+
+		consumer, err := broker.CreateConsumer(ctx, "idle", func(context.Context, *eventprocessor.Message) eventprocessor.Result {
+			return eventprocessor.Ack()
+		})
+		require.NoError(t, err)
+
+		closeCtx, cancel := context.WithTimeout(ctx, testTimeout)
+		defer cancel()
+		require.NoError(t, broker.Close(closeCtx))
+
+		select {
+		case <-consumer.Done():
+		case <-closeCtx.Done():
+			t.Fatal("consumer was not stopped")
+		}
+
+		_, err = broker.CreateConsumer(ctx, "late", func(context.Context, *eventprocessor.Message) eventprocessor.Result {
+			return eventprocessor.Ack()
+		})
+		assert.ErrorIs(t, err, memory.ErrClosed)
+	})
+}
@@ -0,0 +1,338 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+)
+
+// DefaultAckWait is how long a delivered or in-progress message waits for an ack before it is redelivered,
+// unless the consumer sets WithAckWait.
+const DefaultAckWait = 30 * time.Second
+
+// streamName is the stream name reported in the metadata of delivered messages.
+const streamName = "memory"
+
+// ErrConsumerActive is returned when a durable consumer with the same name is already running.
+var ErrConsumerActive = errors.New("consumer already active")
+
+// ErrInvalidConfig is returned when a consumer is created without a name or handler.
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// ConsumerOption customizes consumers created with Broker.CreateConsumer.
+// The options mirror their namesakes of the nats package.
+type ConsumerOption func(*consumerConfig)
+
+// consumerConfig collects the settings of a consumer.
+type consumerConfig struct {
+	filters    []string
+	ackWait    time.Duration
+	deliverNew bool
+}
+
+// WithFilterSubjects delivers only messages published to subjects matching any of filters, all by default.
+func WithFilterSubjects(filters ...string) ConsumerOption {
+	return func(c *consumerConfig) {
+		c.filters = filters
+	}
+}
+
+// WithAckWait sets how long a delivered message waits for an ack before it is redelivered, DefaultAckWait by default.
+func WithAckWait(d time.Duration) ConsumerOption {
+	return func(c *consumerConfig) {
+		c.ackWait = d
+	}
+}
+
+// WithDeliverNew makes a new consumer skip the messages stored before it was created.
+func WithDeliverNew() ConsumerOption {
+	return func(c *consumerConfig) {
+		c.deliverNew = true
+	}
+}
+
+// Consumer delivers stored messages to a handler until stopped.
+type Consumer struct {
+	broker   *Broker
+	state    *consumerState
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// Stop stops the consumer after the running handler returns.
+// Unacknowledged messages are kept and redelivered to the next consumer with the same name.
+func (c *Consumer) Stop() {
+	c.broker.mu.Lock()
+	if c.state.owner == c {
+		c.state.owner = nil
+	}
+	c.broker.mu.Unlock()
+
+	c.signal()
+	c.cancel()
+}
+
+// Drain stops the consumer like Stop. Messages are delivered one at a time, so none are buffered.
+func (c *Consumer) Drain() {
+	c.Stop()
+}
+
+// Done returns a channel closed once the consumer has stopped.
+func (c *Consumer) Done() <-chan struct{} {
+	return c.done
+}
+
+// signal tells the delivery loop to exit.
+func (c *Consumer) signal() {
+	c.stopOnce.Do(func() { close(c.stopCh) })
+}
+
+// consumerState is the durable state of a named consumer, kept across restarts.
+type consumerState struct {
+	name    string
+	filters []string
+	ackWait time.Duration
+	// cursor is the log index of the first message never delivered to the consumer,
+	// and pending the number of messages matching the consumer from there on
+	cursor   int
+	pending  uint64
+	sequence uint64
+	// delivered counts the deliveries of unsettled messages by stream sequence and retryAt holds their redelivery time
+	delivered map[uint64]uint64
+	retryAt   map[uint64]time.Time
+	// owner is the running consumer, nil when stopped
+	owner *Consumer
+}
+
+// matches reports whether subject matches any filter of the consumer.
+func (s *consumerState) matches(subject string) bool {
+	return slices.ContainsFunc(s.filters, func(filter string) bool {
+		return eventprocessor.MatchSubject(filter, subject)
+	})
+}
+
+// CreateConsumer starts a durable consumer delivering stored messages to handler. Handlers are shared
+// with nats.JetStreamClient.CreateConsumer, so code under test can switch between the two.
+// A new name replays every stored message, or only later ones WithDeliverNew;
+// an existing name resumes from its unacknowledged messages. Messages are delivered one at a time in
+// publish order, nak'd messages are redelivered after their delay and unacknowledged ones after the ack wait.
+func (b *Broker) CreateConsumer(
+	ctx context.Context,
+	name string,
+	handler eventprocessor.Handler,
+	opts ...ConsumerOption,
+) (*Consumer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("context error: %w", err)
+	}
+
+	if name == "" || handler == nil {
+		return nil, fmt.Errorf("name and handler are required: %w", ErrInvalidConfig)
+	}
+
+	config := consumerConfig{filters: nil, ackWait: 0, deliverNew: false}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	filters := config.filters
+	if len(filters) == 0 {
+		filters = []string{">"}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, fmt.Errorf("failed to create consumer: %w", ErrClosed)
+	}
+
+	state, ok := b.consumers[name]
+	if ok && state.owner != nil {
+		return nil, fmt.Errorf("failed to create consumer %s: %w", name, ErrConsumerActive)
+	}
+
+	if !ok || !slices.Equal(state.filters, filters) {
+		state = &consumerState{
+			name:      name,
+			filters:   filters,
+			ackWait:   0,
+			cursor:    0,
+			pending:   0,
+			sequence:  0,
+			delivered: make(map[uint64]uint64),
+			retryAt:   make(map[uint64]time.Time),
+			owner:     nil,
+		}
+
+		if config.deliverNew {
+			state.cursor = len(b.log)
+		}
+
+		for _, msg := range b.log[state.cursor:] {
+			if state.matches(msg.Subject) {
+				state.pending++
+			}
+		}
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	consumer := &Consumer{
+		broker:   b,
+		state:    state,
+		stopCh:   make(chan struct{}),
+		stopOnce: sync.Once{},
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	state.ackWait = cmp.Or(config.ackWait, DefaultAckWait)
+	state.owner = consumer
+	b.consumers[name] = state
+
+	b.wg.Add(1)
+
+	go b.consume(runCtx, consumer, handler)
+
+	return consumer, nil
+}
+
+// consume runs the delivery loop of a consumer until it is stopped.
+func (b *Broker) consume(ctx context.Context, c *Consumer, handler eventprocessor.Handler) {
+	defer b.wg.Done()
+	defer close(c.done)
+
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		default:
+		}
+
+		msg, wait, changed := b.next(c.state)
+		if msg != nil {
+			// Settling a delivery cannot fail
+			_ = msg.Respond(handler(ctx, msg))
+
+			continue
+		}
+
+		if !b.wait(c.stopCh, wait, changed) {
+			return
+		}
+	}
+}
+
+// wait blocks until a message is published, the next redelivery is due or the consumer is stopped.
+// It returns false when the consumer was stopped.
+func (b *Broker) wait(stop <-chan struct{}, wait time.Duration, changed <-chan struct{}) bool {
+	var retry <-chan time.Time
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		retry = timer.C
+	}
+
+	select {
+	case <-stop:
+		return false
+	case <-changed:
+		return true
+	case <-retry:
+		return true
+	}
+}
+
+// next returns the next message for the consumer, due for redelivery after the ack wait unless it is settled
+// before: the earliest unsettled message due for redelivery or else the next message never delivered.
+// When none is ready it returns the time until the next scheduled redelivery, or zero, and a channel closed on
+// the next publish. Only unsettled messages and the messages past the consumer cursor are looked at.
+func (b *Broker) next(state *consumerState) (*eventprocessor.Message, time.Duration, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+
+	var (
+		due  uint64
+		wait time.Duration
+	)
+
+	for seq, at := range state.retryAt {
+		if until := at.Sub(now); until > 0 {
+			if wait == 0 || until < wait {
+				wait = until
+			}
+
+			continue
+		}
+
+		if due == 0 || seq < due {
+			due = seq
+		}
+	}
+
+	// Skip past messages the consumer does not match
+	for state.cursor < len(b.log) && !state.matches(b.log[state.cursor].Subject) {
+		state.cursor++
+	}
+
+	var msg *Message
+
+	switch {
+	case due != 0 && (state.cursor == len(b.log) || due < b.log[state.cursor].Sequence):
+		msg = b.log[due-1]
+	case state.cursor < len(b.log):
+		msg = b.log[state.cursor]
+		state.cursor++
+		state.pending--
+	default:
+		return nil, wait, b.changed
+	}
+
+	state.sequence++
+	state.delivered[msg.Sequence]++
+	state.retryAt[msg.Sequence] = now.Add(state.ackWait)
+
+	return eventprocessor.NewMessage(msg.Subject, msg.Data, maps.Clone(msg.Headers), &eventprocessor.Metadata{
+		Sequence:     eventprocessor.SequencePair{Consumer: state.sequence, Stream: msg.Sequence},
+		NumDelivered: state.delivered[msg.Sequence],
+		NumPending:   state.pending,
+		Timestamp:    msg.Timestamp,
+		Stream:       streamName,
+		Consumer:     state.name,
+		Domain:       "",
+	}, &delivery{broker: b, state: state, sequence: msg.Sequence}), 0, b.changed
+}
+
+// settle records the handler result for the message with sequence seq.
+// Messages already acked or terminated stay settled.
+func (b *Broker) settle(state *consumerState, seq uint64, res eventprocessor.Result) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := state.delivered[seq]; !ok {
+		return
+	}
+
+	switch res.Action {
+	case eventprocessor.ActionNak:
+		state.retryAt[seq] = time.Now().Add(res.Delay)
+	case eventprocessor.ActionInProgress:
+		state.retryAt[seq] = time.Now().Add(state.ackWait)
+	case eventprocessor.ActionAck, eventprocessor.ActionTerm:
+		delete(state.delivered, seq)
+		delete(state.retryAt, seq)
+	}
+}
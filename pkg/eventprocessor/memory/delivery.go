@@ -0,0 +1,22 @@
+package memory
+
+import (
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+)
+
+// Compile-time check that delivery implements eventprocessor.Acknowledger.
+var _ eventprocessor.Acknowledger = (*delivery)(nil)
+
+// delivery acknowledges a stored message delivered to a consumer through the broker.
+type delivery struct {
+	broker   *Broker
+	state    *consumerState
+	sequence uint64
+}
+
+// Respond implements eventprocessor.Acknowledger. Settling a delivery never fails.
+func (d *delivery) Respond(res eventprocessor.Result) error {
+	d.broker.settle(d.state, d.sequence, res)
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package eventprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotAcknowledgeable is returned when responding to a message that was not delivered by a broker.
+var ErrNotAcknowledgeable = errors.New("message cannot be acknowledged")
+
+// Action describes how a consumed message is acknowledged after handling.
+type Action int
+
+const (
+	// ActionAck acknowledges the message as successfully processed.
+	ActionAck Action = iota
+	// ActionNak negatively acknowledges the message, optionally with a redelivery delay.
+	ActionNak
+	// ActionTerm terminates the message so it is never redelivered.
+	ActionTerm
+	// ActionInProgress resets the redelivery timer without acknowledging the message.
+	ActionInProgress
+)
+
+// String returns the lowercase name of the action.
+func (a Action) String() string {
+	switch a {
+	case ActionAck:
+		return "ack"
+	case ActionNak:
+		return "nak"
+	case ActionTerm:
+		return "term"
+	case ActionInProgress:
+		return "in_progress"
+	default:
+		return fmt.Sprintf("action(%d)", int(a))
+	}
+}
+
+// Result is returned by a Handler and decides how the message is acknowledged.
+type Result struct {
+	// Action is the acknowledgement to send
+	Action Action
+	// Delay is the redelivery delay used with ActionNak, zero means immediate redelivery
+	Delay time.Duration
+	// Err is the reason for a nak or term, used for logging
+	Err error
+}
+
+// Ack returns a Result that acknowledges the message.
+func Ack() Result {
+	return Result{Action: ActionAck, Delay: 0, Err: nil}
+}
+
+// Nak returns a Result that requests redelivery after delay.
+func Nak(delay time.Duration, err error) Result {
+	return Result{Action: ActionNak, Delay: delay, Err: err}
+}
+
+// Term returns a Result that stops any further redelivery of the message.
+func Term(err error) Result {
+	return Result{Action: ActionTerm, Delay: 0, Err: err}
+}
+
+// InProgress returns a Result that extends the ack deadline without acknowledging.
+// The message is redelivered after the consumer ack wait unless it is acknowledged elsewhere.
+func InProgress() Result {
+	return Result{Action: ActionInProgress, Delay: 0, Err: nil}
+}
+
+// Header holds message headers. Keys are case-sensitive, like NATS headers.
+type Header map[string][]string
+
+// Add appends value to the values of key.
+func (h Header) Add(key, value string) {
+	h[key] = append(h[key], value)
+}
+
+// Set replaces the values of key with value.
+func (h Header) Set(key, value string) {
+	h[key] = []string{value}
+}
+
+// Get returns the first value of key, empty when there is none.
+func (h Header) Get(key string) string {
+	if values := h[key]; len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// Values returns all values of key.
+func (h Header) Values(key string) []string {
+	return h[key]
+}
+
+// Del deletes the values of key.
+func (h Header) Del(key string) {
+	delete(h, key)
+}
+
+// SequencePair holds the position of a message in its stream and in the consumer delivering it.
+type SequencePair struct {
+	// Consumer is the delivery sequence of the consumer, counting redeliveries
+	Consumer uint64
+	// Stream is the sequence of the message in the stream
+	Stream uint64
+}
+
+// Metadata describes the delivery of a consumed message.
+type Metadata struct {
+	// Sequence holds the stream and consumer sequences
+	Sequence SequencePair
+	// NumDelivered is the number of times the message was delivered, starting at 1
+	NumDelivered uint64
+	// NumPending is the number of messages matching the consumer not delivered yet
+	NumPending uint64
+	// Timestamp is the time the message was stored
+	Timestamp time.Time
+	// Stream is the name of the stream storing the message
+	Stream string
+	// Consumer is the name of the consumer the message was delivered to
+	Consumer string
+	// Domain is the domain the message was received on, empty when none
+	Domain string
+}
+
+// Acknowledger settles a delivered message with the broker that delivered it.
+type Acknowledger interface {
+	// Respond acknowledges the message according to res.
+	// Returns an error if the acknowledgement could not be sent
+	Respond(res Result) error
+}
+
+// Message is a consumed message passed to a Handler.
+type Message struct {
+	// Subject is the subject the message was published to
+	Subject string
+	// Data is the message payload
+	Data []byte
+	// Headers are the message headers, nil when none were set
+	Headers Header
+	// Metadata holds the stream and consumer sequences, delivery count and timestamp
+	Metadata *Metadata
+
+	acker Acknowledger
+}
+
+// NewMessage creates a delivered message that acknowledger settles.
+// Brokers use it to feed a Handler.
+func NewMessage(subject string, data []byte, headers Header, metadata *Metadata, acknowledger Acknowledger) *Message {
+	return &Message{
+		Subject:  subject,
+		Data:     data,
+		Headers:  headers,
+		Metadata: metadata,
+		acker:    acknowledger,
+	}
+}
+
+// Respond acknowledges the message according to res.
+// Messages passed to a Handler are acknowledged with its Result, so Respond is meant for
+// messages fetched on demand, such as with a NATS pull consumer.
+func (m *Message) Respond(res Result) error {
+	if m.acker == nil {
+		return ErrNotAcknowledgeable
+	}
+
+	return m.acker.Respond(res) //nolint: wrapcheck
+}
+
+// InProgress resets the redelivery timer, allowing long-running handlers to keep the message.
+func (m *Message) InProgress() error {
+	return m.Respond(InProgress())
+}
+
+// Handler processes a consumed message and returns how it should be acknowledged.
+type Handler func(ctx context.Context, msg *Message) Result
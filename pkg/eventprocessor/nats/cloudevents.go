@@ -0,0 +1,43 @@
+package nats
+
+import (
+	"fmt"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/nats-io/nats.go"
+)
+
+// ErrInvalidCloudEvent is returned when a message is not a valid CloudEvents 1.0 event.
+var ErrInvalidCloudEvent = eventprocessor.ErrInvalidCloudEvent
+
+// CloudEvents attributes used by the NATS protocol binding.
+const (
+	// CloudEventsSpecVersion is the supported CloudEvents specification version.
+	CloudEventsSpecVersion = eventprocessor.CloudEventsSpecVersion
+	// CloudEventsContentType is the content type of structured mode messages.
+	CloudEventsContentType = eventprocessor.CloudEventsContentType
+)
+
+// CloudEventsMode selects how an Event is encoded as a CloudEvent.
+type CloudEventsMode = eventprocessor.CloudEventsMode
+
+const (
+	// CloudEventsBinary carries attributes in ce-* headers and the payload as the message body.
+	CloudEventsBinary = eventprocessor.CloudEventsBinary
+	// CloudEventsStructured carries attributes and payload together in a JSON body.
+	CloudEventsStructured = eventprocessor.CloudEventsStructured
+)
+
+// cloudEventMsg encodes event as a CloudEvent message for subject in mode.
+func cloudEventMsg(subject string, event Event, mode CloudEventsMode) (*nats.Msg, error) {
+	if mode != CloudEventsBinary && mode != CloudEventsStructured {
+		return nil, fmt.Errorf("unknown CloudEvents mode %d: %w", mode, ErrInvalidConfig)
+	}
+
+	header, data, err := eventprocessor.EncodeCloudEvent(event, mode)
+	if err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	return &nats.Msg{Subject: subject, Header: nats.Header(header), Data: data}, nil //nolint: exhaustruct
+}
@@ -0,0 +1,169 @@
+package nats_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decoded is the outcome of reading a consumed message as a CloudEvent.
+type decoded struct {
+	event nats.Event
+	err   error
+}
+
+func TestCloudEvents(t *testing.T) { //nolint: funlen
+	t.Parallel()
+
+	const testTimeout = 5 * time.Second
+
+	cfg := natstest.NewConfig(t)
+
+	client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+		Name:     "TEST_CLOUDEVENTS",
+		Subjects: []string{"test.cloudevents.>"},
+	})
+	require.NoError(t, err)
+	defer client.Close(context.Background())
+
+	nc, err := natsgo.Connect(cfg.URL)
+	require.NoError(t, err)
+	defer nc.Close()
+
+	js, err := jetstream.New(nc)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	base := testEvent()
+	text := base
+	text.ContentType = "text/plain"
+	text.Data = []byte("hello")
+	untyped := base
+	untyped.ContentType = ""
+	untyped.Data = []byte{0xff, 0x00}
+
+	tests := []struct {
+		name  string
+		event nats.Event
+		mode  nats.CloudEventsMode
+	}{
+		{name: "binary json", event: base, mode: nats.CloudEventsBinary},
+		{name: "structured json", event: base, mode: nats.CloudEventsStructured},
+		{name: "binary text", event: text, mode: nats.CloudEventsBinary},
+		{name: "structured text", event: text, mode: nats.CloudEventsStructured},
+		{name: "structured untyped binary data", event: untyped, mode: nats.CloudEventsStructured},
+	}
+	for _, tt := range tests {
+		require.NoError(t, client.PublishCloudEvent(ctx, "test.cloudevents.ok", tt.event, tt.mode), tt.name)
+	}
+
+	invalid := base
+	invalid.Source = ""
+	require.ErrorIs(t, client.PublishCloudEvent(ctx, "test.cloudevents.ok", invalid, nats.CloudEventsBinary),
+		nats.ErrInvalidCloudEvent)
+
+	// Events from other producers: text data as a JSON string, and invalid attributes
+	foreign := []*natsgo.Msg{
+		{
+			Subject: "test.cloudevents.foreign",
+			Header:  natsgo.Header{"Content-Type": []string{nats.CloudEventsContentType + "; charset=utf-8"}},
+			Data: []byte(`{"specversion":"1.0","id":"1","source":"/billing","type":"invoice.paid",` +
+				`"time":"2024-01-02T03:04:05Z","datacontenttype":"text/plain","data":"paid"}`),
+		},
+		{
+			Subject: "test.cloudevents.foreign",
+			Header:  natsgo.Header{"ce-specversion": []string{"0.3"}, "ce-id": []string{"2"}},
+			Data:    []byte("old"),
+		},
+		{
+			Subject: "test.cloudevents.foreign",
+			Header:  natsgo.Header{"ce-specversion": []string{"1.0"}, "ce-id": []string{"3"}},
+			Data:    []byte("no source"),
+		},
+		{
+			Subject: "test.cloudevents.foreign",
+			Header:  natsgo.Header{"Content-Type": []string{nats.CloudEventsContentType}},
+			Data:    []byte(`{"specversion":"1.0","id":"4","source":"/x","type":"t","time":"yesterday"}`),
+		},
+	}
+	for _, msg := range foreign {
+		_, err := js.PublishMsg(ctx, msg)
+		require.NoError(t, err)
+	}
+
+	results := make(chan decoded, len(tests)+len(foreign))
+	cc, err := client.CreateConsumer(ctx, "test-cloudevents", func(_ context.Context, msg *nats.Message) nats.Result {
+		event, err := msg.CloudEvent()
+		results <- decoded{event: event, err: err}
+
+		return nats.Ack()
+	})
+	require.NoError(t, err)
+	defer cc.Stop()
+
+	next := func() decoded {
+		select {
+		case res := <-results:
+			return res
+		case <-ctx.Done():
+			t.Fatal("message was not consumed")
+
+			return decoded{} //nolint: exhaustruct
+		}
+	}
+
+	for _, tt := range tests {
+		res := next()
+		require.NoError(t, res.err, tt.name)
+		assert.Equal(t, tt.event.ID, res.event.ID, tt.name)
+		assert.Equal(t, tt.event.Type, res.event.Type, tt.name)
+		assert.Equal(t, tt.event.Source, res.event.Source, tt.name)
+		assert.True(t, tt.event.Time.Equal(res.event.Time), tt.name)
+		assert.Equal(t, tt.event.ContentType, res.event.ContentType, tt.name)
+		assert.Equal(t, tt.event.SchemaVersion, res.event.SchemaVersion, tt.name)
+		assert.Equal(t, tt.event.Headers, res.event.Headers, tt.name)
+		assert.Equal(t, tt.event.Data, res.event.Data, tt.name)
+	}
+
+	res := next()
+	require.NoError(t, res.err)
+	assert.Equal(t, "invoice.paid", res.event.Type)
+	assert.Equal(t, []byte("paid"), res.event.Data)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), res.event.Time.UTC())
+
+	for range foreign[1:] {
+		assert.ErrorIs(t, next().err, nats.ErrInvalidCloudEvent)
+	}
+
+	t.Run("Simple", func(t *testing.T) {
+		simple, err := nats.NewSimpleNatsClient(cfg)
+		require.NoError(t, err)
+		defer simple.Close(context.Background())
+
+		events := make(chan nats.Event, 2)
+		_, err = simple.SubscribeCloudEvents("test.simple.cloudevents", func(event nats.Event) {
+			events <- event
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, simple.PublishEvent(ctx, "test.simple.cloudevents", base))
+		require.NoError(t, simple.PublishCloudEvent(ctx, "test.simple.cloudevents", base, nats.CloudEventsStructured))
+
+		select {
+		case got := <-events:
+			assert.Equal(t, base.ID, got.ID)
+			assert.Equal(t, base.Data, got.Data)
+		case <-ctx.Done():
+			t.Fatal("CloudEvent was not received")
+		}
+	})
+}
@@ -1,8 +1,10 @@
 package nats
 
 import (
+	"fmt"
 	"time"
 
+	"github.com/nats-io/nats.go"
 	"go.uber.org/zap"
 )
 
@@ -20,4 +22,37 @@ type Config struct {
 	ReconnectWait time.Duration
 	// Logger is the configured zap logger instance
 	Logger *zap.Logger
+	// Metrics records Prometheus metrics for clients created with this configuration, nil disables them
+	Metrics *Metrics
+	// Tracing propagates trace context through message headers and records spans, nil disables it
+	Tracing *Tracing
+}
+
+// connect opens a NATS connection for cfg, tracking its state in the metrics of client.
+// extra options are applied after the configured ones.
+func connect(cfg *Config, client string, extra ...nats.Option) (*nats.Conn, error) {
+	opts := []nats.Option{
+		nats.MaxReconnects(cfg.MaxReconnects),
+		nats.ReconnectWait(cfg.ReconnectWait),
+	}
+
+	// Use credentials file if provided, fallback to token
+	if cfg.CredsFile != "" {
+		opts = append(opts, nats.UserCredentials(cfg.CredsFile))
+	} else if cfg.Token != "" {
+		opts = append(opts, nats.Token(cfg.Token))
+	}
+
+	conn := cfg.Metrics.connection(client)
+	opts = append(opts, conn.options()...)
+	opts = append(opts, extra...)
+
+	nc, err := nats.Connect(cfg.URL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	conn.setConnected(true)
+
+	return nc, nil
 }
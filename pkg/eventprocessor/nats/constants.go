@@ -1,5 +1,7 @@
 package nats
 
+import "time"
+
 const (
 	// DefaultMaxReconnects is the default number of reconnection attempts.
 	DefaultMaxReconnects = 5
@@ -12,4 +14,29 @@ const (
 	DefaultMaxRequestMaxBytes = 1024 * 1024
 	// DefaultInactiveThresholdMultiplier is the multiplier for inactive threshold.
 	DefaultInactiveThresholdMultiplier = 2
+	// DefaultAckWait is the ack wait the server applies to consumers that do not set one.
+	DefaultAckWait = 30 * time.Second
+)
+
+const (
+	// DefaultIdempotencyCapacity is the default number of message IDs kept by the in-memory idempotency store.
+	DefaultIdempotencyCapacity = 10000
+	// DefaultIdempotencyTTL is the default time a processed message ID is remembered.
+	DefaultIdempotencyTTL = 24 * time.Hour
+)
+
+// DefaultRetryDelay is the initial delay of ExponentialRetry when none is given.
+const DefaultRetryDelay = time.Second
+
+// DefaultMaxInFlightPerWorker is the default number of unacknowledged messages per worker in a worker pool.
+const DefaultMaxInFlightPerWorker = 16
+
+// MetricsNamespace prefixes the names of all client metrics.
+const MetricsNamespace = "eventprocessor"
+
+const (
+	// DefaultRequestTimeout bounds requests and scatter-gathers whose context has no deadline.
+	DefaultRequestTimeout = 5 * time.Second
+	// DefaultReplyBuffer is the minimum number of undelivered replies buffered by ScatterGather.
+	DefaultReplyBuffer = 64
 )
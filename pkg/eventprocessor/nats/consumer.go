@@ -0,0 +1,162 @@
+package nats
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/ratelimit"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// ConsumerOption customizes consumers created by the JetStream clients.
+type ConsumerOption func(*consumerOptions)
+
+// consumerOptions collects the consumer configuration and client-side processing settings.
+type consumerOptions struct {
+	config   jetstream.ConsumerConfig
+	pool     *WorkerPoolConfig
+	throttle *ratelimit.Limiter
+	// deadLetterAfter is the delivery a DeadLetterQueue dead-letters messages at, zero without one
+	deadLetterAfter int
+}
+
+// WithRetryPolicy sets the consumer MaxDeliver from policy.
+// Handlers should apply the same policy with RetryPolicy.Handler so naks follow the schedule.
+// The ack wait is left alone, so handlers slower than the retry delays are not redelivered while running;
+// use WithAckBackOff to space out redeliveries after ack timeouts.
+func WithRetryPolicy(policy RetryPolicy) ConsumerOption {
+	return func(o *consumerOptions) {
+		if policy.MaxDeliver > 0 {
+			o.config.MaxDeliver = policy.MaxDeliver
+		}
+	}
+}
+
+// WithThrottle limits how fast messages are handled, per subject pattern.
+// While a handler waits for the limiter no further messages are pulled, and pull batches are
+// reduced to the smallest rule burst so buffered messages do not outlive their ack wait.
+func WithThrottle(limiter *ratelimit.Limiter) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.throttle = limiter
+	}
+}
+
+// consumerOptions builds the pull consumer options shared by the JetStream clients,
+// a durable consumer delivering all messages with explicit acks unless opts change it.
+// Conflicting options are rejected with an error wrapping ErrInvalidConfig.
+func (c *JetStreamClient) consumerOptions(name, description string, opts []ConsumerOption) (consumerOptions, error) {
+	o := consumerOptions{
+		config: jetstream.ConsumerConfig{ //nolint: exhaustruct
+			Name:               name,
+			Durable:            name,
+			DeliverPolicy:      jetstream.DeliverAllPolicy,
+			AckPolicy:          jetstream.AckExplicitPolicy,
+			Description:        fmt.Sprintf("%s %s for stream %s", description, name, c.streamConfig.Name),
+			MaxRequestBatch:    DefaultMaxRequestBatch,
+			MaxRequestExpires:  c.config.ReconnectWait,
+			MaxRequestMaxBytes: DefaultMaxRequestMaxBytes,
+			InactiveThreshold:  c.config.ReconnectWait * DefaultInactiveThresholdMultiplier,
+		},
+		pool:            nil,
+		throttle:        nil,
+		deadLetterAfter: 0,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if err := o.validate(); err != nil {
+		return consumerOptions{}, err
+	}
+
+	return o, nil
+}
+
+// validate checks for settings that conflict with each other or that the server rejects.
+func (o *consumerOptions) validate() error {
+	ackWait := cmp.Or(o.config.AckWait, DefaultAckWait)
+
+	for _, delay := range o.config.BackOff {
+		if delay < ackWait {
+			return fmt.Errorf("ack backoff %s is shorter than the ack wait %s: %w", delay, ackWait, ErrInvalidConfig)
+		}
+	}
+
+	if o.config.MaxDeliver > 0 && len(o.config.BackOff) >= o.config.MaxDeliver {
+		return fmt.Errorf("ack backoff needs fewer than %d delays: %w", o.config.MaxDeliver, ErrInvalidConfig)
+	}
+
+	if o.pool != nil && o.config.MaxAckPending != o.pool.MaxInFlight {
+		return fmt.Errorf("max ack pending %d differs from the worker pool MaxInFlight %d: %w",
+			o.config.MaxAckPending, o.pool.MaxInFlight, ErrInvalidConfig)
+	}
+
+	if o.deadLetterAfter > 0 && o.config.MaxDeliver > 0 && o.config.MaxDeliver <= o.deadLetterAfter {
+		return fmt.Errorf("max deliver %d stops redelivery before the dead-letter queue at %d: %w",
+			o.config.MaxDeliver, o.deadLetterAfter, ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// consume starts consuming from consumer with handler, keeping pull requests within the consumer's request limits.
+// With a worker pool configured, messages are handed to the pool instead of being handled inline.
+// The consume context is stopped by Close unless it was stopped or drained before.
+func (c *JetStreamClient) consume( //nolint: ireturn
+	consumer jetstream.Consumer,
+	handler Handler,
+	opts consumerOptions,
+) (jetstream.ConsumeContext, error) {
+	batch := DefaultMaxRequestBatch
+	if opts.config.MaxRequestBatch > 0 {
+		batch = opts.config.MaxRequestBatch
+	}
+
+	name := consumer.CachedInfo().Name
+	handler = c.metrics.instrument(c.name, name, c.tracing.trace(name, handler))
+
+	if opts.throttle != nil {
+		batch = min(batch, max(opts.throttle.MinBurst(), 1))
+		handler = throttled(opts.throttle, handler)
+	}
+
+	pullOpts := []jetstream.PullConsumeOpt{
+		jetstream.PullMaxMessages(batch),
+		jetstream.PullExpiry(c.config.ReconnectWait),
+	}
+
+	s := c.newConsumption(name)
+
+	if opts.pool == nil {
+		cc, err := consumer.Consume(s.wrap(dispatch(s.ctx, c.logger, handler)), pullOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create consume context: %w", err)
+		}
+
+		return c.track(s, cc), nil
+	}
+
+	pool := newWorkerPool(s.ctx, c.logger, *opts.pool, handler)
+
+	cc, err := consumer.Consume(s.wrap(pool.submit), pullOpts...)
+	if err != nil {
+		pool.close()
+
+		return nil, fmt.Errorf("failed to create consume context: %w", err)
+	}
+
+	return c.track(s, &pooledConsumeContext{ConsumeContext: cc, pool: pool}), nil
+}
+
+// throttled waits for limiter before running handler, naking the message if the wait fails.
+func throttled(limiter *ratelimit.Limiter, handler Handler) Handler {
+	return func(ctx context.Context, msg *Message) Result {
+		if err := limiter.Wait(ctx, msg.Subject); err != nil {
+			return Nak(0, err)
+		}
+
+		return handler(ctx, msg)
+	}
+}
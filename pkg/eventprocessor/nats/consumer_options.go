@@ -0,0 +1,136 @@
+package nats
+
+import (
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// ConsumerConfig returns the consumer configuration set by opts, for implementations of CreateConsumer
+// outside this package. Settings opts leave alone are zero, except a DeliverAllPolicy and AckExplicitPolicy.
+func ConsumerConfig(opts ...ConsumerOption) jetstream.ConsumerConfig {
+	o := consumerOptions{
+		config: jetstream.ConsumerConfig{ //nolint: exhaustruct
+			DeliverPolicy: jetstream.DeliverAllPolicy,
+			AckPolicy:     jetstream.AckExplicitPolicy,
+		},
+		pool:            nil,
+		throttle:        nil,
+		deadLetterAfter: 0,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o.config
+}
+
+// WithDeliverPolicy sets where a new consumer starts in the stream: jetstream.DeliverAllPolicy (default),
+// DeliverNewPolicy, DeliverLastPolicy or DeliverLastPerSubjectPolicy.
+// DeliverLastPerSubjectPolicy requires WithFilterSubjects.
+// Use WithStartSequence or WithStartTime to start from a position.
+// The server rejects changing the deliver policy of an existing durable consumer.
+func WithDeliverPolicy(policy jetstream.DeliverPolicy) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.config.DeliverPolicy = policy
+		o.config.OptStartSeq = 0
+		o.config.OptStartTime = nil
+	}
+}
+
+// WithStartSequence starts a new consumer at the stream sequence seq.
+func WithStartSequence(seq uint64) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.config.DeliverPolicy = jetstream.DeliverByStartSequencePolicy
+		o.config.OptStartSeq = seq
+		o.config.OptStartTime = nil
+	}
+}
+
+// WithStartTime starts a new consumer at the first message stored at or after start.
+func WithStartTime(start time.Time) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.config.DeliverPolicy = jetstream.DeliverByStartTimePolicy
+		o.config.OptStartSeq = 0
+		o.config.OptStartTime = &start
+	}
+}
+
+// WithFilterSubjects restricts the consumer to messages matching any of subjects, wildcards allowed.
+func WithFilterSubjects(subjects ...string) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.config.FilterSubject = ""
+		o.config.FilterSubjects = nil
+
+		if len(subjects) == 1 {
+			o.config.FilterSubject = subjects[0]
+		} else {
+			o.config.FilterSubjects = subjects
+		}
+	}
+}
+
+// WithAckWait sets how long the server waits for an ack before redelivering a message, DefaultAckWait by default.
+func WithAckWait(d time.Duration) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.config.AckWait = d
+	}
+}
+
+// WithAckBackOff redelivers messages that were not acknowledged in time after the given delays,
+// the last one repeating, instead of after every AckWait. The server waits delays[0] for the first ack,
+// so every delay must be at least the AckWait and there must be fewer delays than MaxDeliver.
+func WithAckBackOff(delays ...time.Duration) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.config.BackOff = delays
+	}
+}
+
+// WithMaxDeliver sets the maximum number of deliveries of a message, -1 for unlimited.
+func WithMaxDeliver(n int) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.config.MaxDeliver = n
+	}
+}
+
+// WithMaxAckPending bounds the messages delivered but not yet acknowledged, -1 for unlimited.
+// WithWorkerPool sets it from MaxInFlight, so with a pool configure MaxInFlight instead; a differing value is rejected.
+func WithMaxAckPending(n int) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.config.MaxAckPending = n
+	}
+}
+
+// WithReplayPolicy sets the replay rate: jetstream.ReplayInstantPolicy (default) delivers stored messages
+// as fast as possible, ReplayOriginalPolicy at the rate they were published.
+func WithReplayPolicy(policy jetstream.ReplayPolicy) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.config.ReplayPolicy = policy
+	}
+}
+
+// WithEphemeral creates an ephemeral consumer instead of a durable one. It keeps its name
+// but is deleted by the server once inactive for the inactive threshold, see WithInactiveThreshold.
+func WithEphemeral() ConsumerOption {
+	return func(o *consumerOptions) {
+		o.config.Durable = ""
+	}
+}
+
+// WithInactiveThreshold sets how long the consumer may go without pull requests before the server deletes it,
+// twice the ReconnectWait by default. Zero disables it for durable consumers.
+func WithInactiveThreshold(d time.Duration) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.config.InactiveThreshold = d
+	}
+}
+
+// WithRequestLimits sets the maximum number of messages and bytes of a single pull request,
+// DefaultMaxRequestBatch and DefaultMaxRequestMaxBytes by default. Pull batches never exceed batch.
+func WithRequestLimits(batch, maxBytes int) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.config.MaxRequestBatch = batch
+		o.config.MaxRequestMaxBytes = maxBytes
+	}
+}
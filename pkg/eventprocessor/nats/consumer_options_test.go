@@ -0,0 +1,133 @@
+package nats_test
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumerOptions(t *testing.T) { //nolint: funlen
+	t.Parallel()
+
+	cfg := natstest.NewConfig(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+		Name:     "TEST_CONSUMER_OPTIONS",
+		Subjects: []string{"test.options.>"},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close(context.Background()) })
+
+	// Stream sequences 1-3 are on subject a, 4-5 on subject b
+	for _, subject := range []string{"a", "a", "a", "b", "b"} {
+		require.NoError(t, client.PublishToStream(ctx, "test.options."+subject, []byte(subject)))
+	}
+
+	tests := []struct {
+		name string
+		opts []nats.ConsumerOption
+		want []uint64
+	}{
+		{name: "default", opts: nil, want: []uint64{1, 2, 3, 4, 5}},
+		{name: "last", opts: []nats.ConsumerOption{nats.WithDeliverPolicy(jetstream.DeliverLastPolicy)}, want: []uint64{5}},
+		{
+			name: "last-per-subject",
+			opts: []nats.ConsumerOption{
+				nats.WithDeliverPolicy(jetstream.DeliverLastPerSubjectPolicy),
+				nats.WithFilterSubjects("test.options.>"),
+			},
+			want: []uint64{3, 5},
+		},
+		{name: "start-sequence", opts: []nats.ConsumerOption{nats.WithStartSequence(4)}, want: []uint64{4, 5}},
+		{name: "filter", opts: []nats.ConsumerOption{nats.WithFilterSubjects("test.options.b")}, want: []uint64{4, 5}},
+		{
+			name: "filters",
+			opts: []nats.ConsumerOption{
+				nats.WithFilterSubjects("test.options.a", "test.options.b"),
+				nats.WithStartSequence(3),
+			},
+			want: []uint64{3, 4, 5},
+		},
+		{
+			name: "ephemeral",
+			opts: []nats.ConsumerOption{nats.WithEphemeral(), nats.WithRequestLimits(2, 1024)},
+			want: []uint64{1, 2, 3, 4, 5},
+		},
+	}
+	for _, tt := range tests {
+		var (
+			mu  sync.Mutex
+			got []uint64
+		)
+
+		cc, err := client.CreateConsumer(ctx, tt.name, func(_ context.Context, msg *nats.Message) nats.Result {
+			mu.Lock()
+			defer mu.Unlock()
+
+			got = append(got, msg.Metadata.Sequence.Stream)
+
+			return nats.Ack()
+		}, tt.opts...)
+		require.NoError(t, err, tt.name)
+
+		assert.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return slices.Equal(tt.want, got)
+		}, testTimeout, 10*time.Millisecond, tt.name)
+		cc.Stop()
+	}
+
+	nc, err := natsgo.Connect(cfg.URL)
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+
+	js, err := jetstream.New(nc)
+	require.NoError(t, err)
+
+	start := time.Now().Add(-time.Hour).UTC()
+	cc, err := client.CreateConsumer(ctx, "configured", func(context.Context, *nats.Message) nats.Result {
+		return nats.Ack()
+	},
+		nats.WithStartTime(start),
+		nats.WithAckWait(time.Minute),
+		nats.WithMaxDeliver(3),
+		nats.WithMaxAckPending(10),
+		nats.WithReplayPolicy(jetstream.ReplayOriginalPolicy),
+		nats.WithInactiveThreshold(time.Hour),
+		nats.WithEphemeral(),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cc.Stop)
+
+	consumer, err := js.Consumer(ctx, "TEST_CONSUMER_OPTIONS", "configured")
+	require.NoError(t, err)
+
+	config := consumer.CachedInfo().Config
+	assert.Equal(t, jetstream.DeliverByStartTimePolicy, config.DeliverPolicy)
+	require.NotNil(t, config.OptStartTime)
+	assert.True(t, start.Equal(*config.OptStartTime))
+	assert.Equal(t, time.Minute, config.AckWait)
+	assert.Equal(t, 3, config.MaxDeliver)
+	assert.Equal(t, 10, config.MaxAckPending)
+	assert.Equal(t, jetstream.ReplayOriginalPolicy, config.ReplayPolicy)
+	assert.Equal(t, time.Hour, config.InactiveThreshold)
+	assert.Empty(t, config.Durable)
+	assert.Equal(t, jetstream.AckExplicitPolicy, config.AckPolicy)
+
+	durable, err := js.Consumer(ctx, "TEST_CONSUMER_OPTIONS", "default")
+	require.NoError(t, err)
+	assert.Equal(t, "default", durable.CachedInfo().Config.Durable)
+}
@@ -2,81 +2,231 @@ package nats
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 
+	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
+	"github.com/nats-io/nuid"
 	"go.uber.org/zap"
 )
 
+// MsgIDFunc derives the Nats-Msg-Id used by the server to detect duplicate publishes.
+type MsgIDFunc func(topic string, data []byte) string
+
+// DedupOption configures a DedupJetStreamClient.
+type DedupOption func(*DedupJetStreamClient)
+
+// WithMsgIDFunc sets the function used to derive message IDs in PublishToStream and Publish; it is required.
+// Key deduplication on a business identifier carried in the payload, use ContentHashMsgID to drop
+// identical payloads, or RandomMsgID to only drop retries of the same publish.
+func WithMsgIDFunc(fn MsgIDFunc) DedupOption {
+	return func(c *DedupJetStreamClient) {
+		if fn != nil {
+			c.msgID = fn
+		}
+	}
+}
+
+// WithJetStreamOptions applies opts to the underlying JetStream client, for example WithStreamOwnership.
+func WithJetStreamOptions(opts ...JetStreamOption) DedupOption {
+	return func(c *DedupJetStreamClient) {
+		c.streamOpts = append(c.streamOpts, opts...)
+	}
+}
+
+// WithIdempotencyStore sets the store used by DeduplicateConsumer to remember processed message IDs.
+// Use a KVStore or FileStore to skip redeliveries across restarts.
+func WithIdempotencyStore(store IdempotencyStore) DedupOption {
+	return func(c *DedupJetStreamClient) {
+		if store != nil {
+			c.store = store
+		}
+	}
+}
+
+// RandomMsgID returns a unique message ID for every publish. Only retries of the same publish are
+// deduplicated: two publishes of the same logical message never collide, so it offers no dedupe across
+// publishes. Use it for messages without a natural identifier, and PublishWithID or PublishEvent for the others.
+func RandomMsgID(string, []byte) string {
+	return nuid.Next()
+}
+
+// ContentHashMsgID derives a message ID from the SHA-256 hash of topic and data,
+// so identical payloads published to the same subject within the Duplicates window are dropped.
+// Use it with WithMsgIDFunc only when repeating a payload never carries meaning:
+// repeated counters, heartbeats or commands are otherwise silently lost.
+func ContentHashMsgID(topic string, data []byte) string {
+	h := sha256.New()
+	h.Write([]byte(topic))
+	h.Write([]byte{0})
+	h.Write(data)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // DedupJetStreamClient implements NATS JetStream with deduplication.
+// Every publish carries a Nats-Msg-Id so the stream's Duplicates window drops repeated messages.
 type DedupJetStreamClient struct {
 	*JetStreamClient
 	config *Config
 	logger *zap.Logger
+	msgID  MsgIDFunc
+	store  IdempotencyStore
+	// streamOpts are only used while constructing the client
+	streamOpts []JetStreamOption
 }
 
 // NewDedupJetStreamClient creates a new NATS JetStream client with deduplication.
-func NewDedupJetStreamClient(cfg *Config, streamConfig jetstream.StreamConfig) (*DedupJetStreamClient, error) {
+// WithMsgIDFunc is required to choose how PublishToStream and Publish derive message IDs,
+// since deduplication is only as good as those IDs. Processed IDs are kept in a MemoryStore
+// unless overridden with WithIdempotencyStore.
+func NewDedupJetStreamClient(
+	cfg *Config,
+	streamConfig jetstream.StreamConfig,
+	opts ...DedupOption,
+) (*DedupJetStreamClient, error) {
 	if cfg == nil {
 		return nil, ErrInvalidConfig
 	}
 
-	js, err := NewJetStreamClient(cfg, streamConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create JetStream client: %w", err)
-	}
-
 	if cfg.Logger == nil {
 		return nil, fmt.Errorf("logger is required in config: %w", ErrInvalidConfig)
 	}
 
-	return &DedupJetStreamClient{
-		JetStreamClient: js,
+	client := &DedupJetStreamClient{
+		JetStreamClient: nil,
 		config:          cfg,
 		logger:          cfg.Logger,
-	}, nil
+		msgID:           nil,
+		store:           NewMemoryStore(DefaultIdempotencyCapacity, DefaultIdempotencyTTL),
+		streamOpts:      nil,
+	}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	if client.msgID == nil {
+		return nil, fmt.Errorf("a message ID function is required, see WithMsgIDFunc: %w", ErrInvalidConfig)
+	}
+
+	js, err := newJetStreamClient(cfg, streamConfig, clientDedupe, client.streamOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JetStream client: %w", err)
+	}
+
+	client.JetStreamClient = js
+	client.streamOpts = nil
+
+	return client, nil
 }
 
-// PublishToStream publishes a message to a stream.
+// PublishToStream publishes a message to a stream with a derived message ID.
+// Duplicates dropped by the server are logged and not reported as errors.
 func (c *DedupJetStreamClient) PublishToStream(ctx context.Context, topic string, data []byte) error {
+	ack, err := c.Publish(ctx, topic, data)
+	if err != nil {
+		return err
+	}
+
+	c.logDuplicate(topic, ack)
+
+	return nil
+}
+
+// PublishEvent publishes event to a stream using the event ID as the message ID,
+// so republishing the same event within the Duplicates window is dropped by the server.
+func (c *DedupJetStreamClient) PublishEvent(ctx context.Context, topic string, event Event) error {
 	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("context error: %w", err)
 	}
 
-	_, err := c.js.Publish(ctx, topic, data)
+	msg, err := eventMsg(topic, event)
+	if err != nil {
+		return err
+	}
+
+	ack, err := c.publishMsg(ctx, msg, jetstream.WithMsgID(event.ID))
 	if err != nil {
-		return fmt.Errorf("failed to publish message: %w", err)
+		return err
 	}
 
+	c.logDuplicate(topic, ack)
+
 	return nil
 }
 
-// DeduplicateConsumer creates a durable pull consumer with deduplication for the stream.
-// Returns a ConsumeContext that must be used to receive messages.
-func (c *DedupJetStreamClient) DeduplicateConsumer(ctx context.Context, name string) (jetstream.ConsumeContext, error) { //nolint: ireturn
+// logDuplicate logs publishes the server dropped as duplicates.
+func (c *DedupJetStreamClient) logDuplicate(topic string, ack *jetstream.PubAck) {
+	if ack.Duplicate {
+		c.logger.Info("duplicate message dropped by server",
+			zap.String("stream", ack.Stream),
+			zap.Uint64("sequence", ack.Sequence),
+			zap.String("subject", topic),
+		)
+	}
+}
+
+// Publish publishes a message with an ID derived by the client's MsgIDFunc.
+// The returned PubAck reports Duplicate when the server already stored a message with the same ID.
+func (c *DedupJetStreamClient) Publish(ctx context.Context, topic string, data []byte) (*jetstream.PubAck, error) {
+	return c.PublishWithID(ctx, topic, c.msgID(topic, data), data)
+}
+
+// PublishWithID publishes a message using the caller-supplied message ID.
+// The returned PubAck reports Duplicate when the server already stored a message with the same ID.
+func (c *DedupJetStreamClient) PublishWithID(
+	ctx context.Context,
+	topic string,
+	msgID string,
+	data []byte,
+) (*jetstream.PubAck, error) {
 	if err := ctx.Err(); err != nil {
 		return nil, fmt.Errorf("context error: %w", err)
 	}
 
+	if msgID == "" {
+		return nil, ErrEmptyMsgID
+	}
+
+	return c.publishMsg(ctx, &nats.Msg{Subject: topic, Data: data}, jetstream.WithMsgID(msgID)) //nolint: exhaustruct
+}
+
+// DeduplicateConsumer creates a pull consumer with deduplication for the stream, durable unless WithEphemeral is given.
+// Besides the server-side publish dedupe window, handler runs at most once per Nats-Msg-Id:
+// IDs of acked messages are recorded in the client's IdempotencyStore and redeliveries of them are acked
+// without calling handler. Terminated messages are not recorded, so dead letters replayed with
+// DeadLetterQueue.Replay are handled again. Messages without the header always reach handler.
+// opts customize the consumer configuration, for example WithRetryPolicy.
+// Returns a ConsumeContext that must be stopped to end consumption.
+func (c *DedupJetStreamClient) DeduplicateConsumer( //nolint: ireturn
+	ctx context.Context,
+	name string,
+	handler Handler,
+	opts ...ConsumerOption,
+) (jetstream.ConsumeContext, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("context error: %w", err)
+	}
+
+	if handler == nil {
+		return nil, fmt.Errorf("handler is required: %w", ErrInvalidConfig)
+	}
+
 	c.logger.Info("creating deduplicated consumer",
 		zap.String("stream", c.streamConfig.Name),
 		zap.String("name", name),
 		zap.Duration("dedupe_window", c.streamConfig.Duplicates),
 	)
 
-	consumerConfig := jetstream.ConsumerConfig{ //nolint: exhaustruct
-		Name:               name,
-		Durable:            name,
-		DeliverPolicy:      jetstream.DeliverAllPolicy,
-		AckPolicy:          jetstream.AckExplicitPolicy,
-		Description:        fmt.Sprintf("Deduplicated consumer %s for stream %s", name, c.streamConfig.Name),
-		MaxRequestBatch:    DefaultMaxRequestBatch,
-		MaxRequestExpires:  c.config.ReconnectWait,
-		MaxRequestMaxBytes: DefaultMaxRequestMaxBytes,
-		InactiveThreshold:  c.config.ReconnectWait * DefaultInactiveThresholdMultiplier,
+	consumerOpts, err := c.consumerOptions(name, "Deduplicated consumer", opts)
+	if err != nil {
+		return nil, err
 	}
 
-	consumer, err := c.stream.CreateOrUpdateConsumer(ctx, consumerConfig)
+	consumer, err := c.stream.CreateOrUpdateConsumer(ctx, consumerOpts.config)
 	if err != nil {
 		c.logger.Error("failed to create consumer",
 			zap.String("name", name),
@@ -90,45 +240,5 @@ func (c *DedupJetStreamClient) DeduplicateConsumer(ctx context.Context, name str
 		zap.String("name", name),
 	)
 
-	// Create consume context with options
-	cc, err := consumer.Consume(func(msg jetstream.Msg) {
-		defer func() {
-			if err := msg.Ack(); err != nil {
-				c.logger.Error("failed to acknowledge message", zap.Error(err))
-			}
-		}()
-		meta, err := msg.Metadata()
-		if err != nil {
-			c.logger.Error("failed to get metadata",
-				zap.Error(err),
-				zap.String("subject", msg.Subject()),
-			)
-
-			return
-		}
-
-		c.logger.Info("received deduplicated message",
-			zap.Uint64("sequence", meta.Sequence.Consumer),
-			zap.String("subject", msg.Subject()),
-			zap.String("consumer", name),
-		)
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to create consume context: %w", err)
-	}
-
-	return cc, nil
-}
-
-// Close closes the NATS connection and cleans up resources.
-func (c *DedupJetStreamClient) Close(ctx context.Context) error {
-	if err := ctx.Err(); err != nil {
-		return fmt.Errorf("context error: %w", err)
-	}
-
-	if err := c.js.DeleteStream(ctx, c.streamConfig.Name); err != nil {
-		c.logger.Error("failed to delete stream", zap.Error(err))
-	}
-
-	return c.JetStreamClient.Close(ctx)
+	return c.consume(consumer, idempotent(c.logger, c.store, c.config.ReconnectWait, handler), consumerOpts)
 }
@@ -3,16 +3,16 @@ package nats_test
 import (
 	"context"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"go.uber.org/zap"
-	"go.uber.org/zap/zaptest"
-	"go.uber.org/zap/zaptest/observer"
 )
 
 const (
@@ -27,18 +27,7 @@ func TestDeDupeJetStreamClient(t *testing.T) { //nolint: gocognit, funlen
 		messageCount = 100
 	)
 
-	// Create test logger
-	logger := zaptest.NewLogger(t)
-	_ = logger.Sync() // Ignore sync errors in tests
-
-	cfg := &nats.Config{
-		URL:           "nats://nats:4222",
-		Token:         "test-token",
-		CredsFile:     "",
-		MaxReconnects: nats.DefaultMaxReconnects,
-		ReconnectWait: time.Second * nats.DefaultReconnectWaitSeconds,
-		Logger:        logger,
-	}
+	cfg := natstest.NewConfig(t)
 
 	t.Run("NewDeDupeJetStreamClient", func(t *testing.T) {
 		t.Parallel()
@@ -46,26 +35,27 @@ func TestDeDupeJetStreamClient(t *testing.T) { //nolint: gocognit, funlen
 			Name:       "TEST_DEDUPE_1",
 			Subjects:   []string{"test.dedupe1.>"},
 			Duplicates: time.Minute,
-		})
+		}, nats.WithMsgIDFunc(nats.RandomMsgID))
 		require.NoError(t, err)
 		require.NotNil(t, client)
 		defer client.Close(context.Background())
+
+		// The message ID function decides what is deduplicated, so it must be chosen explicitly
+		_, err = nats.NewDedupJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:     "TEST_DEDUPE_1",
+			Subjects: []string{"test.dedupe1.>"},
+		})
+		require.ErrorIs(t, err, nats.ErrInvalidConfig)
 	})
 
 	t.Run("ConsumerTest", func(t *testing.T) {
 		t.Parallel()
 
-		// Create test logger with observer for assertions
-		core, logs := observer.New(zap.InfoLevel)
-		logger := zap.New(core)
-		testCfg := *cfg
-		testCfg.Logger = logger
-
-		client, err := nats.NewDedupJetStreamClient(&testCfg, jetstream.StreamConfig{ //nolint: exhaustruct
+		client, err := nats.NewDedupJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
 			Name:       "TEST_DEDUPE_2",
 			Subjects:   []string{"test.dedupe2.>"},
 			Duplicates: time.Minute,
-		})
+		}, nats.WithMsgIDFunc(nats.RandomMsgID))
 		require.NoError(t, err)
 		defer client.Close(context.Background())
 
@@ -89,7 +79,15 @@ func TestDeDupeJetStreamClient(t *testing.T) { //nolint: gocognit, funlen
 		}()
 
 		// Create consumer and start consuming
-		cc, err := client.DeduplicateConsumer(ctx, "test-consumer")
+		var lastSeq, missingIDs atomic.Uint64
+		cc, err := client.DeduplicateConsumer(ctx, "test-consumer", func(_ context.Context, msg *nats.Message) nats.Result {
+			if msg.Headers.Get(jetstream.MsgIDHeader) == "" {
+				missingIDs.Add(1)
+			}
+			lastSeq.Store(msg.Metadata.Sequence.Consumer)
+
+			return nats.Ack()
+		})
 		require.NoError(t, err)
 		require.NotNil(t, cc)
 		defer cc.Stop()
@@ -99,29 +97,186 @@ func TestDeDupeJetStreamClient(t *testing.T) { //nolint: gocognit, funlen
 		case <-ctx.Done():
 			t.Fatal("context deadline exceeded")
 		case <-done:
-			// Check logs until we find the last message
 			require.Eventually(t, func() bool {
-				entries := logs.All()
-				for _, entry := range entries {
-					if seq, ok := entry.ContextMap()["sequence"].(uint64); ok && seq == messageCount {
-						return true
-					}
-				}
-
-				return false
+				return lastSeq.Load() == messageCount
 			}, testTimeout, 100*time.Millisecond, "did not receive all messages or stream is already filled")
+		}
+		assert.Zero(t, missingIDs.Load(), "messages without a message ID")
+	})
 
-			// Verify log messages contain expected fields
-			logEntries := logs.All()
-			require.NotEmpty(t, logEntries, "expected some log messages")
+	t.Run("SkipsProcessedIDs", func(t *testing.T) {
+		t.Parallel()
+		store := nats.NewMemoryStore(10, time.Minute)
+		client, err := nats.NewDedupJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:       "TEST_DEDUPE_6",
+			Subjects:   []string{"test.dedupe6.>"},
+			Duplicates: 100 * time.Millisecond,
+		}, nats.WithIdempotencyStore(store), nats.WithMsgIDFunc(nats.RandomMsgID))
+		require.NoError(t, err)
+		defer client.Close(context.Background())
 
-			for _, entry := range logEntries {
-				if entry.Message == "received deduplicated message" {
-					fields := entry.ContextMap()
-					require.Contains(t, fields, "sequence")
-					require.Contains(t, fields, "subject")
-				}
-			}
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		// Outside the server dedupe window both copies are stored
+		_, err = client.PublishWithID(ctx, "test.dedupe6.orders", "order-1", []byte("first"))
+		require.NoError(t, err)
+		time.Sleep(300 * time.Millisecond)
+		ack, err := client.PublishWithID(ctx, "test.dedupe6.orders", "order-1", []byte("second"))
+		require.NoError(t, err)
+		require.False(t, ack.Duplicate)
+		_, err = client.PublishWithID(ctx, "test.dedupe6.orders", "order-2", []byte("third"))
+		require.NoError(t, err)
+
+		var (
+			mu      sync.Mutex
+			handled []string
+		)
+		cc, err := client.DeduplicateConsumer(ctx, "test-idempotent", func(_ context.Context, msg *nats.Message) nats.Result {
+			mu.Lock()
+			defer mu.Unlock()
+			handled = append(handled, string(msg.Data))
+
+			return nats.Ack()
+		})
+		require.NoError(t, err)
+		defer cc.Stop()
+
+		// Messages are handled in order, so the redelivered ID was skipped once the last one arrives
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return len(handled) > 0 && handled[len(handled)-1] == "third"
+		}, testTimeout, 50*time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"first", "third"}, handled)
+		assert.Equal(t, 2, store.Len())
+	})
+
+	t.Run("HandlesRepeatsWithoutID", func(t *testing.T) {
+		t.Parallel()
+		store := nats.NewMemoryStore(10, time.Minute)
+		client, err := nats.NewDedupJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:       "TEST_DEDUPE_8",
+			Subjects:   []string{"test.dedupe8.>"},
+			Duplicates: 100 * time.Millisecond,
+		}, nats.WithIdempotencyStore(store), nats.WithMsgIDFunc(nats.RandomMsgID))
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		// The plain JetStream publish sets no Nats-Msg-Id, so repeated payloads are distinct messages
+		require.NoError(t, client.JetStreamClient.PublishToStream(ctx, "test.dedupe8.ping", []byte("ping")))
+		time.Sleep(200 * time.Millisecond)
+		require.NoError(t, client.JetStreamClient.PublishToStream(ctx, "test.dedupe8.ping", []byte("ping")))
+
+		var handled atomic.Int32
+		cc, err := client.DeduplicateConsumer(ctx, "test-no-id", func(context.Context, *nats.Message) nats.Result {
+			handled.Add(1)
+
+			return nats.Ack()
+		})
+		require.NoError(t, err)
+		defer cc.Stop()
+
+		require.Eventually(t, func() bool {
+			return handled.Load() == 2
+		}, testTimeout, 50*time.Millisecond)
+		assert.Equal(t, 0, store.Len())
+	})
+
+	t.Run("DuplicatePublish", func(t *testing.T) {
+		t.Parallel()
+		client, err := nats.NewDedupJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:       "TEST_DEDUPE_4",
+			Subjects:   []string{"test.dedupe4.>"},
+			Duplicates: time.Minute,
+		}, nats.WithMsgIDFunc(nats.ContentHashMsgID))
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		ctx := context.Background()
+
+		first, err := client.Publish(ctx, "test.dedupe4.a", []byte("payload"))
+		require.NoError(t, err)
+		assert.False(t, first.Duplicate)
+
+		second, err := client.Publish(ctx, "test.dedupe4.a", []byte("payload"))
+		require.NoError(t, err)
+		assert.True(t, second.Duplicate)
+		assert.Equal(t, first.Sequence, second.Sequence)
+
+		// Same payload on another subject is a distinct message
+		other, err := client.Publish(ctx, "test.dedupe4.b", []byte("payload"))
+		require.NoError(t, err)
+		assert.False(t, other.Duplicate)
+
+		// Caller-supplied IDs take precedence over the content hash
+		byID, err := client.PublishWithID(ctx, "test.dedupe4.a", "order-1", []byte("v1"))
+		require.NoError(t, err)
+		assert.False(t, byID.Duplicate)
+		byID, err = client.PublishWithID(ctx, "test.dedupe4.a", "order-1", []byte("v2"))
+		require.NoError(t, err)
+		assert.True(t, byID.Duplicate)
+
+		require.NoError(t, client.PublishToStream(ctx, "test.dedupe4.a", []byte("payload")))
+
+		_, err = client.PublishWithID(ctx, "test.dedupe4.a", "", []byte("payload"))
+		assert.ErrorIs(t, err, nats.ErrEmptyMsgID)
+	})
+
+	t.Run("RandomMsgID", func(t *testing.T) {
+		t.Parallel()
+		client, err := nats.NewDedupJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:       "TEST_DEDUPE_7",
+			Subjects:   []string{"test.dedupe7.>"},
+			Duplicates: time.Minute,
+		}, nats.WithMsgIDFunc(nats.RandomMsgID))
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		ctx := context.Background()
+
+		// Repeated payloads such as heartbeats are distinct messages
+		first, err := client.Publish(ctx, "test.dedupe7.heartbeat", []byte("ping"))
+		require.NoError(t, err)
+		second, err := client.Publish(ctx, "test.dedupe7.heartbeat", []byte("ping"))
+		require.NoError(t, err)
+		assert.False(t, second.Duplicate)
+		assert.Equal(t, first.Sequence+1, second.Sequence)
+	})
+
+	t.Run("CustomMsgIDFunc", func(t *testing.T) {
+		t.Parallel()
+		keyByPrefix := func(_ string, data []byte) string {
+			return string(data[:3])
+		}
+		client, err := nats.NewDedupJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:       "TEST_DEDUPE_5",
+			Subjects:   []string{"test.dedupe5.>"},
+			Duplicates: time.Minute,
+		}, nats.WithMsgIDFunc(keyByPrefix))
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		ctx := context.Background()
+		tests := []struct {
+			data      string
+			duplicate bool
+		}{
+			{data: "id1-first", duplicate: false},
+			{data: "id1-second", duplicate: true},
+			{data: "id2-first", duplicate: false},
+		}
+		for _, tt := range tests {
+			ack, err := client.Publish(ctx, "test.dedupe5.events", []byte(tt.data))
+			require.NoError(t, err)
+			assert.Equal(t, tt.duplicate, ack.Duplicate, tt.data)
 		}
 	})
 
@@ -132,7 +287,7 @@ func TestDeDupeJetStreamClient(t *testing.T) { //nolint: gocognit, funlen
 		_, err := nats.NewDedupJetStreamClient(&invalidCfg, jetstream.StreamConfig{ //nolint: exhaustruct
 			Name:     "TEST_DEDUPE_3",
 			Subjects: []string{"test.dedupe3.>"},
-		})
+		}, nats.WithMsgIDFunc(nats.RandomMsgID))
 		assert.Error(t, err)
 	})
 }
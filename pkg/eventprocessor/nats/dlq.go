@@ -0,0 +1,276 @@
+package nats
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+)
+
+// Headers describing why and where from a message was dead-lettered.
+const (
+	// HeaderDLQOriginSubject is the subject the message was originally published to.
+	HeaderDLQOriginSubject = "Dlq-Origin-Subject"
+	// HeaderDLQOriginStream is the stream the message was consumed from.
+	HeaderDLQOriginStream = "Dlq-Origin-Stream"
+	// HeaderDLQStreamSequence is the sequence of the message in the origin stream.
+	HeaderDLQStreamSequence = "Dlq-Stream-Sequence"
+	// HeaderDLQDeliveryCount is how many times the message was delivered before it was dead-lettered.
+	HeaderDLQDeliveryCount = "Dlq-Delivery-Count"
+	// HeaderDLQLastError is the last error returned by the handler.
+	HeaderDLQLastError = "Dlq-Last-Error"
+	// HeaderDLQOriginMsgID is the Nats-Msg-Id the message was originally published with.
+	HeaderDLQOriginMsgID = "Dlq-Origin-Msg-Id"
+)
+
+// DeadLetter is a message stored in a dead-letter queue.
+type DeadLetter struct {
+	// Sequence is the sequence of the entry in the dead-letter stream
+	Sequence uint64
+	// Subject is the subject the message was originally published to
+	Subject string
+	// Stream is the stream the message was consumed from
+	Stream string
+	// StreamSequence is the sequence of the message in the origin stream
+	StreamSequence uint64
+	// Deliveries is how many times the message was delivered before it was dead-lettered
+	Deliveries uint64
+	// LastError is the last error returned by the handler
+	LastError string
+	// FailedAt is when the message was dead-lettered
+	FailedAt time.Time
+	// MsgID is the Nats-Msg-Id the message was originally published with, empty if it had none
+	MsgID string
+	// Data is the original message payload
+	Data []byte
+	// Headers are the original message headers
+	Headers nats.Header
+}
+
+// DeadLetterQueue republishes messages that keep failing to a dead-letter stream
+// and allows listing and replaying them.
+type DeadLetterQueue struct {
+	client     *JetStreamClient
+	stream     jetstream.Stream
+	subject    string
+	maxDeliver uint64
+	logger     *zap.Logger
+}
+
+// NewDeadLetterQueue creates or updates a stream named streamName capturing subject and
+// returns a queue dead-lettering messages after maxDeliver failed deliveries.
+// subject must not overlap the subjects of the streams being consumed.
+func NewDeadLetterQueue(
+	ctx context.Context,
+	client *JetStreamClient,
+	streamName string,
+	subject string,
+	maxDeliver int,
+) (*DeadLetterQueue, error) {
+	if client == nil || streamName == "" || subject == "" || maxDeliver < 1 {
+		return nil, ErrInvalidConfig
+	}
+
+	stream, err := client.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{ //nolint: exhaustruct
+		Name:        streamName,
+		Description: "Dead-lettered messages",
+		Subjects:    []string{subject},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter stream: %w", err)
+	}
+
+	return &DeadLetterQueue{
+		client:     client,
+		stream:     stream,
+		subject:    subject,
+		maxDeliver: uint64(maxDeliver),
+		logger:     client.logger,
+	}, nil
+}
+
+// ConsumerOption sets the consumer MaxDeliver one above the dead-letter threshold, so the server keeps
+// redelivering until Wrap dead-letters the message, including messages whose deliveries all ran out
+// on ack timeouts. Consumers with a later WithMaxDeliver or WithRetryPolicy at or below the threshold
+// are rejected with ErrInvalidConfig instead of silently dropping messages.
+func (q *DeadLetterQueue) ConsumerOption() ConsumerOption {
+	return func(o *consumerOptions) {
+		o.deadLetterAfter = int(q.maxDeliver)
+		o.config.MaxDeliver = int(q.maxDeliver) + 1
+	}
+}
+
+// Wrap returns a Handler that dead-letters messages handler keeps failing on.
+// A message is dead-lettered and terminated when handler does not ack its maxDeliver-th delivery,
+// or when handler terminates it with an error. Deliveries past maxDeliver, left by handlers that
+// timed out, are dead-lettered without calling handler; the consumer needs ConsumerOption to get them.
+// If dead-lettering fails the message is nak'd instead.
+func (q *DeadLetterQueue) Wrap(handler Handler) Handler {
+	return func(ctx context.Context, msg *Message) Result {
+		if msg.Metadata.NumDelivered > q.maxDeliver {
+			return q.deadLetter(ctx, msg, Nak(0, nil), ErrMaxDeliveries)
+		}
+
+		res := handler(ctx, msg)
+
+		// In progress is not a result yet: the delivery is finished or times out later
+		exhausted := res.Action != ActionAck && res.Action != ActionInProgress &&
+			msg.Metadata.NumDelivered >= q.maxDeliver
+		rejected := res.Action == ActionTerm && res.Err != nil
+
+		if !exhausted && !rejected {
+			return res
+		}
+
+		return q.deadLetter(ctx, msg, res, cmp.Or(res.Err, ErrMaxDeliveries))
+	}
+}
+
+// deadLetter publishes msg to the queue and terminates it, or naks it when that fails.
+func (q *DeadLetterQueue) deadLetter(ctx context.Context, msg *Message, res Result, cause error) Result {
+	if err := q.publish(ctx, msg, cause); err != nil {
+		return Nak(res.Delay, errors.Join(cause, err))
+	}
+
+	q.logger.Warn("message dead-lettered",
+		zap.String("subject", msg.Subject),
+		zap.Uint64("stream_sequence", msg.Metadata.Sequence.Stream),
+		zap.Uint64("deliveries", msg.Metadata.NumDelivered),
+		zap.Error(cause),
+	)
+
+	return Term(fmt.Errorf("dead-lettered after %d deliveries: %w", msg.Metadata.NumDelivered, cause))
+}
+
+// publish stores msg in the dead-letter stream with headers describing the failure.
+func (q *DeadLetterQueue) publish(ctx context.Context, msg *Message, cause error) error {
+	header := nats.Header{}
+
+	for key, values := range msg.Headers {
+		if key != jetstream.MsgIDHeader {
+			header[key] = append([]string(nil), values...)
+		}
+	}
+
+	if id := msg.Headers.Get(jetstream.MsgIDHeader); id != "" {
+		header.Set(HeaderDLQOriginMsgID, id)
+	}
+
+	header.Set(HeaderDLQOriginSubject, msg.Subject)
+	header.Set(HeaderDLQOriginStream, msg.Metadata.Stream)
+	header.Set(HeaderDLQStreamSequence, strconv.FormatUint(msg.Metadata.Sequence.Stream, 10))
+	header.Set(HeaderDLQDeliveryCount, strconv.FormatUint(msg.Metadata.NumDelivered, 10))
+
+	header.Set(HeaderDLQLastError, cause.Error())
+
+	out := &nats.Msg{Subject: q.subject, Header: header, Data: msg.Data} //nolint: exhaustruct
+	if _, err := q.client.js.PublishMsg(ctx, out); err != nil {
+		return fmt.Errorf("failed to publish to dead-letter queue: %w", err)
+	}
+
+	return nil
+}
+
+// List returns up to limit dead letters, oldest first. A non-positive limit returns all of them.
+func (q *DeadLetterQueue) List(ctx context.Context, limit int) ([]DeadLetter, error) {
+	info, err := q.stream.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead-letter stream info: %w", err)
+	}
+
+	var letters []DeadLetter
+
+	for seq := info.State.FirstSeq; seq <= info.State.LastSeq && info.State.Msgs > 0; seq++ {
+		if limit > 0 && len(letters) >= limit {
+			break
+		}
+
+		raw, err := q.stream.GetMsg(ctx, seq)
+		if errors.Is(err, jetstream.ErrMsgNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dead letter %d: %w", seq, err)
+		}
+
+		letters = append(letters, newDeadLetter(raw))
+	}
+
+	return letters, nil
+}
+
+// Replay republishes the dead letter with sequence seq to its origin subject and removes it from the queue.
+// The original Nats-Msg-Id is restored so consumer-side idempotency still applies to the replay.
+// If the origin stream still holds that ID in its duplicate window, which would drop the replay,
+// it is published again without the ID.
+func (q *DeadLetterQueue) Replay(ctx context.Context, seq uint64) error {
+	raw, err := q.stream.GetMsg(ctx, seq)
+	if err != nil {
+		return fmt.Errorf("failed to get dead letter %d: %w", seq, err)
+	}
+
+	letter := newDeadLetter(raw)
+
+	out := &nats.Msg{Subject: letter.Subject, Header: letter.Headers, Data: letter.Data} //nolint: exhaustruct
+	if letter.MsgID != "" {
+		out.Header.Set(jetstream.MsgIDHeader, letter.MsgID)
+	}
+
+	ack, err := q.client.js.PublishMsg(ctx, out)
+	if err == nil && ack.Duplicate {
+		q.logger.Warn("replaying dead letter without its message ID inside the duplicate window",
+			zap.Uint64("sequence", seq),
+			zap.String("msg_id", letter.MsgID),
+		)
+
+		out.Header.Del(jetstream.MsgIDHeader)
+		_, err = q.client.js.PublishMsg(ctx, out)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to replay dead letter %d: %w", seq, err)
+	}
+
+	if err := q.stream.DeleteMsg(ctx, seq); err != nil {
+		return fmt.Errorf("failed to remove replayed dead letter %d: %w", seq, err)
+	}
+
+	return nil
+}
+
+// newDeadLetter decodes a dead-letter stream message, separating DLQ headers from the original ones.
+func newDeadLetter(raw *jetstream.RawStreamMsg) DeadLetter {
+	headers := nats.Header{}
+
+	for key, values := range raw.Header {
+		switch key {
+		case HeaderDLQOriginSubject, HeaderDLQOriginStream, HeaderDLQStreamSequence,
+			HeaderDLQDeliveryCount, HeaderDLQLastError, HeaderDLQOriginMsgID:
+		default:
+			headers[key] = values
+		}
+	}
+
+	streamSeq, _ := strconv.ParseUint(raw.Header.Get(HeaderDLQStreamSequence), 10, 64)
+	deliveries, _ := strconv.ParseUint(raw.Header.Get(HeaderDLQDeliveryCount), 10, 64)
+
+	return DeadLetter{
+		Sequence:       raw.Sequence,
+		Subject:        raw.Header.Get(HeaderDLQOriginSubject),
+		Stream:         raw.Header.Get(HeaderDLQOriginStream),
+		StreamSequence: streamSeq,
+		Deliveries:     deliveries,
+		LastError:      raw.Header.Get(HeaderDLQLastError),
+		FailedAt:       raw.Time,
+		MsgID:          raw.Header.Get(HeaderDLQOriginMsgID),
+		Data:           raw.Data,
+		Headers:        headers,
+	}
+}
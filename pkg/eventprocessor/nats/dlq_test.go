@@ -0,0 +1,229 @@
+package nats_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadLetterQueue(t *testing.T) { //nolint: funlen
+	t.Parallel()
+
+	const maxDeliver = 3
+
+	cfg := natstest.NewConfig(t)
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		t.Parallel()
+		_, err := nats.NewDeadLetterQueue(context.Background(), nil, "DLQ", "dlq.events", maxDeliver)
+		assert.ErrorIs(t, err, nats.ErrInvalidConfig)
+	})
+
+	t.Run("DeadLetterAndReplay", func(t *testing.T) {
+		t.Parallel()
+
+		client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:     "TEST_DLQ_ORIGIN",
+			Subjects: []string{"test.dlq.>"},
+		})
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		dlq, err := nats.NewDeadLetterQueue(ctx, client, "TEST_DLQ", "dlq.test", maxDeliver)
+		require.NoError(t, err)
+
+		require.NoError(t, client.PublishToStream(ctx, "test.dlq.flaky", []byte("flaky")))
+		require.NoError(t, client.PublishToStream(ctx, "test.dlq.invalid", []byte("invalid")))
+
+		var (
+			healthy   atomic.Bool
+			recovered atomic.Value
+		)
+		errDownstream := errors.New("downstream unavailable")
+		cc, err := client.CreateConsumer(ctx, "test-dlq", dlq.Wrap(func(_ context.Context, msg *nats.Message) nats.Result {
+			switch {
+			case msg.Subject == "test.dlq.invalid":
+				return nats.Term(errors.New("invalid payload"))
+			case healthy.Load():
+				recovered.Store(string(msg.Data))
+
+				return nats.Ack()
+			default:
+				return nats.Nak(0, errDownstream)
+			}
+		}), dlq.ConsumerOption())
+		require.NoError(t, err)
+		defer cc.Stop()
+
+		var letters []nats.DeadLetter
+		require.Eventually(t, func() bool {
+			letters, err = dlq.List(ctx, 0)
+
+			return err == nil && len(letters) == 2
+		}, testTimeout, 50*time.Millisecond)
+
+		byError := map[string]nats.DeadLetter{}
+		for _, letter := range letters {
+			byError[letter.LastError] = letter
+		}
+
+		flaky := byError[errDownstream.Error()]
+		assert.Equal(t, "test.dlq.flaky", flaky.Subject)
+		assert.Equal(t, "TEST_DLQ_ORIGIN", flaky.Stream)
+		assert.Equal(t, uint64(1), flaky.StreamSequence)
+		assert.Equal(t, uint64(maxDeliver), flaky.Deliveries)
+		assert.Equal(t, []byte("flaky"), flaky.Data)
+		assert.NotContains(t, flaky.Headers, nats.HeaderDLQLastError)
+
+		invalid := byError["invalid payload"]
+		assert.Equal(t, "test.dlq.invalid", invalid.Subject)
+		assert.Equal(t, uint64(1), invalid.Deliveries)
+
+		limited, err := dlq.List(ctx, 1)
+		require.NoError(t, err)
+		assert.Len(t, limited, 1)
+
+		// Once the downstream recovers the replayed message is processed
+		healthy.Store(true)
+		require.NoError(t, dlq.Replay(ctx, flaky.Sequence))
+		require.Eventually(t, func() bool {
+			return recovered.Load() == "flaky"
+		}, testTimeout, 50*time.Millisecond)
+
+		letters, err = dlq.List(ctx, 0)
+		require.NoError(t, err)
+		require.Len(t, letters, 1)
+		assert.Equal(t, "test.dlq.invalid", letters[0].Subject)
+	})
+
+	t.Run("AckTimeouts", func(t *testing.T) {
+		t.Parallel()
+
+		client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:     "TEST_DLQ_TIMEOUT",
+			Subjects: []string{"test.dlqtimeout.>"},
+		})
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		dlq, err := nats.NewDeadLetterQueue(ctx, client, "TEST_DLQ_TIMEOUT_DLQ", "dlq.timeout", maxDeliver)
+		require.NoError(t, err)
+
+		// A consumer giving up before the queue would silently drop messages
+		_, err = client.CreateConsumer(ctx, "test-dlq-conflict", dlq.Wrap(func(context.Context, *nats.Message) nats.Result {
+			return nats.Ack()
+		}), dlq.ConsumerOption(), nats.WithMaxDeliver(maxDeliver))
+		require.ErrorIs(t, err, nats.ErrInvalidConfig)
+
+		require.NoError(t, client.PublishToStream(ctx, "test.dlqtimeout.hang", []byte("hang")))
+
+		// The handler never settles the message, so every delivery runs out on the ack wait
+		var handled atomic.Int64
+		cc, err := client.CreateConsumer(ctx, "test-dlq-timeout", dlq.Wrap(func(context.Context, *nats.Message) nats.Result {
+			handled.Add(1)
+
+			return nats.InProgress()
+		}), dlq.ConsumerOption(), nats.WithAckWait(100*time.Millisecond))
+		require.NoError(t, err)
+		defer cc.Stop()
+
+		var letters []nats.DeadLetter
+		require.Eventually(t, func() bool {
+			letters, err = dlq.List(ctx, 0)
+
+			return err == nil && len(letters) == 1
+		}, testTimeout, 50*time.Millisecond)
+		assert.Equal(t, uint64(maxDeliver+1), letters[0].Deliveries)
+		assert.Equal(t, nats.ErrMaxDeliveries.Error(), letters[0].LastError)
+		assert.Equal(t, int64(maxDeliver), handled.Load())
+	})
+
+	t.Run("ReplayWithDeduplicateConsumer", func(t *testing.T) {
+		t.Parallel()
+
+		const window = 200 * time.Millisecond
+
+		client, err := nats.NewDedupJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:       "TEST_DLQ_DEDUPE",
+			Subjects:   []string{"test.dlqdedupe.>"},
+			Duplicates: window,
+		}, nats.WithMsgIDFunc(nats.RandomMsgID))
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		dlq, err := nats.NewDeadLetterQueue(ctx, client.JetStreamClient, "TEST_DLQ_DEDUPE_DLQ", "dlq.dedupe", maxDeliver)
+		require.NoError(t, err)
+
+		_, err = client.PublishWithID(ctx, "test.dlqdedupe.order", "order-1", []byte("order"))
+		require.NoError(t, err)
+
+		var (
+			mu      sync.Mutex
+			handled []string
+			healthy atomic.Bool
+		)
+		cc, err := client.DeduplicateConsumer(ctx, "test-dlq-dedupe", dlq.Wrap(
+			func(_ context.Context, msg *nats.Message) nats.Result {
+				mu.Lock()
+				handled = append(handled, nats.MessageID(msg))
+				mu.Unlock()
+
+				if !healthy.Load() {
+					return nats.Term(errors.New("downstream rejected"))
+				}
+
+				return nats.Ack()
+			}))
+		require.NoError(t, err)
+		defer cc.Stop()
+
+		var letters []nats.DeadLetter
+		require.Eventually(t, func() bool {
+			letters, err = dlq.List(ctx, 0)
+
+			return err == nil && len(letters) == 1
+		}, testTimeout, 50*time.Millisecond)
+		assert.Equal(t, "order-1", letters[0].MsgID)
+
+		// Replay after the publish dedupe window so the stream keeps the restored ID
+		time.Sleep(2 * window)
+		healthy.Store(true)
+		require.NoError(t, dlq.Replay(ctx, letters[0].Sequence))
+
+		handledIDs := func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return append([]string(nil), handled...)
+		}
+		require.Eventually(t, func() bool {
+			return len(handledIDs()) == 2
+		}, testTimeout, 50*time.Millisecond)
+		assert.Equal(t, []string{"order-1", "order-1"}, handledIDs())
+
+		// The acked replay is recorded, so republishing the ID is skipped by the consumer
+		time.Sleep(2 * window)
+		_, err = client.PublishWithID(ctx, "test.dlqdedupe.order", "order-1", []byte("order"))
+		require.NoError(t, err)
+		time.Sleep(500 * time.Millisecond)
+		assert.Len(t, handledIDs(), 2)
+	})
+}
@@ -0,0 +1,35 @@
+package nats
+
+import (
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/nats-io/nats.go"
+)
+
+// Headers carrying the Event envelope attributes.
+const (
+	EventIDHeader            = eventprocessor.EventIDHeader
+	EventTypeHeader          = eventprocessor.EventTypeHeader
+	EventSourceHeader        = eventprocessor.EventSourceHeader
+	EventTimeHeader          = eventprocessor.EventTimeHeader
+	EventContentTypeHeader   = eventprocessor.EventContentTypeHeader
+	EventSchemaVersionHeader = eventprocessor.EventSchemaVersionHeader
+)
+
+// Event is the message envelope published with PublishEvent.
+type Event = eventprocessor.Event
+
+// Compile-time checks that all clients publish events.
+var (
+	_ eventprocessor.EventPublisher = (*SimpleNatsClient)(nil)
+	_ eventprocessor.EventPublisher = (*JetStreamClient)(nil)
+	_ eventprocessor.EventPublisher = (*DedupJetStreamClient)(nil)
+)
+
+// eventMsg maps event onto a message for subject, storing its attributes in headers.
+func eventMsg(subject string, event Event) (*nats.Msg, error) {
+	if err := event.Validate(); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	return &nats.Msg{Subject: subject, Header: nats.Header(event.Header()), Data: event.Data}, nil //nolint: exhaustruct
+}
@@ -0,0 +1,151 @@
+package nats_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testEvent returns an event with every envelope attribute set.
+func testEvent() nats.Event {
+	event := eventprocessor.NewEvent("orders.created", "orders-service", []byte(`{"id":1}`))
+	event.ContentType = "application/json"
+	event.SchemaVersion = "2"
+	event.Headers = map[string]string{"Tenant": "acme"}
+
+	return event
+}
+
+func TestEvents(t *testing.T) { //nolint: funlen
+	t.Parallel()
+
+	const testTimeout = 5 * time.Second
+
+	cfg := natstest.NewConfig(t)
+
+	t.Run("JetStream", func(t *testing.T) {
+		t.Parallel()
+
+		client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:     "TEST_EVENTS_1",
+			Subjects: []string{"test.events1.>"},
+		})
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		want := testEvent()
+		require.NoError(t, client.PublishEvent(ctx, "test.events1.orders", want))
+		require.NoError(t, client.PublishToStream(ctx, "test.events1.raw", []byte("raw")))
+
+		invalid := want
+		invalid.Type = ""
+		require.ErrorIs(t, client.PublishEvent(ctx, "test.events1.orders", invalid), eventprocessor.ErrInvalidEvent)
+
+		events := make(chan nats.Event, 1)
+		errs := make(chan error, 1)
+		cc, err := client.CreateConsumer(ctx, "test-events", func(_ context.Context, msg *nats.Message) nats.Result {
+			event, err := msg.Event()
+			if err != nil {
+				errs <- err
+
+				return nats.Term(err)
+			}
+			events <- event
+
+			return nats.Ack()
+		})
+		require.NoError(t, err)
+		defer cc.Stop()
+
+		select {
+		case got := <-events:
+			assert.Equal(t, want.ID, got.ID)
+			assert.Equal(t, want.Type, got.Type)
+			assert.Equal(t, want.Source, got.Source)
+			assert.True(t, want.Time.Equal(got.Time))
+			assert.Equal(t, want.ContentType, got.ContentType)
+			assert.Equal(t, want.SchemaVersion, got.SchemaVersion)
+			assert.Equal(t, want.Headers, got.Headers)
+			assert.Equal(t, want.Data, got.Data)
+		case <-ctx.Done():
+			t.Fatal("event was not consumed")
+		}
+
+		// Messages published without an envelope cannot be read as events
+		select {
+		case err := <-errs:
+			assert.ErrorIs(t, err, eventprocessor.ErrInvalidEvent)
+		case <-ctx.Done():
+			t.Fatal("raw message was not consumed")
+		}
+	})
+
+	t.Run("Simple", func(t *testing.T) {
+		t.Parallel()
+
+		client, err := nats.NewSimpleNatsClient(cfg)
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		events := make(chan nats.Event, 2)
+		_, err = client.SubscribeEvents("test.events2.>", func(event nats.Event) {
+			events <- event
+		})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		want := testEvent()
+		require.NoError(t, client.PublishToStream(ctx, "test.events2.raw", []byte("dropped")))
+		require.NoError(t, client.PublishEvent(ctx, "test.events2.orders", want))
+
+		select {
+		case got := <-events:
+			assert.Equal(t, want.ID, got.ID)
+			assert.Equal(t, want.Headers, got.Headers)
+			assert.Equal(t, want.Data, got.Data)
+		case <-ctx.Done():
+			t.Fatal("event was not received")
+		}
+	})
+
+	t.Run("Dedupe", func(t *testing.T) {
+		t.Parallel()
+
+		client, err := nats.NewDedupJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:       "TEST_EVENTS_3",
+			Subjects:   []string{"test.events3.>"},
+			Duplicates: time.Minute,
+		}, nats.WithMsgIDFunc(nats.RandomMsgID))
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		ctx := context.Background()
+		event := testEvent()
+		require.NoError(t, client.PublishEvent(ctx, "test.events3.orders", event))
+		require.NoError(t, client.PublishEvent(ctx, "test.events3.orders", event))
+		require.NoError(t, client.PublishEvent(ctx, "test.events3.orders", testEvent()))
+
+		nc, err := natsgo.Connect(cfg.URL)
+		require.NoError(t, err)
+		defer nc.Close()
+
+		js, err := jetstream.New(nc)
+		require.NoError(t, err)
+		stream, err := js.Stream(ctx, "TEST_EVENTS_3")
+		require.NoError(t, err)
+		assert.Equal(t, uint64(2), stream.CachedInfo().State.Msgs)
+	})
+}
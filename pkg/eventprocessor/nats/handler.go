@@ -0,0 +1,159 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+)
+
+// Action describes how a consumed message is acknowledged after handling.
+type Action = eventprocessor.Action
+
+const (
+	// ActionAck acknowledges the message as successfully processed.
+	ActionAck = eventprocessor.ActionAck
+	// ActionNak negatively acknowledges the message, optionally with a redelivery delay.
+	ActionNak = eventprocessor.ActionNak
+	// ActionTerm terminates the message so it is never redelivered.
+	ActionTerm = eventprocessor.ActionTerm
+	// ActionInProgress resets the redelivery timer without acknowledging the message.
+	ActionInProgress = eventprocessor.ActionInProgress
+)
+
+// Result is returned by a Handler and decides how the message is acknowledged.
+type Result = eventprocessor.Result
+
+// Ack returns a Result that acknowledges the message.
+func Ack() Result {
+	return eventprocessor.Ack()
+}
+
+// Nak returns a Result that requests redelivery after delay.
+func Nak(delay time.Duration, err error) Result {
+	return eventprocessor.Nak(delay, err)
+}
+
+// Term returns a Result that stops any further redelivery of the message.
+func Term(err error) Result {
+	return eventprocessor.Term(err)
+}
+
+// InProgress returns a Result that extends the ack deadline without acknowledging.
+// The message is redelivered after the consumer AckWait unless it is acknowledged elsewhere.
+func InProgress() Result {
+	return eventprocessor.InProgress()
+}
+
+// Message is a consumed message passed to a Handler, shared with the other brokers.
+// Messages fetched with a PullConsumer are acknowledged with Message.Respond.
+type Message = eventprocessor.Message
+
+// Handler processes a consumed message and returns how it should be acknowledged.
+type Handler = eventprocessor.Handler
+
+// acker acknowledges a JetStream message according to a Result.
+type acker struct {
+	msg jetstream.Msg
+}
+
+// Respond implements eventprocessor.Acknowledger.
+func (a acker) Respond(res Result) error {
+	return respond(a.msg, res)
+}
+
+// NewMessage wraps a raw JetStream message, reading its metadata.
+// It lets other implementations of jetstream.Msg feed a Handler.
+func NewMessage(msg jetstream.Msg) (*Message, error) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata: %w", err)
+	}
+
+	return eventprocessor.NewMessage(msg.Subject(), msg.Data(), eventprocessor.Header(msg.Headers()), &eventprocessor.Metadata{
+		Sequence: eventprocessor.SequencePair{
+			Consumer: meta.Sequence.Consumer,
+			Stream:   meta.Sequence.Stream,
+		},
+		NumDelivered: meta.NumDelivered,
+		NumPending:   meta.NumPending,
+		Timestamp:    meta.Timestamp,
+		Stream:       meta.Stream,
+		Consumer:     meta.Consumer,
+		Domain:       meta.Domain,
+	}, acker{msg: msg}), nil
+}
+
+// respond acknowledges msg according to the handler result.
+func respond(msg jetstream.Msg, res Result) error {
+	var err error
+
+	switch res.Action {
+	case ActionAck:
+		err = msg.Ack()
+	case ActionNak:
+		if res.Delay > 0 {
+			err = msg.NakWithDelay(res.Delay)
+		} else {
+			err = msg.Nak()
+		}
+	case ActionTerm:
+		err = msg.Term()
+	case ActionInProgress:
+		err = msg.InProgress()
+	default:
+		return fmt.Errorf("unknown action %s: %w", res.Action, ErrInvalidConfig)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to %s message: %w", res.Action, err)
+	}
+
+	return nil
+}
+
+// dispatch adapts a Handler to a jetstream.MessageHandler running it with ctx.
+func dispatch(ctx context.Context, logger *zap.Logger, handler Handler) jetstream.MessageHandler {
+	return func(raw jetstream.Msg) {
+		if msg, ok := readMessage(logger, raw); ok {
+			process(ctx, logger, handler, msg)
+		}
+	}
+}
+
+// readMessage wraps raw for handling.
+// Messages without readable metadata are terminated since they cannot be tracked.
+func readMessage(logger *zap.Logger, raw jetstream.Msg) (*Message, bool) {
+	msg, err := NewMessage(raw)
+	if err != nil {
+		logger.Error("failed to read message", zap.Error(err), zap.String("subject", raw.Subject()))
+
+		if err := raw.Term(); err != nil {
+			logger.Error("failed to terminate message", zap.Error(err))
+		}
+
+		return nil, false
+	}
+
+	return msg, true
+}
+
+// process runs handler on msg with ctx and acknowledges it according to the result.
+func process(ctx context.Context, logger *zap.Logger, handler Handler, msg *Message) {
+	res := handler(ctx, msg)
+	if res.Err != nil {
+		logger.Warn("handler did not process message",
+			zap.String("action", res.Action.String()),
+			zap.String("subject", msg.Subject),
+			zap.Uint64("stream_sequence", msg.Metadata.Sequence.Stream),
+			zap.Error(res.Err),
+		)
+	}
+
+	if err := msg.Respond(res); err != nil {
+		logger.Error("failed to acknowledge message", zap.Error(err))
+	}
+}
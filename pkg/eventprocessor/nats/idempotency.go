@@ -0,0 +1,163 @@
+package nats
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+)
+
+// IdempotencyStore records the IDs of processed messages so consumers can skip redeliveries.
+type IdempotencyStore interface {
+	// Seen reports whether a message with id was already processed.
+	Seen(ctx context.Context, id string) (bool, error)
+	// Mark records a message with id as processed.
+	Mark(ctx context.Context, id string) error
+}
+
+// MessageID returns the ID used for consumer-side deduplication: the Nats-Msg-Id header set by
+// the publisher, or an empty string when the message has none.
+func MessageID(msg *Message) string {
+	return msg.Headers.Get(jetstream.MsgIDHeader)
+}
+
+// MemoryStore is an IdempotencyStore keeping the most recently processed IDs in memory.
+// IDs are evicted when they expire or when capacity is exceeded, least recently marked first.
+type MemoryStore struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+// memoryEntry is an ID held by MemoryStore.
+type memoryEntry struct {
+	id      string
+	expires time.Time
+}
+
+// NewMemoryStore creates an in-memory store holding up to capacity IDs for ttl each.
+// Non-positive values fall back to DefaultIdempotencyCapacity and DefaultIdempotencyTTL.
+func NewMemoryStore(capacity int, ttl time.Duration) *MemoryStore {
+	if capacity <= 0 {
+		capacity = DefaultIdempotencyCapacity
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+
+	return &MemoryStore{
+		mu:       sync.Mutex{},
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Seen implements IdempotencyStore.
+func (s *MemoryStore) Seen(_ context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[id]
+	if !ok {
+		return false, nil
+	}
+
+	if entry, _ := elem.Value.(*memoryEntry); time.Now().After(entry.expires) {
+		s.remove(elem)
+
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Mark implements IdempotencyStore.
+func (s *MemoryStore) Mark(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expires := time.Now().Add(s.ttl)
+
+	if elem, ok := s.entries[id]; ok {
+		elem.Value = &memoryEntry{id: id, expires: expires}
+		s.order.MoveToFront(elem)
+
+		return nil
+	}
+
+	s.entries[id] = s.order.PushFront(&memoryEntry{id: id, expires: expires})
+
+	for s.order.Len() > s.capacity {
+		s.remove(s.order.Back())
+	}
+
+	return nil
+}
+
+// Len returns the number of IDs currently held, including expired ones not yet evicted.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.order.Len()
+}
+
+// remove evicts elem from the store.
+func (s *MemoryStore) remove(elem *list.Element) {
+	entry, _ := s.order.Remove(elem).(*memoryEntry)
+	delete(s.entries, entry.id)
+}
+
+// Compile-time checks that all stores implement IdempotencyStore.
+var (
+	_ IdempotencyStore = (*MemoryStore)(nil)
+	_ IdempotencyStore = (*KVStore)(nil)
+	_ IdempotencyStore = (*FileStore)(nil)
+)
+
+// idempotent wraps handler so it runs at most once per message ID recorded in store.
+// IDs are recorded once handler acks the message; terminated messages are not recorded, so one
+// dead-lettered and replayed with its original ID is handled again. Store lookups that fail
+// are nak'd with retryDelay so the message is retried later. Messages without an ID
+// cannot be told apart from legitimate repeats and always reach handler.
+func idempotent(logger *zap.Logger, store IdempotencyStore, retryDelay time.Duration, handler Handler) Handler {
+	return func(ctx context.Context, msg *Message) Result {
+		id := MessageID(msg)
+		if id == "" {
+			return handler(ctx, msg)
+		}
+
+		seen, err := store.Seen(ctx, id)
+		if err != nil {
+			return Nak(retryDelay, fmt.Errorf("failed to check message ID: %w", err))
+		}
+
+		if seen {
+			logger.Info("skipping already processed message",
+				zap.String("msg_id", id),
+				zap.String("subject", msg.Subject),
+				zap.Uint64("stream_sequence", msg.Metadata.Sequence.Stream),
+			)
+
+			return Ack()
+		}
+
+		res := handler(ctx, msg)
+		if res.Action == ActionAck {
+			if err := store.Mark(ctx, id); err != nil {
+				logger.Error("failed to record processed message", zap.String("msg_id", id), zap.Error(err))
+			}
+		}
+
+		return res
+	}
+}
@@ -0,0 +1,305 @@
+package nats
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+)
+
+// KVStore is an IdempotencyStore backed by a JetStream key-value bucket,
+// so processed IDs survive restarts and are shared between consumer replicas.
+type KVStore struct {
+	kv jetstream.KeyValue
+}
+
+// NewKVStore creates or updates the bucket used to record processed IDs on the client's connection.
+// Keys expire after ttl; zero keeps them until removed.
+func NewKVStore(ctx context.Context, client *JetStreamClient, bucket string, ttl time.Duration) (*KVStore, error) {
+	if client == nil || bucket == "" {
+		return nil, ErrInvalidConfig
+	}
+
+	kv, err := client.js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{ //nolint: exhaustruct
+		Bucket:      bucket,
+		Description: "Processed message IDs",
+		TTL:         ttl,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key-value bucket: %w", err)
+	}
+
+	return &KVStore{kv: kv}, nil
+}
+
+// Seen implements IdempotencyStore.
+func (s *KVStore) Seen(ctx context.Context, id string) (bool, error) {
+	_, err := s.kv.Get(ctx, kvKey(id))
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to look up message ID: %w", err)
+	}
+
+	return true, nil
+}
+
+// Mark implements IdempotencyStore.
+// The ID is created atomically, so an ID already marked by another replica is left as it is.
+func (s *KVStore) Mark(ctx context.Context, id string) error {
+	_, err := s.kv.Create(ctx, kvKey(id), []byte(strconv.FormatInt(time.Now().Unix(), 10)))
+	if err != nil && !errors.Is(err, jetstream.ErrKeyExists) {
+		return fmt.Errorf("failed to record message ID: %w", err)
+	}
+
+	return nil
+}
+
+// kvKey encodes id into the character set allowed for key-value keys.
+func kvKey(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id))
+}
+
+const (
+	// compactMinLines is the number of lines a FileStore file may hold before stale lines are compacted.
+	compactMinLines = 1024
+	// maxFileStoreIDLength is the longest message ID a FileStore records.
+	maxFileStoreIDLength = 64 * 1024
+	// maxFileStoreLineLength bounds a FileStore line, a timestamp and a tab followed by the ID.
+	maxFileStoreLineLength = maxFileStoreIDLength + 32
+)
+
+// FileStore is an IdempotencyStore persisting processed IDs to an append-only file.
+// The file is read on open, so processed IDs survive restarts of a single consumer.
+// Once most lines of the file are expired or repeated IDs, it is rewritten with the live entries only.
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	ttl     time.Duration
+	logger  *zap.Logger
+	entries map[string]time.Time
+	// lines counts the lines of the file and compactAt the count that triggers the next compaction
+	lines     int
+	compactAt int
+}
+
+// NewFileStore opens or creates the store file at path.
+// IDs older than ttl are ignored when loading and dropped by compaction; zero keeps them forever.
+// Compaction failures are logged to logger, since the file stays usable without compaction.
+func NewFileStore(path string, ttl time.Duration, logger *zap.Logger) (*FileStore, error) {
+	if logger == nil {
+		return nil, fmt.Errorf("logger is required: %w", ErrInvalidConfig)
+	}
+
+	file, err := openStoreFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &FileStore{
+		mu:        sync.Mutex{},
+		path:      path,
+		file:      file,
+		ttl:       ttl,
+		logger:    logger,
+		entries:   make(map[string]time.Time),
+		lines:     0,
+		compactAt: 0,
+	}
+	if err := store.load(); err != nil {
+		file.Close()
+
+		return nil, err
+	}
+
+	store.maybeCompact()
+
+	return store, nil
+}
+
+// openStoreFile opens the store file at path for appending, creating it when missing.
+func openStoreFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open idempotency file: %w", err)
+	}
+
+	return file, nil
+}
+
+// Seen implements IdempotencyStore.
+func (s *FileStore) Seen(_ context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	marked, ok := s.entries[id]
+
+	return ok && !s.expired(marked), nil
+}
+
+// Mark implements IdempotencyStore.
+// The ID is written to disk before Mark returns.
+// IDs containing a tab or newline or longer than 64 KiB are rejected.
+func (s *FileStore) Mark(_ context.Context, id string) error {
+	if strings.ContainsAny(id, "\t\n") {
+		return fmt.Errorf("message ID contains a tab or newline: %w", ErrInvalidConfig)
+	}
+
+	if len(id) > maxFileStoreIDLength {
+		return fmt.Errorf("message ID is longer than %d bytes: %w", maxFileStoreIDLength, ErrInvalidConfig)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if _, err := fmt.Fprintf(s.file, "%d\t%s\n", now.UnixNano(), id); err != nil {
+		return fmt.Errorf("failed to record message ID: %w", err)
+	}
+
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync idempotency file: %w", err)
+	}
+
+	s.entries[id] = now
+	s.lines++
+	s.maybeCompact()
+
+	return nil
+}
+
+// Close closes the underlying file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("failed to close idempotency file: %w", err)
+	}
+
+	return nil
+}
+
+// load reads previously recorded IDs, skipping expired and malformed lines.
+func (s *FileStore) load() error {
+	scanner := bufio.NewScanner(s.file)
+	scanner.Buffer(nil, maxFileStoreLineLength)
+
+	for scanner.Scan() {
+		s.lines++
+
+		ts, id, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok {
+			continue
+		}
+
+		nanos, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if marked := time.Unix(0, nanos); !s.expired(marked) {
+			s.entries[id] = marked
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read idempotency file: %w", err)
+	}
+
+	s.compactAt = max(compactMinLines, 2*len(s.entries))
+
+	return nil
+}
+
+// maybeCompact compacts the file once it holds at least twice as many lines as live entries.
+// A failed compaction is logged and retried once the file doubled again, the entries are already recorded.
+func (s *FileStore) maybeCompact() {
+	if s.lines < s.compactAt {
+		return
+	}
+
+	if err := s.compact(); err != nil {
+		s.logger.Error("failed to compact idempotency file", zap.String("path", s.path), zap.Error(err))
+		s.compactAt = 2 * s.lines
+	}
+}
+
+// compact drops expired entries and atomically replaces the file with one line per live entry.
+func (s *FileStore) compact() error {
+	for id, marked := range s.entries {
+		if s.expired(marked) {
+			delete(s.entries, id)
+		}
+	}
+
+	tmp := s.path + ".tmp"
+	if err := writeEntries(tmp, s.entries); err != nil {
+		os.Remove(tmp)
+
+		return err
+	}
+
+	// The compacted file is opened before it replaces the current one, so appends never go to an unlinked file
+	file, err := openStoreFile(tmp)
+	if err != nil {
+		os.Remove(tmp)
+
+		return err
+	}
+
+	if err := os.Rename(tmp, s.path); err != nil {
+		file.Close()
+		os.Remove(tmp)
+
+		return fmt.Errorf("failed to replace idempotency file: %w", err)
+	}
+
+	s.file.Close()
+	s.file = file
+	s.lines = len(s.entries)
+	s.compactAt = max(compactMinLines, 2*len(s.entries))
+
+	return nil
+}
+
+// writeEntries writes entries to a new file at path and syncs it.
+func writeEntries(path string, entries map[string]time.Time) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted idempotency file: %w", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	for id, marked := range entries {
+		fmt.Fprintf(w, "%d\t%s\n", marked.UnixNano(), id)
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write compacted idempotency file: %w", err)
+	}
+
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync compacted idempotency file: %w", err)
+	}
+
+	return nil
+}
+
+// expired reports whether an ID marked at marked is past the store TTL.
+func (s *FileStore) expired(marked time.Time) bool {
+	return s.ttl > 0 && time.Since(marked) > s.ttl
+}
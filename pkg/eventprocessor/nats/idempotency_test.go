@@ -0,0 +1,221 @@
+package nats_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestIdempotencyStores(t *testing.T) {
+	t.Parallel()
+
+	cfg := natstest.NewConfig(t)
+	ctx := context.Background()
+
+	client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+		Name:     "TEST_IDEMPOTENCY",
+		Subjects: []string{"test.idempotency.>"},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close(context.Background()) })
+
+	kvStore, err := nats.NewKVStore(ctx, client, "processed", time.Minute)
+	require.NoError(t, err)
+
+	fileStore, err := nats.NewFileStore(filepath.Join(t.TempDir(), "processed.log"), time.Minute, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { fileStore.Close() })
+
+	stores := map[string]nats.IdempotencyStore{
+		"memory": nats.NewMemoryStore(10, time.Minute),
+		"kv":     kvStore,
+		"file":   fileStore,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			for _, id := range []string{"order-1", "a4f1c0", "with spaces/and.dots"} {
+				seen, err := store.Seen(ctx, id)
+				require.NoError(t, err)
+				assert.False(t, seen, id)
+
+				require.NoError(t, store.Mark(ctx, id))
+
+				seen, err = store.Seen(ctx, id)
+				require.NoError(t, err)
+				assert.True(t, seen, id)
+			}
+
+			// Marking a processed ID again, for example from another replica, is not an error
+			require.NoError(t, store.Mark(ctx, "order-1"))
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("EvictsLeastRecentlyMarked", func(t *testing.T) {
+		t.Parallel()
+		store := nats.NewMemoryStore(2, time.Minute)
+
+		require.NoError(t, store.Mark(ctx, "a"))
+		require.NoError(t, store.Mark(ctx, "b"))
+		require.NoError(t, store.Mark(ctx, "a"))
+		require.NoError(t, store.Mark(ctx, "c"))
+
+		tests := []struct {
+			id   string
+			seen bool
+		}{
+			{id: "a", seen: true},
+			{id: "b", seen: false},
+			{id: "c", seen: true},
+		}
+		for _, tt := range tests {
+			seen, err := store.Seen(ctx, tt.id)
+			require.NoError(t, err)
+			assert.Equal(t, tt.seen, seen, tt.id)
+		}
+		assert.Equal(t, 2, store.Len())
+	})
+
+	t.Run("ExpiresAfterTTL", func(t *testing.T) {
+		t.Parallel()
+		store := nats.NewMemoryStore(10, 20*time.Millisecond)
+
+		require.NoError(t, store.Mark(ctx, "a"))
+		time.Sleep(50 * time.Millisecond)
+
+		seen, err := store.Seen(ctx, "a")
+		require.NoError(t, err)
+		assert.False(t, seen)
+		assert.Equal(t, 0, store.Len())
+	})
+}
+
+func TestFileStorePersists(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "processed.log")
+
+	store, err := nats.NewFileStore(path, 0, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, store.Mark(ctx, "order-1"))
+	require.Error(t, store.Mark(ctx, "bad\nid"))
+	require.ErrorIs(t, store.Mark(ctx, strings.Repeat("x", 64*1024+1)), nats.ErrInvalidConfig)
+
+	// IDs up to the limit are read back, beyond the default line length of a scanner
+	long := strings.Repeat("x", 64*1024)
+	require.NoError(t, store.Mark(ctx, long))
+	require.NoError(t, store.Close())
+
+	reopened, err := nats.NewFileStore(path, 0, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	seen, err := reopened.Seen(ctx, "order-1")
+	require.NoError(t, err)
+	assert.True(t, seen)
+
+	seen, err = reopened.Seen(ctx, long)
+	require.NoError(t, err)
+	assert.True(t, seen)
+
+	expiring, err := nats.NewFileStore(path, time.Nanosecond, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	defer expiring.Close()
+
+	seen, err = expiring.Seen(ctx, "order-1")
+	require.NoError(t, err)
+	assert.False(t, seen)
+}
+
+func TestFileStoreCompacts(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "processed.log")
+
+	store, err := nats.NewFileStore(path, 0, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	// Repeated IDs are compacted to one line each instead of growing the file
+	for i := range 5000 {
+		require.NoError(t, store.Mark(ctx, "order-"+strconv.Itoa(i%10)))
+	}
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Less(t, info.Size(), int64(1100*len("1700000000000000000\torder-0\n")))
+
+	seen, err := store.Seen(ctx, "order-9")
+	require.NoError(t, err)
+	assert.True(t, seen)
+	require.NoError(t, store.Close())
+
+	// Distinct live IDs are kept, and dropped once expired when the file is reopened
+	live, err := nats.NewFileStore(path, 0, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	for i := range 2000 {
+		require.NoError(t, live.Mark(ctx, "order-"+strconv.Itoa(i)))
+	}
+	require.NoError(t, live.Close())
+
+	expiring, err := nats.NewFileStore(path, time.Nanosecond, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	defer expiring.Close()
+
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+}
+
+func TestFileStoreCompactionFailure(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "processed.log")
+
+	store, err := nats.NewFileStore(path, 0, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	defer store.Close()
+
+	// A directory in place of the compacted file makes compaction fail without failing Mark
+	require.NoError(t, os.MkdirAll(filepath.Join(path+".tmp", "blocked"), 0o700))
+
+	for range 1500 {
+		require.NoError(t, store.Mark(ctx, "order-1"))
+	}
+
+	seen, err := store.Seen(ctx, "order-1")
+	require.NoError(t, err)
+	assert.True(t, seen)
+
+	// Compaction is retried once the file doubled again
+	require.NoError(t, os.RemoveAll(path+".tmp"))
+
+	for range 1000 {
+		require.NoError(t, store.Mark(ctx, "order-1"))
+	}
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Less(t, info.Size(), int64(1000*len("1700000000000000000\torder-1\n")))
+}
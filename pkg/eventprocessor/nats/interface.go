@@ -1,11 +1,11 @@
 package nats
 
 import (
-	"context"
 	"errors"
 	"os"
 	"time"
 
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
 	"go.uber.org/zap"
 )
 
@@ -13,23 +13,22 @@ import (
 var (
 	// ErrInvalidConfig is returned when the configuration is invalid.
 	ErrInvalidConfig = errors.New("invalid configuration")
+	// ErrEmptyMsgID is returned when a deduplicated publish has no message ID.
+	ErrEmptyMsgID = errors.New("empty message ID")
+	// ErrMaxDeliveries is recorded when a message is dead-lettered after exhausting its deliveries.
+	ErrMaxDeliveries = errors.New("maximum deliveries exceeded")
 )
 
 // EventProcessor defines the interface for different event processing strategies.
-// It provides methods for publishing messages to streams and managing connections.
-type EventProcessor interface {
-	// PublishToStream publishes a message to a stream.
-	// ctx provides context for the operation
-	// topic is the stream or subject to publish to
-	// data is the message payload to publish
-	// Returns an error if the publish operation fails
-	PublishToStream(ctx context.Context, topic string, data []byte) error
+// It is an alias of eventprocessor.EventProcessor so NATS clients can be swapped with other brokers.
+type EventProcessor = eventprocessor.EventProcessor
 
-	// Close gracefully shuts down the event processor and its connections.
-	// ctx provides context for the shutdown operation
-	// Returns an error if the shutdown fails
-	Close(ctx context.Context) error
-}
+// Compile-time checks that all clients implement EventProcessor.
+var (
+	_ EventProcessor = (*SimpleNatsClient)(nil)
+	_ EventProcessor = (*JetStreamClient)(nil)
+	_ EventProcessor = (*DedupJetStreamClient)(nil)
+)
 
 // NewConfig creates a new configuration with values from environment.
 // It initializes a production logger and sets default values for reconnection parameters.
@@ -46,5 +45,7 @@ func NewConfig() *Config {
 		MaxReconnects: DefaultMaxReconnects,
 		ReconnectWait: time.Second * DefaultReconnectWaitSeconds,
 		Logger:        logger,
+		Metrics:       nil,
+		Tracing:       nil,
 	}
 }
@@ -2,8 +2,12 @@ package nats
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
@@ -18,30 +22,44 @@ type JetStreamClient struct {
 	config       *Config
 	stream       jetstream.Stream
 	streamConfig jetstream.StreamConfig
+	ownership    StreamOwnership
+	changes      StreamDiff
+	consumers    map[*consumption]struct{}
 	logger       *zap.Logger
+	metrics      *Metrics
+	tracing      *Tracing
+	name         string
 }
 
 // NewJetStreamClient creates a new NATS JetStream client.
-func NewJetStreamClient(cfg *Config, streamConfig jetstream.StreamConfig) (*JetStreamClient, error) {
+// The stream is created or updated to streamConfig and kept on Close unless WithStreamOwnership says otherwise.
+func NewJetStreamClient(
+	cfg *Config,
+	streamConfig jetstream.StreamConfig,
+	opts ...JetStreamOption,
+) (*JetStreamClient, error) {
+	return newJetStreamClient(cfg, streamConfig, clientJetStream, opts)
+}
+
+// newJetStreamClient creates a JetStream client whose metrics are labeled with client.
+func newJetStreamClient(
+	cfg *Config,
+	streamConfig jetstream.StreamConfig,
+	client string,
+	opts []JetStreamOption,
+) (*JetStreamClient, error) {
 	if cfg == nil {
 		return nil, ErrInvalidConfig
 	}
 
-	opts := []nats.Option{
-		nats.MaxReconnects(cfg.MaxReconnects),
-		nats.ReconnectWait(cfg.ReconnectWait),
+	jsOpts := jetStreamOptions{ownership: StreamManaged}
+	for _, opt := range opts {
+		opt(&jsOpts)
 	}
 
-	// Use credentials file if provided, fallback to token
-	if cfg.CredsFile != "" {
-		opts = append(opts, nats.UserCredentials(cfg.CredsFile))
-	} else if cfg.Token != "" {
-		opts = append(opts, nats.Token(cfg.Token))
-	}
-
-	nc, err := nats.Connect(cfg.URL, opts...)
+	nc, err := connect(cfg, client)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+		return nil, err
 	}
 
 	js, err := jetstream.New(nc)
@@ -51,11 +69,18 @@ func NewJetStreamClient(cfg *Config, streamConfig jetstream.StreamConfig) (*JetS
 		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
 	}
 
-	stream, err := js.CreateStream(context.Background(), streamConfig)
+	stream, changes, err := openStream(context.Background(), js, streamConfig, jsOpts.ownership)
 	if err != nil {
 		nc.Close()
 
-		return nil, fmt.Errorf("failed to create stream: %w", err)
+		return nil, err
+	}
+
+	if len(changes) > 0 {
+		cfg.Logger.Info("stream reconciled",
+			zap.String("stream", streamConfig.Name),
+			zap.Stringer("changes", changes),
+		)
 	}
 
 	return &JetStreamClient{
@@ -63,89 +88,148 @@ func NewJetStreamClient(cfg *Config, streamConfig jetstream.StreamConfig) (*JetS
 		js:           js,
 		mu:           sync.RWMutex{},
 		config:       cfg,
-		streamConfig: streamConfig,
+		streamConfig: stream.CachedInfo().Config,
+		ownership:    jsOpts.ownership,
+		changes:      changes,
 		stream:       stream,
+		consumers:    make(map[*consumption]struct{}),
 		logger:       cfg.Logger,
+		metrics:      cfg.Metrics,
+		tracing:      cfg.Tracing,
+		name:         client,
 	}, nil
 }
 
+// StreamChanges returns the changes applied when reconciling an existing managed stream on creation.
+func (c *JetStreamClient) StreamChanges() StreamDiff {
+	return c.changes
+}
+
 // PublishToStream publishes a message to a stream.
 func (c *JetStreamClient) PublishToStream(ctx context.Context, topic string, data []byte) error {
 	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("context error: %w", err)
 	}
 
-	_, err := c.js.Publish(ctx, topic, data)
+	_, err := c.publishMsg(ctx, &nats.Msg{Subject: topic, Data: data}) //nolint: exhaustruct
+
+	return err
+}
+
+// PublishEvent publishes event to a stream, carrying its attributes in message headers.
+// Consumers read it back with Message.Event.
+func (c *JetStreamClient) PublishEvent(ctx context.Context, topic string, event Event) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context error: %w", err)
+	}
+
+	msg, err := eventMsg(topic, event)
 	if err != nil {
-		return fmt.Errorf("failed to publish message: %w", err)
+		return err
 	}
 
-	return nil
+	_, err = c.publishMsg(ctx, msg)
+
+	return err
 }
 
-// CreateConsumer creates a durable pull consumer for the stream.
-// Returns a ConsumeContext that must be used to receive messages.
-func (c *JetStreamClient) CreateConsumer(ctx context.Context, name string) (jetstream.ConsumeContext, error) { //nolint: ireturn
+// PublishCloudEvent publishes event to a stream as a CloudEvents 1.0 event in mode.
+// Consumers read it back with Message.CloudEvent.
+func (c *JetStreamClient) PublishCloudEvent(
+	ctx context.Context,
+	topic string,
+	event Event,
+	mode CloudEventsMode,
+) error {
 	if err := ctx.Err(); err != nil {
-		return nil, fmt.Errorf("context error: %w", err)
+		return fmt.Errorf("context error: %w", err)
 	}
 
-	consumerConfig := jetstream.ConsumerConfig{ //nolint: exhaustruct
-		Name:               name,
-		Durable:            name,
-		DeliverPolicy:      jetstream.DeliverAllPolicy,
-		AckPolicy:          jetstream.AckExplicitPolicy,
-		Description:        fmt.Sprintf("Consumer %s for stream %s", name, c.streamConfig.Name),
-		MaxRequestBatch:    DefaultMaxRequestBatch,
-		MaxRequestExpires:  c.config.ReconnectWait,
-		MaxRequestMaxBytes: DefaultMaxRequestMaxBytes,
-		InactiveThreshold:  c.config.ReconnectWait * DefaultInactiveThresholdMultiplier,
+	msg, err := cloudEventMsg(topic, event, mode)
+	if err != nil {
+		return err
 	}
 
-	consumer, err := c.stream.CreateOrUpdateConsumer(ctx, consumerConfig)
+	_, err = c.publishMsg(ctx, msg)
+
+	return err
+}
+
+// publishMsg publishes msg to the stream, recording traces and metrics.
+func (c *JetStreamClient) publishMsg(
+	ctx context.Context,
+	msg *nats.Msg,
+	opts ...jetstream.PublishOpt,
+) (*jetstream.PubAck, error) {
+	end := c.tracing.startPublish(ctx, c.name, msg)
+
+	start := time.Now()
+	ack, err := c.js.PublishMsg(ctx, msg, opts...)
+	c.metrics.observePublish(c.name, start, err)
+	end(err)
+
 	if err != nil {
-		return nil, fmt.Errorf("failed to create consumer: %w", err)
+		return nil, fmt.Errorf("failed to publish message: %w", err)
 	}
 
-	// Create consume context with options
-	cc, err := consumer.Consume(func(msg jetstream.Msg) {
-		defer func() {
-			if err := msg.Ack(); err != nil {
-				c.logger.Error("failed to acknowledge message", zap.Error(err))
-			}
-		}()
-		meta, err := msg.Metadata()
-		if err != nil {
-			c.logger.Error("failed to get metadata", zap.Error(err))
+	return ack, nil
+}
 
-			return
-		}
+// CreateConsumer creates a pull consumer for the stream, durable unless WithEphemeral is given,
+// and starts consuming with handler.
+// The Result returned by handler decides whether each message is acked, nak'd, terminated or kept in progress.
+// opts customize the consumer configuration, for example WithDeliverPolicy, WithFilterSubjects or WithRetryPolicy.
+// Returns a ConsumeContext that must be stopped to end consumption.
+func (c *JetStreamClient) CreateConsumer( //nolint: ireturn
+	ctx context.Context,
+	name string,
+	handler Handler,
+	opts ...ConsumerOption,
+) (jetstream.ConsumeContext, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("context error: %w", err)
+	}
 
-		c.logger.Info("received message",
-			zap.Uint64("consumer_sequence", meta.Sequence.Consumer),
-			zap.String("subject", msg.Subject()))
-	})
+	if handler == nil {
+		return nil, fmt.Errorf("handler is required: %w", ErrInvalidConfig)
+	}
+
+	consumerOpts, err := c.consumerOptions(name, "Consumer", opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create consume context: %w", err)
+		return nil, err
 	}
 
-	return cc, nil
+	consumer, err := c.stream.CreateOrUpdateConsumer(ctx, consumerOpts.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
+	}
+
+	return c.consume(consumer, handler, consumerOpts)
 }
 
-// Close closes the NATS connection and cleans up resources.
+// Close stops the consumers created by the client, waiting for messages being handled to be acknowledged,
+// and drains the NATS connection, flushing pending publishes.
+// The stream is deleted only when the client owns it with StreamEphemeral.
+// Messages delivered to a consumer after Close started are nak'd for redelivery.
+// Work left unfinished at the context deadline is reported with an error wrapping ErrShutdownIncomplete,
+// and the context passed to the handlers still running is canceled.
 func (c *JetStreamClient) Close(ctx context.Context) error {
 	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("context error: %w", err)
 	}
 
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	consumers := slices.Collect(maps.Keys(c.consumers))
+	clear(c.consumers)
+	c.mu.Unlock()
 
-	if err := c.js.DeleteStream(ctx, c.streamConfig.Name); err != nil {
-		c.logger.Error("failed to delete stream", zap.Error(err))
-	}
+	consumersErr := stopConsumers(ctx, consumers)
 
-	c.conn.Close()
+	if c.ownership == StreamEphemeral {
+		if err := c.js.DeleteStream(ctx, c.streamConfig.Name); err != nil {
+			c.logger.Error("failed to delete stream", zap.Error(err))
+		}
+	}
 
-	return nil
+	return errors.Join(consumersErr, drainConn(ctx, c.conn))
 }
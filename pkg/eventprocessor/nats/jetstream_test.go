@@ -2,44 +2,31 @@ package nats_test
 
 import (
 	"context"
-	"os"
+	"errors"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/eventprocessortest"
 	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/ratelimit"
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"go.uber.org/zap"
-	"go.uber.org/zap/zaptest/observer"
 )
 
 const (
-	defaultNatsURL = "nats://nats:4222"
-	testTimeout    = 5 * time.Second
-	messageCount   = 100
+	testTimeout  = 5 * time.Second
+	messageCount = 100
 )
 
 func TestJetStreamClient(t *testing.T) {
 	t.Parallel()
 
-	natsURL := os.Getenv("NATS_URL")
-	if natsURL == "" {
-		natsURL = defaultNatsURL // fallback for local testing
-	}
-
-	logger, err := zap.NewDevelopment()
-	require.NoError(t, err)
-
-	cfg := &nats.Config{
-		Logger:        logger,
-		URL:           natsURL,
-		Token:         "test-token",
-		CredsFile:     "",
-		MaxReconnects: nats.DefaultMaxReconnects,
-		ReconnectWait: time.Second * nats.DefaultReconnectWaitSeconds,
-	}
+	cfg := natstest.NewConfig(t)
 
 	t.Run("NewJetStreamClient", func(t *testing.T) {
 		t.Parallel()
@@ -53,16 +40,26 @@ func TestJetStreamClient(t *testing.T) {
 		defer client.Close(context.Background())
 	})
 
-	t.Run("ConsumerTest", func(t *testing.T) {
+	t.Run("Suite", func(t *testing.T) {
 		t.Parallel()
+		eventprocessortest.RunConsumers(t, "test.jetstream.suite",
+			func(t *testing.T, pattern string) eventprocessortest.Streaming[nats.ConsumerOption, jetstream.ConsumeContext] {
+				t.Helper()
+				name := strings.ToUpper(strings.ReplaceAll(strings.TrimSuffix(pattern, ".>"), ".", "_"))
+				client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+					Name:     name,
+					Subjects: []string{pattern},
+				})
+				require.NoError(t, err)
+
+				return client
+			}, nats.WithAckWait)
+	})
 
-		// Create test logger with observer for assertions
-		core, logs := observer.New(zap.InfoLevel)
-		logger := zap.New(core)
-		testCfg := *cfg
-		testCfg.Logger = logger
+	t.Run("ConsumerTest", func(t *testing.T) {
+		t.Parallel()
 
-		client, err := nats.NewJetStreamClient(&testCfg, jetstream.StreamConfig{ //nolint: exhaustruct
+		client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
 			Name:       "TEST_JETSTREAM_2",
 			Subjects:   []string{"test.jetstream2.>"},
 			Duplicates: 100 * time.Millisecond,
@@ -81,22 +78,115 @@ func TestJetStreamClient(t *testing.T) {
 		}
 
 		// Create consumer and start consuming
-		cc, err := client.CreateConsumer(ctx, "test-consumer")
+		var lastSeq atomic.Uint64
+		cc, err := client.CreateConsumer(ctx, "test-consumer", func(_ context.Context, msg *nats.Message) nats.Result {
+			assert.Equal(t, []byte("data"), msg.Data)
+			lastSeq.Store(msg.Metadata.Sequence.Consumer)
+
+			return nats.Ack()
+		})
 		require.NoError(t, err)
 		require.NotNil(t, cc)
 		defer cc.Stop()
 
 		// Wait for all messages to be processed
 		require.Eventually(t, func() bool {
-			entries := logs.All()
-			for _, entry := range entries {
-				if seq, ok := entry.ContextMap()["consumer_sequence"].(uint64); ok && seq == messageCount {
-					return true
-				}
+			return lastSeq.Load() == messageCount
+		}, testTimeout, 100*time.Millisecond, "did not receive all messages or stream is already filled")
+	})
+
+	t.Run("HandlerResults", func(t *testing.T) {
+		t.Parallel()
+
+		client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:     "TEST_JETSTREAM_4",
+			Subjects: []string{"test.jetstream4.>"},
+		})
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		require.NoError(t, client.PublishToStream(ctx, "test.jetstream4.retry", []byte("retry")))
+		require.NoError(t, client.PublishToStream(ctx, "test.jetstream4.poison", []byte("poison")))
+
+		var retried, poisoned atomic.Uint64
+		cc, err := client.CreateConsumer(ctx, "test-results", func(_ context.Context, msg *nats.Message) nats.Result {
+			if msg.Subject == "test.jetstream4.poison" {
+				poisoned.Add(1)
+
+				return nats.Term(errors.New("poison message"))
 			}
 
-			return false
-		}, testTimeout, 100*time.Millisecond, "did not receive all messages or stream is already filled")
+			retried.Store(msg.Metadata.NumDelivered)
+			if msg.Metadata.NumDelivered < 3 {
+				return nats.Nak(10*time.Millisecond, errors.New("not yet"))
+			}
+
+			return nats.Ack()
+		})
+		require.NoError(t, err)
+		defer cc.Stop()
+
+		require.Eventually(t, func() bool {
+			return retried.Load() == 3
+		}, testTimeout, 50*time.Millisecond, "nak'd message was not redelivered")
+
+		// Terminated messages must not be redelivered
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, uint64(1), poisoned.Load())
+	})
+
+	t.Run("Throttle", func(t *testing.T) {
+		t.Parallel()
+
+		client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:     "TEST_JETSTREAM_6",
+			Subjects: []string{"test.jetstream6.>"},
+		})
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		for range 5 {
+			require.NoError(t, client.PublishToStream(ctx, "test.jetstream6.slow", []byte("data")))
+		}
+
+		limiter, err := ratelimit.NewLimiter(ratelimit.Rule{Pattern: "test.jetstream6.>", Rate: 20, Burst: 1})
+		require.NoError(t, err)
+
+		var handled atomic.Uint64
+		start := time.Now()
+		cc, err := client.CreateConsumer(ctx, "test-throttle", func(context.Context, *nats.Message) nats.Result {
+			handled.Add(1)
+
+			return nats.Ack()
+		}, nats.WithThrottle(limiter))
+		require.NoError(t, err)
+		defer cc.Stop()
+
+		require.Eventually(t, func() bool {
+			return handled.Load() == 5
+		}, testTimeout, 10*time.Millisecond)
+		assert.GreaterOrEqual(t, time.Since(start), 180*time.Millisecond)
+		assert.Equal(t, uint64(4), limiter.Stats()[0].Delayed)
+	})
+
+	t.Run("NilHandler", func(t *testing.T) {
+		t.Parallel()
+
+		client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:     "TEST_JETSTREAM_5",
+			Subjects: []string{"test.jetstream5.>"},
+		})
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		_, err = client.CreateConsumer(context.Background(), "test-nil", nil)
+		assert.ErrorIs(t, err, nats.ErrInvalidConfig)
 	})
 
 	t.Run("InvalidConnection", func(t *testing.T) {
@@ -0,0 +1,220 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Client labels used for metrics.
+const (
+	clientSimple    = "simple"
+	clientJetStream = "jetstream"
+	clientDedupe    = "dedupe"
+)
+
+// Metrics records Prometheus metrics for the NATS clients.
+// Set it on Config.Metrics to enable instrumentation; a nil Metrics records nothing.
+// Clients of the same kind share their client label: their series add up, and connections_up
+// counts how many of them are connected.
+type Metrics struct {
+	published       *prometheus.CounterVec
+	publishErrors   *prometheus.CounterVec
+	publishDuration *prometheus.HistogramVec
+	received        *prometheus.CounterVec
+	consumed        *prometheus.CounterVec
+	handled         *prometheus.CounterVec
+	redeliveries    *prometheus.CounterVec
+	pending         *prometheus.GaugeVec
+	connected       *prometheus.GaugeVec
+	reconnects      *prometheus.CounterVec
+}
+
+// NewMetrics creates the client metrics and registers them with reg.
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	if reg == nil {
+		return nil, fmt.Errorf("registerer is required: %w", ErrInvalidConfig)
+	}
+
+	consumerLabels := []string{"client", "consumer"}
+	m := &Metrics{
+		published: prometheus.NewCounterVec(prometheus.CounterOpts{ //nolint: exhaustruct
+			Namespace: MetricsNamespace,
+			Name:      "published_total",
+			Help:      "Messages published successfully.",
+		}, []string{"client"}),
+		publishErrors: prometheus.NewCounterVec(prometheus.CounterOpts{ //nolint: exhaustruct
+			Namespace: MetricsNamespace,
+			Name:      "publish_errors_total",
+			Help:      "Publishes that returned an error.",
+		}, []string{"client"}),
+		publishDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{ //nolint: exhaustruct
+			Namespace: MetricsNamespace,
+			Name:      "publish_duration_seconds",
+			Help:      "Publish latency, including the server acknowledgement for JetStream clients.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8), //nolint: mnd
+		}, []string{"client"}),
+		received: prometheus.NewCounterVec(prometheus.CounterOpts{ //nolint: exhaustruct
+			Namespace: MetricsNamespace,
+			Name:      "received_total",
+			Help:      "Messages delivered to core NATS subscriptions.",
+		}, []string{"client", "subject"}),
+		consumed: prometheus.NewCounterVec(prometheus.CounterOpts{ //nolint: exhaustruct
+			Namespace: MetricsNamespace,
+			Name:      "consumed_total",
+			Help:      "Messages delivered to JetStream consumer handlers.",
+		}, consumerLabels),
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{ //nolint: exhaustruct
+			Namespace: MetricsNamespace,
+			Name:      "handled_total",
+			Help:      "Handler results by acknowledgement action.",
+		}, append(consumerLabels, "action")),
+		redeliveries: prometheus.NewCounterVec(prometheus.CounterOpts{ //nolint: exhaustruct
+			Namespace: MetricsNamespace,
+			Name:      "redeliveries_total",
+			Help:      "Messages delivered more than once.",
+		}, consumerLabels),
+		pending: prometheus.NewGaugeVec(prometheus.GaugeOpts{ //nolint: exhaustruct
+			Namespace: MetricsNamespace,
+			Name:      "consumer_pending",
+			Help:      "Messages left in the stream for the consumer, as of the last delivery.",
+		}, consumerLabels),
+		connected: prometheus.NewGaugeVec(prometheus.GaugeOpts{ //nolint: exhaustruct
+			Namespace: MetricsNamespace,
+			Name:      "connections_up",
+			Help:      "Clients connected to the NATS server.",
+		}, []string{"client"}),
+		reconnects: prometheus.NewCounterVec(prometheus.CounterOpts{ //nolint: exhaustruct
+			Namespace: MetricsNamespace,
+			Name:      "reconnects_total",
+			Help:      "Reconnections to the NATS server.",
+		}, []string{"client"}),
+	}
+
+	for _, c := range []prometheus.Collector{
+		m.published, m.publishErrors, m.publishDuration, m.received, m.consumed, m.handled,
+		m.redeliveries, m.pending, m.connected, m.reconnects,
+	} {
+		if err := reg.Register(c); err != nil {
+			return nil, fmt.Errorf("failed to register metric: %w", err)
+		}
+	}
+
+	return m, nil
+}
+
+// observePublish records a publish by client that started at start and returned err.
+// Canceled contexts are not counted as publish errors.
+func (m *Metrics) observePublish(client string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+
+	m.publishDuration.WithLabelValues(client).Observe(time.Since(start).Seconds())
+
+	switch {
+	case err == nil:
+		m.published.WithLabelValues(client).Inc()
+	case !errors.Is(err, context.Canceled):
+		m.publishErrors.WithLabelValues(client).Inc()
+	}
+}
+
+// observeReceived records a core NATS message delivered to a subscription of subject.
+func (m *Metrics) observeReceived(client, subject string) {
+	if m == nil {
+		return
+	}
+
+	m.received.WithLabelValues(client, subject).Inc()
+}
+
+// instrument wraps handler to record deliveries, redeliveries, consumer lag and results.
+func (m *Metrics) instrument(client, consumer string, handler Handler) Handler {
+	if m == nil {
+		return handler
+	}
+
+	return func(ctx context.Context, msg *Message) Result {
+		handled := m.observeConsumed(client, consumer, msg)
+		res := handler(ctx, msg)
+		handled(res)
+
+		return res
+	}
+}
+
+// observeConsumed records the delivery of msg to consumer, its redelivery and the consumer lag.
+// The returned function records the result msg was handled with.
+func (m *Metrics) observeConsumed(client, consumer string, msg *Message) func(Result) {
+	if m == nil {
+		return func(Result) {}
+	}
+
+	m.consumed.WithLabelValues(client, consumer).Inc()
+
+	if msg.Metadata.NumDelivered > 1 {
+		m.redeliveries.WithLabelValues(client, consumer).Inc()
+	}
+
+	m.pending.WithLabelValues(client, consumer).Set(float64(msg.Metadata.NumPending))
+
+	return func(res Result) {
+		m.handled.WithLabelValues(client, consumer, res.Action.String()).Inc()
+	}
+}
+
+// connectionMetrics tracks whether a single connection of client is counted in connections_up.
+type connectionMetrics struct {
+	metrics *Metrics
+	client  string
+	up      atomic.Bool
+}
+
+// connection returns the connection state tracker for a new connection of client, nil without metrics.
+func (m *Metrics) connection(client string) *connectionMetrics {
+	if m == nil {
+		return nil
+	}
+
+	return &connectionMetrics{metrics: m, client: client, up: atomic.Bool{}}
+}
+
+// options returns NATS options that keep connections_up current as the connection goes up and down.
+func (c *connectionMetrics) options() []nats.Option {
+	if c == nil {
+		return nil
+	}
+
+	return []nats.Option{
+		nats.DisconnectErrHandler(func(*nats.Conn, error) {
+			c.setConnected(false)
+		}),
+		nats.ReconnectHandler(func(*nats.Conn) {
+			c.metrics.reconnects.WithLabelValues(c.client).Inc()
+			c.setConnected(true)
+		}),
+		nats.ClosedHandler(func(*nats.Conn) {
+			c.setConnected(false)
+		}),
+	}
+}
+
+// setConnected counts the connection in connections_up while it is connected.
+// Repeated calls with the same state, such as a disconnect followed by close, count once.
+func (c *connectionMetrics) setConnected(up bool) {
+	if c == nil || c.up.Swap(up) == up {
+		return
+	}
+
+	if up {
+		c.metrics.connected.WithLabelValues(c.client).Inc()
+	} else {
+		c.metrics.connected.WithLabelValues(c.client).Dec()
+	}
+}
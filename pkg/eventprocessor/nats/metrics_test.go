@@ -0,0 +1,175 @@
+package nats_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// metricValue returns the value of the counter or gauge name whose labels include labels.
+func metricValue(t *testing.T, reg *prometheus.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if want, ok := labels[pair.GetName()]; ok && want != pair.GetValue() {
+					continue metrics
+				}
+			}
+
+			if metric.GetCounter() != nil {
+				return metric.GetCounter().GetValue()
+			}
+
+			return metric.GetGauge().GetValue()
+		}
+	}
+
+	return 0
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	const testTimeout = 5 * time.Second
+
+	reg := prometheus.NewRegistry()
+	metrics, err := nats.NewMetrics(reg)
+	require.NoError(t, err)
+
+	cfg := natstest.NewConfig(t)
+	cfg.Metrics = metrics
+
+	_, err = nats.NewMetrics(reg)
+	require.Error(t, err, "metrics must not be registered twice")
+
+	client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+		Name:     "TEST_METRICS",
+		Subjects: []string{"test.metrics.>"},
+	})
+	require.NoError(t, err)
+	defer client.Close(context.Background())
+
+	simple, err := nats.NewSimpleNatsClient(cfg)
+	require.NoError(t, err)
+	defer simple.Close(context.Background())
+
+	other, err := nats.NewSimpleNatsClient(cfg)
+	require.NoError(t, err)
+	defer other.Close(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	for _, subject := range []string{"test.metrics.ok", "test.metrics.retry", "test.metrics.bad"} {
+		require.NoError(t, client.PublishToStream(ctx, subject, []byte("data")))
+	}
+	require.Error(t, client.PublishToStream(ctx, "test.unbound", []byte("data")))
+
+	received := make(chan struct{}, 1)
+	_, err = simple.Subscribe("test.simple", func([]byte) { received <- struct{}{} })
+	require.NoError(t, err)
+	require.NoError(t, simple.PublishToStream(ctx, "test.simple", []byte("data")))
+	<-received
+
+	cc, err := client.CreateConsumer(ctx, "test-metrics", func(_ context.Context, msg *nats.Message) nats.Result {
+		switch {
+		case msg.Subject == "test.metrics.bad":
+			return nats.Term(errors.New("bad message"))
+		case msg.Subject == "test.metrics.retry" && msg.Metadata.NumDelivered == 1:
+			return nats.Nak(0, errors.New("try again"))
+		default:
+			return nats.Ack()
+		}
+	})
+	require.NoError(t, err)
+	defer cc.Stop()
+
+	// The redelivered message is acked last
+	consumer := map[string]string{"client": "jetstream", "consumer": "test-metrics"}
+	acked := map[string]string{"client": "jetstream", "consumer": "test-metrics", "action": "ack"}
+	require.Eventually(t, func() bool {
+		return metricValue(t, reg, "eventprocessor_handled_total", acked) == 2
+	}, testTimeout, 10*time.Millisecond)
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{
+		{name: "eventprocessor_published_total", labels: map[string]string{"client": "jetstream"}, want: 3},
+		{name: "eventprocessor_publish_errors_total", labels: map[string]string{"client": "jetstream"}, want: 1},
+		{name: "eventprocessor_published_total", labels: map[string]string{"client": "simple"}, want: 1},
+		{
+			name:   "eventprocessor_received_total",
+			labels: map[string]string{"client": "simple", "subject": "test.simple"},
+			want:   1,
+		},
+		{name: "eventprocessor_consumed_total", labels: consumer, want: 4},
+		{
+			name:   "eventprocessor_handled_total",
+			labels: map[string]string{"client": "jetstream", "consumer": "test-metrics", "action": "nak"},
+			want:   1,
+		},
+		{
+			name:   "eventprocessor_handled_total",
+			labels: map[string]string{"client": "jetstream", "consumer": "test-metrics", "action": "term"},
+			want:   1,
+		},
+		{name: "eventprocessor_redeliveries_total", labels: consumer, want: 1},
+		{name: "eventprocessor_consumer_pending", labels: consumer, want: 0},
+		{name: "eventprocessor_connections_up", labels: map[string]string{"client": "jetstream"}, want: 1},
+		{name: "eventprocessor_connections_up", labels: map[string]string{"client": "simple"}, want: 2},
+	}
+	for _, tt := range tests {
+		assert.InDelta(t, tt.want, metricValue(t, reg, tt.name, tt.labels), 0, tt.name, tt.labels)
+	}
+
+	// Fetched messages are recorded like handled ones once responded to
+	pull, err := client.CreatePullConsumer(ctx, "test-metrics-pull")
+	require.NoError(t, err)
+
+	msgs, err := pull.Fetch(ctx, 3, time.Second)
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+
+	for _, msg := range msgs {
+		require.NoError(t, msg.Respond(nats.InProgress()))
+		require.NoError(t, msg.Respond(nats.Ack()))
+	}
+
+	pulled := map[string]string{"client": "jetstream", "consumer": "test-metrics-pull"}
+	assert.InDelta(t, 3, metricValue(t, reg, "eventprocessor_consumed_total", pulled), 0)
+	assert.InDelta(t, 3, metricValue(t, reg, "eventprocessor_handled_total",
+		map[string]string{"client": "jetstream", "consumer": "test-metrics-pull", "action": "ack"}), 0)
+	assert.InDelta(t, 0, metricValue(t, reg, "eventprocessor_handled_total",
+		map[string]string{"client": "jetstream", "consumer": "test-metrics-pull", "action": "in_progress"}), 0)
+
+	// Closing one client leaves the other of the same kind counted
+	require.NoError(t, simple.Close(context.Background()))
+	require.Eventually(t, func() bool {
+		return metricValue(t, reg, "eventprocessor_connections_up", map[string]string{"client": "simple"}) == 1
+	}, testTimeout, 10*time.Millisecond)
+
+	require.NoError(t, other.Close(context.Background()))
+	require.Eventually(t, func() bool {
+		return metricValue(t, reg, "eventprocessor_connections_up", map[string]string{"client": "simple"}) == 0
+	}, testTimeout, 10*time.Millisecond)
+}
@@ -0,0 +1,278 @@
+package nats
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"iter"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+)
+
+// PullConsumer fetches messages from a consumer on demand, for batch jobs and periodic processors
+// that pull exactly what they need instead of consuming continuously.
+// Fetched messages are not acknowledged automatically: call Message.Respond for each
+// before the consumer AckWait expires, otherwise they are redelivered.
+// Metrics and traces are recorded like for handled messages, from delivery until Message.Respond.
+type PullConsumer struct {
+	logger   *zap.Logger
+	consumer jetstream.Consumer
+	metrics  *Metrics
+	tracing  *Tracing
+	// client labels the metrics of the consumer
+	client string
+	// maxBatch and maxWait are the request limits of the consumer, zero for none
+	maxBatch int
+	maxWait  time.Duration
+}
+
+// CreatePullConsumer creates a pull consumer for the stream, durable unless WithEphemeral is given,
+// without starting consumption. opts customize the consumer configuration; WithWorkerPool and
+// WithThrottle only apply to CreateConsumer and are ignored.
+// Like other consumers they are deleted once inactive for the inactive threshold; durable consumers
+// idle between runs for longer should disable it with WithInactiveThreshold(0).
+func (c *JetStreamClient) CreatePullConsumer(
+	ctx context.Context,
+	name string,
+	opts ...ConsumerOption,
+) (*PullConsumer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("context error: %w", err)
+	}
+
+	consumerOpts, err := c.consumerOptions(name, "Pull consumer", opts)
+	if err != nil {
+		return nil, err
+	}
+
+	consumer, err := c.stream.CreateOrUpdateConsumer(ctx, consumerOpts.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
+	}
+
+	return &PullConsumer{
+		logger:   c.logger,
+		consumer: consumer,
+		metrics:  c.metrics,
+		tracing:  c.tracing,
+		client:   c.name,
+		maxBatch: consumerOpts.config.MaxRequestBatch,
+		maxWait:  consumerOpts.config.MaxRequestExpires,
+	}, nil
+}
+
+// Name returns the consumer name.
+func (p *PullConsumer) Name() string {
+	return p.consumer.CachedInfo().Name
+}
+
+// Fetch pulls up to batch messages, returning as soon as batch messages arrived or maxWait elapsed,
+// whichever comes first. An empty result means no messages were available within maxWait.
+// batch and maxWait may not exceed the consumer request limits, see WithRequestLimits;
+// maxWait is shortened to the ctx deadline. When ctx ends first the messages received so far
+// are nak'd for immediate redelivery and the context error is returned.
+func (p *PullConsumer) Fetch(ctx context.Context, batch int, maxWait time.Duration) ([]*Message, error) {
+	fetched, err := p.fetch(ctx, batch, maxWait)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := make([]*Message, 0, len(fetched))
+	for _, f := range fetched {
+		msgs = append(msgs, p.track(ctx, f))
+	}
+
+	return msgs, nil
+}
+
+// fetch pulls up to batch messages like Fetch without tracking them.
+func (p *PullConsumer) fetch(ctx context.Context, batch int, maxWait time.Duration) ([]*pulled, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("context error: %w", err)
+	}
+
+	if batch <= 0 {
+		return nil, fmt.Errorf("batch %d must be positive: %w", batch, ErrInvalidConfig)
+	}
+
+	if p.maxBatch > 0 && batch > p.maxBatch {
+		return nil, fmt.Errorf("batch %d exceeds the request limit %d: %w", batch, p.maxBatch, ErrInvalidConfig)
+	}
+
+	if maxWait <= 0 {
+		return nil, fmt.Errorf("max wait %s must be positive: %w", maxWait, ErrInvalidConfig)
+	}
+
+	if p.maxWait > 0 && maxWait > p.maxWait {
+		return nil, fmt.Errorf("max wait %s exceeds the request limit %s: %w", maxWait, p.maxWait, ErrInvalidConfig)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = min(maxWait, time.Until(deadline))
+	}
+
+	res, err := p.consumer.Fetch(batch, jetstream.FetchMaxWait(max(maxWait, time.Millisecond)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch messages: %w", err)
+	}
+
+	return p.collect(ctx, res, batch)
+}
+
+// collect reads the messages of a pull request until it completes.
+func (p *PullConsumer) collect(ctx context.Context, res jetstream.MessageBatch, batch int) ([]*pulled, error) {
+	msgs := make([]*pulled, 0, batch)
+
+	for {
+		select {
+		case raw, ok := <-res.Messages():
+			if !ok {
+				if err := res.Error(); err != nil {
+					p.release(msgs)
+
+					return nil, fmt.Errorf("failed to fetch messages: %w", err)
+				}
+
+				return msgs, nil
+			}
+
+			if msg, ok := readMessage(p.logger, raw); ok {
+				msgs = append(msgs, newPulled(raw, msg))
+			}
+		case <-ctx.Done():
+			p.release(msgs)
+
+			// Messages still arriving for the abandoned request are released until it expires
+			go func() {
+				for raw := range res.Messages() {
+					p.nak(raw)
+				}
+			}()
+
+			return nil, fmt.Errorf("context error: %w", ctx.Err())
+		}
+	}
+}
+
+// Messages iterates over messages of the consumer as they arrive until ctx ends or the loop breaks:
+//
+//	for msg, err := range consumer.Messages(ctx) {
+//		if err != nil {
+//			return err
+//		}
+//		msg.Respond(handle(msg))
+//	}
+//
+// Each pull takes the messages available right away, up to the consumer request batch limit,
+// or waits for the next one; a slow loop should lower the limit with WithRequestLimits to stay within
+// the AckWait. Pulled messages not yet yielded when the iteration stops are nak'd for immediate
+// redelivery. A failed pull is yielded as an error and ends the iteration, while ctx ending stops it
+// without one.
+func (p *PullConsumer) Messages(ctx context.Context) iter.Seq2[*Message, error] {
+	batch := cmp.Or(p.maxBatch, DefaultMaxRequestBatch)
+	maxWait := cmp.Or(p.maxWait, DefaultRequestTimeout)
+
+	return func(yield func(*Message, error) bool) {
+		for ctx.Err() == nil {
+			msgs, err := p.next(ctx, batch, maxWait)
+			if err != nil {
+				if ctx.Err() == nil {
+					yield(nil, err)
+				}
+
+				return
+			}
+
+			for i, msg := range msgs {
+				if ctx.Err() != nil {
+					p.release(msgs[i:])
+
+					return
+				}
+
+				if !yield(p.track(ctx, msg), nil) {
+					p.release(msgs[i+1:])
+
+					return
+				}
+			}
+		}
+	}
+}
+
+// next pulls the messages available right away, up to batch, or else waits up to maxWait for the first one.
+// Unlike a pull request for batch messages, neither leaves a request waiting for more once they return.
+func (p *PullConsumer) next(ctx context.Context, batch int, maxWait time.Duration) ([]*pulled, error) {
+	res, err := p.consumer.FetchNoWait(batch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch messages: %w", err)
+	}
+
+	msgs, err := p.collect(ctx, res, batch)
+	if err != nil || len(msgs) > 0 {
+		return msgs, err
+	}
+
+	return p.fetch(ctx, 1, maxWait)
+}
+
+// track records the delivery of a fetched message in the consumer metrics and starts its consumer span,
+// returning the message to hand out. Both record the result once it is responded to,
+// like for messages passed to a Handler.
+func (p *PullConsumer) track(ctx context.Context, f *pulled) *Message {
+	name := p.Name()
+	handled := p.metrics.observeConsumed(p.client, name, f.msg)
+	_, end := p.tracing.startConsume(ctx, name, f.msg)
+
+	f.finish = func(res Result) {
+		end(res)
+		handled(res)
+	}
+
+	return f.msg
+}
+
+// release naks fetched messages that will not be handled so they are redelivered right away.
+func (p *PullConsumer) release(msgs []*pulled) {
+	for _, msg := range msgs {
+		p.nak(msg.raw)
+	}
+}
+
+// nak requests immediate redelivery of raw.
+func (p *PullConsumer) nak(raw jetstream.Msg) {
+	if err := raw.Nak(); err != nil {
+		p.logger.Error("failed to release message", zap.Error(err))
+	}
+}
+
+// pulled is a fetched message acknowledging the JetStream message it was read from.
+type pulled struct {
+	raw jetstream.Msg
+	msg *Message
+	// finish records the result once the message is responded to, nil until it is handed out
+	finish func(Result)
+}
+
+// newPulled wraps msg read from raw so responding to it is recorded once tracked.
+func newPulled(raw jetstream.Msg, msg *Message) *pulled {
+	f := &pulled{raw: raw, msg: nil, finish: nil}
+	f.msg = eventprocessor.NewMessage(msg.Subject, msg.Data, msg.Headers, msg.Metadata, f)
+
+	return f
+}
+
+// Respond implements eventprocessor.Acknowledger.
+func (f *pulled) Respond(res Result) error {
+	err := respond(f.raw, res)
+
+	if f.finish != nil && res.Action != ActionInProgress {
+		f.finish(res)
+		f.finish = nil
+	}
+
+	return err
+}
@@ -0,0 +1,192 @@
+package nats_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullConsumer(t *testing.T) { //nolint: funlen
+	t.Parallel()
+
+	cfg := natstest.NewConfig(t)
+
+	client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+		Name:     "TEST_PULL",
+		Subjects: []string{"test.pull.>"},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close(context.Background()) })
+
+	// Subtests start once the parent returns, so each bounds its own run
+	withTimeout := func(t *testing.T) context.Context {
+		t.Helper()
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		t.Cleanup(cancel)
+
+		return ctx
+	}
+
+	for range 5 {
+		require.NoError(t, client.PublishToStream(withTimeout(t), "test.pull.job", []byte("data")))
+	}
+
+	sequences := func(msgs []*nats.Message) []uint64 {
+		seqs := make([]uint64, 0, len(msgs))
+		for _, msg := range msgs {
+			seqs = append(seqs, msg.Metadata.Sequence.Stream)
+		}
+
+		return seqs
+	}
+
+	t.Run("Fetch", func(t *testing.T) {
+		t.Parallel()
+		ctx := withTimeout(t)
+
+		consumer, err := client.CreatePullConsumer(ctx, "fetch")
+		require.NoError(t, err)
+		assert.Equal(t, "fetch", consumer.Name())
+
+		msgs, err := consumer.Fetch(ctx, 3, time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{1, 2, 3}, sequences(msgs))
+
+		require.NoError(t, msgs[0].Respond(nats.Ack()))
+		require.NoError(t, msgs[1].Respond(nats.Ack()))
+		require.NoError(t, msgs[2].Respond(nats.Nak(0, nil)))
+
+		msgs, err = consumer.Fetch(ctx, 10, 200*time.Millisecond)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []uint64{3, 4, 5}, sequences(msgs))
+
+		for _, msg := range msgs {
+			require.NoError(t, msg.Respond(nats.Ack()))
+		}
+
+		start := time.Now()
+		msgs, err = consumer.Fetch(ctx, 10, 200*time.Millisecond)
+		require.NoError(t, err)
+		assert.Empty(t, msgs)
+		assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	})
+
+	t.Run("InvalidRequest", func(t *testing.T) {
+		t.Parallel()
+		ctx := withTimeout(t)
+
+		consumer, err := client.CreatePullConsumer(ctx, "invalid", nats.WithRequestLimits(10, 0))
+		require.NoError(t, err)
+
+		_, err = consumer.Fetch(ctx, 0, time.Second)
+		require.ErrorIs(t, err, nats.ErrInvalidConfig)
+		_, err = consumer.Fetch(ctx, 11, time.Second)
+		require.ErrorIs(t, err, nats.ErrInvalidConfig)
+		_, err = consumer.Fetch(ctx, 10, 0)
+		require.ErrorIs(t, err, nats.ErrInvalidConfig)
+		_, err = consumer.Fetch(ctx, 10, time.Hour)
+		require.ErrorIs(t, err, nats.ErrInvalidConfig)
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err = consumer.Fetch(canceled, 10, time.Second)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("Messages", func(t *testing.T) {
+		t.Parallel()
+		ctx := withTimeout(t)
+
+		consumer, err := client.CreatePullConsumer(ctx, "messages")
+		require.NoError(t, err)
+
+		// Breaking out of the loop releases the rest of the fetched batch
+		var got []uint64
+		for msg, err := range consumer.Messages(ctx) {
+			require.NoError(t, err)
+			require.NoError(t, msg.Respond(nats.Ack()))
+
+			if got = append(got, msg.Metadata.Sequence.Stream); len(got) == 3 {
+				break
+			}
+		}
+		assert.Equal(t, []uint64{1, 2, 3}, got)
+
+		// The iteration ends cleanly once its context is done
+		iterCtx, iterCancel := context.WithCancel(ctx)
+		defer iterCancel()
+
+		got = nil
+		for msg, err := range consumer.Messages(iterCtx) {
+			require.NoError(t, err)
+			require.NoError(t, msg.Respond(nats.Ack()))
+			assert.Equal(t, uint64(2), msg.Metadata.NumDelivered)
+
+			if got = append(got, msg.Metadata.Sequence.Stream); len(got) == 2 {
+				iterCancel()
+			}
+		}
+		assert.Equal(t, []uint64{4, 5}, got)
+	})
+}
+
+func TestPullConsumerInactiveThreshold(t *testing.T) {
+	t.Parallel()
+
+	cfg := natstest.NewConfig(t)
+
+	client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+		Name:     "TEST_PULL_INACTIVE",
+		Subjects: []string{"test.pullinactive.>"},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close(context.Background()) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	nc, err := natsgo.Connect(cfg.URL)
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+
+	js, err := jetstream.New(nc)
+	require.NoError(t, err)
+
+	defaultThreshold := cfg.ReconnectWait * nats.DefaultInactiveThresholdMultiplier
+
+	push, err := client.CreateConsumer(ctx, "push", func(context.Context, *nats.Message) nats.Result {
+		return nats.Ack()
+	})
+	require.NoError(t, err)
+	t.Cleanup(push.Stop)
+
+	_, err = client.CreatePullConsumer(ctx, "pull")
+	require.NoError(t, err)
+	_, err = client.CreatePullConsumer(ctx, "nightly", nats.WithInactiveThreshold(0))
+	require.NoError(t, err)
+
+	tests := []struct {
+		consumer  string
+		threshold time.Duration
+	}{
+		{consumer: "push", threshold: defaultThreshold},
+		{consumer: "pull", threshold: defaultThreshold},
+		{consumer: "nightly", threshold: 0},
+	}
+	for _, tt := range tests {
+		consumer, err := js.Consumer(ctx, "TEST_PULL_INACTIVE", tt.consumer)
+		require.NoError(t, err)
+
+		info, err := consumer.Info(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, tt.threshold, info.Config.InactiveThreshold, tt.consumer)
+	}
+}
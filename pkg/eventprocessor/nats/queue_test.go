@@ -0,0 +1,122 @@
+package nats_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueGroups(t *testing.T) { //nolint: funlen
+	t.Parallel()
+
+	const (
+		testTimeout = 5 * time.Second
+		messages    = 50
+	)
+
+	cfg := natstest.NewConfig(t)
+
+	t.Run("LoadBalanced", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := context.Background()
+		replicas := make([]*nats.SimpleNatsClient, 2)
+		counts := make([]atomic.Int64, len(replicas))
+		for i := range replicas {
+			client, err := nats.NewSimpleNatsClient(cfg)
+			require.NoError(t, err)
+			t.Cleanup(func() { client.Close(ctx) })
+			replicas[i] = client
+
+			sub, err := client.Subscribe("test.queue.orders", func([]byte) { counts[i].Add(1) },
+				nats.WithQueueGroup("workers"))
+			require.NoError(t, err)
+			assert.Equal(t, "workers", sub.Queue())
+		}
+
+		// A subscriber outside the group still receives every message
+		var all atomic.Int64
+		_, err := replicas[0].Subscribe("test.queue.orders", func([]byte) { all.Add(1) })
+		require.NoError(t, err)
+		require.NoError(t, replicas[0].Flush(ctx))
+		require.NoError(t, replicas[1].Flush(ctx))
+
+		for i := range messages {
+			require.NoError(t, replicas[0].PublishToStream(ctx, "test.queue.orders", []byte(fmt.Sprint(i))))
+		}
+
+		require.Eventually(t, func() bool {
+			return counts[0].Load()+counts[1].Load() == messages && all.Load() == messages
+		}, testTimeout, 10*time.Millisecond)
+		assert.Positive(t, counts[0].Load())
+		assert.Positive(t, counts[1].Load())
+	})
+
+	t.Run("Channel", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		client, err := nats.NewSimpleNatsClient(cfg)
+		require.NoError(t, err)
+		defer client.Close(ctx)
+
+		_, err = client.SubscribeChan("test.queue.chan", nil)
+		require.ErrorIs(t, err, nats.ErrInvalidConfig)
+
+		ch := make(chan []byte, 1)
+		sub, err := client.SubscribeChan("test.queue.chan", ch, nats.WithQueueGroup("chan-workers"))
+		require.NoError(t, err)
+		assert.Equal(t, "chan-workers", sub.Queue())
+
+		for i := range 3 {
+			require.NoError(t, client.PublishToStream(ctx, "test.queue.chan", []byte(fmt.Sprint(i))))
+		}
+
+		for i := range 3 {
+			select {
+			case data := <-ch:
+				assert.Equal(t, fmt.Sprint(i), string(data))
+			case <-ctx.Done():
+				t.Fatal("timed out waiting for message")
+			}
+		}
+	})
+
+	t.Run("Respond", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		for range 2 {
+			client, err := nats.NewSimpleNatsClient(cfg)
+			require.NoError(t, err)
+			t.Cleanup(func() { client.Close(context.Background()) })
+
+			_, err = client.Respond("test.queue.rpc", func(_ context.Context, data []byte) ([]byte, error) {
+				return data, nil
+			}, nats.WithQueueGroup("responders"))
+			require.NoError(t, err)
+		}
+
+		requester, err := nats.NewSimpleNatsClient(cfg)
+		require.NoError(t, err)
+		defer requester.Close(ctx)
+
+		gatherCtx, gatherCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		defer gatherCancel()
+
+		replies, err := requester.ScatterGather(gatherCtx, "test.queue.rpc", []byte("ping"), 0)
+		require.NoError(t, err)
+		assert.Len(t, replies, 1)
+	})
+}
@@ -0,0 +1,227 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// defaultDuplicateWindow is the Duplicates window the server applies when none is configured.
+const defaultDuplicateWindow = 2 * time.Minute
+
+// ErrDestructiveChange is returned when reconciling a stream would require a change that loses stored messages.
+var ErrDestructiveChange = errors.New("destructive stream change")
+
+// StreamChange is a setting that differs between an existing stream and the desired configuration.
+type StreamChange struct {
+	// Field is the jetstream.StreamConfig field name
+	Field string
+	// From is the current value
+	From string
+	// To is the desired value
+	To string
+	// Destructive is set for changes that are refused because they lose or rewrite stored messages
+	Destructive bool
+}
+
+// String formats the change as "Field: from -> to".
+func (c StreamChange) String() string {
+	s := fmt.Sprintf("%s: %s -> %s", c.Field, c.From, c.To)
+	if c.Destructive {
+		s += " (destructive)"
+	}
+
+	return s
+}
+
+// StreamDiff lists the changes needed to bring a stream to the desired configuration.
+type StreamDiff []StreamChange
+
+// Destructive returns the destructive changes of d.
+func (d StreamDiff) Destructive() StreamDiff {
+	var destructive StreamDiff
+
+	for _, change := range d {
+		if change.Destructive {
+			destructive = append(destructive, change)
+		}
+	}
+
+	return destructive
+}
+
+// String formats the changes separated by commas.
+func (d StreamDiff) String() string {
+	changes := make([]string, len(d))
+	for i, change := range d {
+		changes[i] = change.String()
+	}
+
+	return strings.Join(changes, ", ")
+}
+
+// DiffStreamConfig compares the configuration of an existing stream with the desired one.
+// Unset fields of desired are compared as the defaults the server applies to them.
+// Changes of Storage, Retention or Mirror, which the server refuses or which rewrite the stream, and tighter
+// MaxMsgs, MaxBytes, MaxAge or MaxMsgsPerSubject limits which would discard stored messages, are marked destructive.
+// Name, FirstSeq, Sealed, MirrorDirect and Template are not compared: they identify the stream,
+// only apply on creation or are set by the server.
+func DiffStreamConfig(current, desired jetstream.StreamConfig) StreamDiff {
+	desired = streamDefaults(desired)
+
+	var d StreamDiff
+
+	diffField(&d, "Description", current.Description, desired.Description, false)
+	diffField(&d, "Subjects", sortedSubjects(current.Subjects), sortedSubjects(desired.Subjects), false)
+	diffField(&d, "Retention", current.Retention, desired.Retention, true)
+	diffField(&d, "Storage", current.Storage, desired.Storage, true)
+	diffField(&d, "MaxConsumers", current.MaxConsumers, desired.MaxConsumers, false)
+	diffField(&d, "MaxMsgs", current.MaxMsgs, desired.MaxMsgs, tighter(current.MaxMsgs, desired.MaxMsgs, -1))
+	diffField(&d, "MaxBytes", current.MaxBytes, desired.MaxBytes, tighter(current.MaxBytes, desired.MaxBytes, -1))
+	diffField(&d, "MaxAge", current.MaxAge, desired.MaxAge, tighter(current.MaxAge, desired.MaxAge, 0))
+	diffField(&d, "MaxMsgsPerSubject", current.MaxMsgsPerSubject, desired.MaxMsgsPerSubject,
+		tighter(current.MaxMsgsPerSubject, desired.MaxMsgsPerSubject, -1))
+	diffField(&d, "MaxMsgSize", current.MaxMsgSize, desired.MaxMsgSize, false)
+	diffField(&d, "Discard", current.Discard, desired.Discard, false)
+	diffField(&d, "DiscardNewPerSubject", current.DiscardNewPerSubject, desired.DiscardNewPerSubject, false)
+	diffField(&d, "Replicas", current.Replicas, desired.Replicas, false)
+	diffField(&d, "NoAck", current.NoAck, desired.NoAck, false)
+	diffField(&d, "Duplicates", current.Duplicates, desired.Duplicates, false)
+	diffField(&d, "DenyDelete", current.DenyDelete, desired.DenyDelete, false)
+	diffField(&d, "DenyPurge", current.DenyPurge, desired.DenyPurge, false)
+	diffField(&d, "AllowRollup", current.AllowRollup, desired.AllowRollup, false)
+	diffField(&d, "AllowDirect", current.AllowDirect, desired.AllowDirect, false)
+	diffField(&d, "Compression", current.Compression, desired.Compression, false)
+	diffField(&d, "Placement", jsonValue(current.Placement), jsonValue(desired.Placement), false)
+	diffField(&d, "Mirror", jsonValue(current.Mirror), jsonValue(desired.Mirror), true)
+	diffField(&d, "Sources", jsonValue(current.Sources), jsonValue(desired.Sources), false)
+	diffField(&d, "SubjectTransform", jsonValue(current.SubjectTransform), jsonValue(desired.SubjectTransform), false)
+	diffField(&d, "RePublish", jsonValue(current.RePublish), jsonValue(desired.RePublish), false)
+	diffField(&d, "ConsumerLimits", current.ConsumerLimits, desired.ConsumerLimits, false)
+	diffField(&d, "Metadata", jsonValue(current.Metadata), jsonValue(desired.Metadata), false)
+
+	return d
+}
+
+// ReconcileStream creates the stream of desired, or brings an existing one to it.
+// Safe changes are applied and returned; destructive ones are refused with an error
+// wrapping ErrDestructiveChange, returning the full diff and leaving the stream unchanged.
+func ReconcileStream( //nolint: ireturn
+	ctx context.Context,
+	js jetstream.JetStream,
+	desired jetstream.StreamConfig,
+) (jetstream.Stream, StreamDiff, error) {
+	stream, err := js.Stream(ctx, desired.Name)
+	if errors.Is(err, jetstream.ErrStreamNotFound) {
+		stream, err = js.CreateStream(ctx, desired)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create stream: %w", err)
+		}
+
+		return stream, nil, nil
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to look up stream %s: %w", desired.Name, err)
+	}
+
+	diff := DiffStreamConfig(stream.CachedInfo().Config, desired)
+	if destructive := diff.Destructive(); len(destructive) > 0 {
+		return nil, diff, fmt.Errorf("failed to reconcile stream %s: %w: %s", desired.Name, ErrDestructiveChange, destructive)
+	}
+
+	if len(diff) == 0 {
+		return stream, nil, nil
+	}
+
+	stream, err = js.UpdateStream(ctx, desired)
+	if err != nil {
+		return nil, diff, fmt.Errorf("failed to update stream %s: %w", desired.Name, err)
+	}
+
+	return stream, diff, nil
+}
+
+// streamDefaults fills the unset fields of cfg with the values the server applies.
+func streamDefaults(cfg jetstream.StreamConfig) jetstream.StreamConfig {
+	for _, limit := range []*int64{&cfg.MaxMsgs, &cfg.MaxBytes, &cfg.MaxMsgsPerSubject} {
+		if *limit == 0 {
+			*limit = -1
+		}
+	}
+
+	if cfg.MaxConsumers == 0 {
+		cfg.MaxConsumers = -1
+	}
+
+	if cfg.MaxMsgSize == 0 {
+		cfg.MaxMsgSize = -1
+	}
+
+	if cfg.Replicas == 0 {
+		cfg.Replicas = 1
+	}
+
+	if cfg.Duplicates == 0 && cfg.Mirror == nil {
+		cfg.Duplicates = defaultDuplicateWindow
+		if cfg.MaxAge > 0 && cfg.MaxAge < defaultDuplicateWindow {
+			cfg.Duplicates = cfg.MaxAge
+		}
+	}
+
+	if len(cfg.Subjects) == 0 && cfg.Mirror == nil && len(cfg.Sources) == 0 {
+		cfg.Subjects = []string{cfg.Name}
+	}
+
+	return cfg
+}
+
+// diffField appends a change to d when from and to differ.
+func diffField[T comparable](d *StreamDiff, field string, from, to T, destructive bool) {
+	if from == to {
+		return
+	}
+
+	*d = append(*d, StreamChange{
+		Field:       field,
+		From:        fmt.Sprint(from),
+		To:          fmt.Sprint(to),
+		Destructive: destructive,
+	})
+}
+
+// tighter reports whether limit to is stricter than from, where unlimited means no limit.
+func tighter[T int64 | time.Duration](from, to, unlimited T) bool {
+	return to != unlimited && (from == unlimited || to < from)
+}
+
+// jsonValue encodes a nested setting as JSON so it compares by value, with map keys sorted.
+// Unset and empty settings both encode as an empty string.
+func jsonValue[T any](v T) string {
+	var b strings.Builder
+
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+
+	switch s := strings.TrimSpace(b.String()); s {
+	case "null", "[]", "{}":
+		return ""
+	default:
+		return s
+	}
+}
+
+// sortedSubjects joins subjects in sorted order so subject lists compare regardless of order.
+func sortedSubjects(subjects []string) string {
+	return strings.Join(slices.Sorted(slices.Values(subjects)), ",")
+}
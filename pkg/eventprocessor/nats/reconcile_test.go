@@ -0,0 +1,162 @@
+package nats_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffStreamConfig(t *testing.T) {
+	t.Parallel()
+
+	current := jetstream.StreamConfig{ //nolint: exhaustruct
+		Name:              "ORDERS",
+		Subjects:          []string{"orders.created", "orders.shipped"},
+		Retention:         jetstream.LimitsPolicy,
+		Storage:           jetstream.FileStorage,
+		MaxConsumers:      -1,
+		MaxMsgs:           1000,
+		MaxBytes:          -1,
+		MaxAge:            time.Hour,
+		MaxMsgsPerSubject: -1,
+		MaxMsgSize:        -1,
+		Replicas:          1,
+		Duplicates:        2 * time.Minute,
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*jetstream.StreamConfig)
+		want   []string
+	}{
+		{name: "unchanged", modify: func(*jetstream.StreamConfig) {}, want: nil},
+		{
+			name: "server defaults",
+			modify: func(c *jetstream.StreamConfig) {
+				c.MaxConsumers, c.MaxBytes, c.MaxMsgsPerSubject, c.MaxMsgSize, c.Replicas, c.Duplicates = 0, 0, 0, 0, 0, 0
+			},
+			want: nil,
+		},
+		{
+			name:   "subject order",
+			modify: func(c *jetstream.StreamConfig) { c.Subjects = []string{"orders.shipped", "orders.created"} },
+			want:   nil,
+		},
+		{
+			name:   "safe changes",
+			modify: func(c *jetstream.StreamConfig) { c.Description, c.MaxMsgs, c.MaxAge = "orders", 2000, 0 },
+			want:   []string{"Description:  -> orders", "MaxMsgs: 1000 -> 2000", "MaxAge: 1h0m0s -> 0s"},
+		},
+		{
+			name:   "storage",
+			modify: func(c *jetstream.StreamConfig) { c.Storage = jetstream.MemoryStorage },
+			want:   []string{"Storage: File -> Memory (destructive)"},
+		},
+		{
+			name:   "retention",
+			modify: func(c *jetstream.StreamConfig) { c.Retention = jetstream.WorkQueuePolicy },
+			want:   []string{"Retention: Limits -> WorkQueue (destructive)"},
+		},
+		{
+			name:   "tighter limits",
+			modify: func(c *jetstream.StreamConfig) { c.MaxMsgs, c.MaxBytes = 10, 1024 },
+			want:   []string{"MaxMsgs: 1000 -> 10 (destructive)", "MaxBytes: -1 -> 1024 (destructive)"},
+		},
+		{
+			name: "nested settings",
+			modify: func(c *jetstream.StreamConfig) {
+				c.Metadata = map[string]string{"team": "orders"}
+				c.RePublish = &jetstream.RePublish{Source: ">", Destination: "audit.>", HeadersOnly: true}
+				c.Mirror = &jetstream.StreamSource{Name: "UPSTREAM"} //nolint: exhaustruct
+			},
+			want: []string{
+				`Mirror:  -> {"name":"UPSTREAM"} (destructive)`,
+				`RePublish:  -> {"src":">","dest":"audit.>","headers_only":true}`,
+				`Metadata:  -> {"team":"orders"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		desired := current
+		tt.modify(&desired)
+
+		var got []string
+		for _, change := range nats.DiffStreamConfig(current, desired) {
+			got = append(got, change.String())
+		}
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
+
+func TestReconcileStream(t *testing.T) {
+	t.Parallel()
+
+	cfg := natstest.NewConfig(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	nc, err := natsgo.Connect(cfg.URL)
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+
+	js, err := jetstream.New(nc)
+	require.NoError(t, err)
+
+	desired := jetstream.StreamConfig{ //nolint: exhaustruct
+		Name:     "TEST_RECONCILE",
+		Subjects: []string{"test.reconcile.>"},
+		MaxAge:   time.Minute,
+	}
+
+	_, diff, err := nats.ReconcileStream(ctx, js, desired)
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+
+	// Server defaults of a created stream are not reported as drift
+	client, err := nats.NewJetStreamClient(cfg, desired)
+	require.NoError(t, err)
+	assert.Empty(t, client.StreamChanges())
+	require.NoError(t, client.PublishToStream(ctx, "test.reconcile.created", []byte("data")))
+	require.NoError(t, client.Close(ctx))
+
+	desired.Description = "reconciled"
+	desired.MaxAge = time.Hour
+	client, err = nats.NewJetStreamClient(cfg, desired)
+	require.NoError(t, err)
+	assert.Equal(t, nats.StreamDiff{
+		{Field: "Description", From: "", To: "reconciled", Destructive: false},
+		{Field: "MaxAge", From: "1m0s", To: "1h0m0s", Destructive: false},
+		// The default duplicate window follows MaxAge up to two minutes
+		{Field: "Duplicates", From: "1m0s", To: "2m0s", Destructive: false},
+	}, client.StreamChanges())
+	require.NoError(t, client.Close(ctx))
+
+	// Drift of nested settings is detected and applied
+	desired.Metadata = map[string]string{"owner": "orders"}
+	client, err = nats.NewJetStreamClient(cfg, desired)
+	require.NoError(t, err)
+	assert.Equal(t, nats.StreamDiff{
+		{Field: "Metadata", From: "", To: `{"owner":"orders"}`, Destructive: false},
+	}, client.StreamChanges())
+	require.NoError(t, client.Close(ctx))
+
+	destructive := desired
+	destructive.Storage = jetstream.MemoryStorage
+	_, err = nats.NewJetStreamClient(cfg, destructive)
+	require.ErrorIs(t, err, nats.ErrDestructiveChange)
+	assert.Contains(t, err.Error(), "Storage: File -> Memory")
+
+	stream, err := js.Stream(ctx, desired.Name)
+	require.NoError(t, err)
+	assert.Equal(t, jetstream.FileStorage, stream.CachedInfo().Config.Storage)
+	assert.Equal(t, "reconciled", stream.CachedInfo().Config.Description)
+	assert.Equal(t, uint64(1), stream.CachedInfo().State.Msgs)
+	assert.Equal(t, map[string]string{"owner": "orders"}, stream.CachedInfo().Config.Metadata)
+}
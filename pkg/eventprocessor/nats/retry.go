@@ -0,0 +1,129 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// ErrPermanent marks handler errors that must not be retried.
+var ErrPermanent = errors.New("permanent error")
+
+// Permanent wraps err so retry policies terminate the message instead of redelivering it.
+func Permanent(err error) error {
+	return fmt.Errorf("%w: %w", ErrPermanent, err)
+}
+
+// ErrorHandler processes a consumed message and returns nil on success.
+// Use RetryPolicy.Handler to turn it into a Handler.
+type ErrorHandler func(ctx context.Context, msg *Message) error
+
+// RetryPolicy describes how failed messages are redelivered.
+// It is applied to a consumer with WithRetryPolicy, which sets the JetStream MaxDeliver,
+// and to a handler with Handler, which naks failures with the scheduled delay.
+type RetryPolicy struct {
+	// MaxDeliver is the maximum number of deliveries including the first one, zero means unlimited
+	MaxDeliver int
+	// Delays is the redelivery schedule; Delays[i] is waited after delivery i+1 fails and the last delay repeats
+	Delays []time.Duration
+	// Jitter randomizes every delay by up to this fraction in both directions, between 0 and 1
+	Jitter float64
+	// Retryable reports whether an error should be retried, nil retries everything not wrapped with Permanent
+	Retryable func(err error) bool
+}
+
+// FixedRetry returns a policy waiting delay between each of up to maxDeliver deliveries.
+func FixedRetry(delay time.Duration, maxDeliver int) RetryPolicy {
+	return RetryPolicy{MaxDeliver: maxDeliver, Delays: []time.Duration{delay}, Jitter: 0, Retryable: nil}
+}
+
+// ExponentialRetry returns a policy starting at initial and multiplying the delay by multiplier
+// after every failure, capped at maxDelay, with the given jitter fraction.
+// A non-positive initial falls back to DefaultRetryDelay, and a maxDelay below initial to initial.
+func ExponentialRetry(initial, maxDelay time.Duration, multiplier, jitter float64, maxDeliver int) RetryPolicy {
+	if initial <= 0 {
+		initial = DefaultRetryDelay
+	}
+
+	maxDelay = max(maxDelay, initial)
+
+	var delays []time.Duration
+
+	for delay := float64(initial); ; delay *= multiplier {
+		if delay >= float64(maxDelay) || multiplier <= 1 {
+			delays = append(delays, time.Duration(math.Min(delay, float64(maxDelay))))
+
+			break
+		}
+
+		delays = append(delays, time.Duration(delay))
+
+		if maxDeliver > 0 && len(delays) >= maxDeliver-1 {
+			break
+		}
+	}
+
+	return RetryPolicy{MaxDeliver: maxDeliver, Delays: delays, Jitter: jitter, Retryable: nil}
+}
+
+// ScheduleRetry returns a policy using the given delays in order, delivering at most len(delays)+1 times.
+func ScheduleRetry(delays ...time.Duration) RetryPolicy {
+	return RetryPolicy{MaxDeliver: len(delays) + 1, Delays: delays, Jitter: 0, Retryable: nil}
+}
+
+// Delay returns how long to wait before redelivering a message whose delivery-th delivery failed.
+func (p RetryPolicy) Delay(delivery uint64) time.Duration {
+	if len(p.Delays) == 0 || delivery == 0 {
+		return 0
+	}
+
+	idx := min(delivery-1, uint64(len(p.Delays)-1))
+	delay := p.Delays[idx]
+
+	if p.Jitter > 0 {
+		// Jitter only spreads retries, it does not need a cryptographic source
+		factor := 1 + p.Jitter*(2*rand.Float64()-1) //nolint: gosec
+		delay = time.Duration(float64(delay) * factor)
+	}
+
+	return delay
+}
+
+// Handler adapts handler to a Handler applying the policy.
+// A nil error acks the message. Permanent errors, and errors on the last allowed delivery,
+// terminate the message. Other errors nak it with the scheduled delay.
+func (p RetryPolicy) Handler(handler ErrorHandler) Handler {
+	return func(ctx context.Context, msg *Message) Result {
+		err := handler(ctx, msg)
+		if err == nil {
+			return Ack()
+		}
+
+		delivery := msg.Metadata.NumDelivered
+
+		switch {
+		case !p.retryable(err):
+			return Term(err)
+		case p.MaxDeliver > 0 && delivery >= uint64(p.MaxDeliver):
+			return Term(fmt.Errorf("%w after %d deliveries: %w", ErrMaxDeliveries, delivery, err))
+		default:
+			return Nak(p.Delay(delivery), err)
+		}
+	}
+}
+
+// retryable classifies err using the policy classifier.
+func (p RetryPolicy) retryable(err error) bool {
+	if errors.Is(err, ErrPermanent) {
+		return false
+	}
+
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+
+	return true
+}
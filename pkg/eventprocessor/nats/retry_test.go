@@ -0,0 +1,191 @@
+package nats_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicySchedule(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		policy nats.RetryPolicy
+		delays []time.Duration
+	}{
+		{
+			name:   "fixed",
+			policy: nats.FixedRetry(time.Second, 3),
+			delays: []time.Duration{time.Second, time.Second, time.Second},
+		},
+		{
+			name:   "exponential capped",
+			policy: nats.ExponentialRetry(100*time.Millisecond, time.Second, 2, 0, 0),
+			delays: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second},
+		},
+		{
+			name:   "exponential limited by deliveries",
+			policy: nats.ExponentialRetry(100*time.Millisecond, time.Minute, 3, 0, 3),
+			delays: []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond},
+		},
+		{
+			name:   "exponential without initial delay",
+			policy: nats.ExponentialRetry(0, 0, 2, 0, 3),
+			delays: []time.Duration{nats.DefaultRetryDelay, nats.DefaultRetryDelay, nats.DefaultRetryDelay},
+		},
+		{
+			name:   "exponential maximum below initial",
+			policy: nats.ExponentialRetry(time.Second, -time.Minute, 2, 0, 0),
+			delays: []time.Duration{time.Second, time.Second},
+		},
+		{
+			name:   "schedule",
+			policy: nats.ScheduleRetry(time.Second, time.Minute, time.Hour),
+			delays: []time.Duration{time.Second, time.Minute, time.Hour, time.Hour},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			for i, want := range tt.delays {
+				assert.Equal(t, want, tt.policy.Delay(uint64(i+1)), "delivery %d", i+1)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyJitter(t *testing.T) {
+	t.Parallel()
+
+	policy := nats.ExponentialRetry(time.Second, time.Minute, 2, 0.5, 0)
+	for range 100 {
+		delay := policy.Delay(2)
+		assert.GreaterOrEqual(t, delay, time.Second)
+		assert.LessOrEqual(t, delay, 3*time.Second)
+	}
+}
+
+func TestRetryPolicyHandler(t *testing.T) {
+	t.Parallel()
+
+	errTransient := errors.New("transient")
+	errValidation := errors.New("validation")
+	policy := nats.FixedRetry(time.Second, 3)
+	policy.Retryable = func(err error) bool { return !errors.Is(err, errValidation) }
+
+	tests := []struct {
+		name      string
+		err       error
+		delivery  uint64
+		action    nats.Action
+		delay     time.Duration
+		permanent bool
+	}{
+		{name: "success", err: nil, delivery: 1, action: nats.ActionAck},
+		{name: "transient", err: errTransient, delivery: 1, action: nats.ActionNak, delay: time.Second},
+		{name: "permanent", err: nats.Permanent(errTransient), delivery: 1, action: nats.ActionTerm, permanent: true},
+		{name: "classified permanent", err: errValidation, delivery: 1, action: nats.ActionTerm},
+		{name: "last delivery", err: errTransient, delivery: 3, action: nats.ActionTerm},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			handler := policy.Handler(func(context.Context, *nats.Message) error { return tt.err })
+			res := handler(context.Background(), &nats.Message{ //nolint: exhaustruct
+				Metadata: &eventprocessor.Metadata{NumDelivered: tt.delivery}, //nolint: exhaustruct
+			})
+
+			assert.Equal(t, tt.action, res.Action)
+			assert.Equal(t, tt.delay, res.Delay)
+			assert.Equal(t, tt.permanent, errors.Is(res.Err, nats.ErrPermanent))
+			if tt.err != nil {
+				assert.ErrorIs(t, res.Err, tt.err)
+			}
+		})
+	}
+}
+
+func TestConsumerRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	cfg := natstest.NewConfig(t)
+	client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+		Name:     "TEST_RETRY",
+		Subjects: []string{"test.retry.>"},
+	})
+	require.NoError(t, err)
+	defer client.Close(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	require.NoError(t, client.PublishToStream(ctx, "test.retry.poison", []byte("poison")))
+
+	policy := nats.ScheduleRetry(20*time.Millisecond, 40*time.Millisecond)
+	var deliveries atomic.Uint64
+	cc, err := client.CreateConsumer(ctx, "test-retry", policy.Handler(func(_ context.Context, msg *nats.Message) error {
+		deliveries.Store(msg.Metadata.NumDelivered)
+
+		return errors.New("always fails")
+	}), nats.WithRetryPolicy(policy))
+	require.NoError(t, err)
+	defer cc.Stop()
+
+	require.Eventually(t, func() bool {
+		return deliveries.Load() == 3
+	}, testTimeout, 10*time.Millisecond)
+
+	// The poison message is terminated on its last delivery and not redelivered again
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, uint64(3), deliveries.Load())
+}
+
+func TestConsumerRetryPolicySlowHandler(t *testing.T) {
+	t.Parallel()
+
+	cfg := natstest.NewConfig(t)
+	client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+		Name:     "TEST_RETRY_SLOW",
+		Subjects: []string{"test.retryslow.>"},
+	})
+	require.NoError(t, err)
+	defer client.Close(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	_, err = client.CreateConsumer(ctx, "test-retry-backoff", func(context.Context, *nats.Message) nats.Result {
+		return nats.Ack()
+	}, nats.WithAckWait(time.Second), nats.WithAckBackOff(100*time.Millisecond))
+	require.ErrorIs(t, err, nats.ErrInvalidConfig, "backoff shorter than the ack wait")
+
+	require.NoError(t, client.PublishToStream(ctx, "test.retryslow.job", []byte("job")))
+
+	// A handler slower than the retry delays is not redelivered while it runs
+	policy := nats.ExponentialRetry(50*time.Millisecond, time.Second, 2, 0, 5)
+	var deliveries atomic.Int64
+	cc, err := client.CreateConsumer(ctx, "test-retry-slow", policy.Handler(func(context.Context, *nats.Message) error {
+		deliveries.Add(1)
+		time.Sleep(300 * time.Millisecond)
+
+		return nil
+	}), nats.WithRetryPolicy(policy), nats.WithAckWait(30*time.Second))
+	require.NoError(t, err)
+	defer cc.Stop()
+
+	require.Eventually(t, func() bool {
+		return deliveries.Load() == 1
+	}, testTimeout, 10*time.Millisecond)
+	time.Sleep(time.Second)
+	assert.Equal(t, int64(1), deliveries.Load())
+}
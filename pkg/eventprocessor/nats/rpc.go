@@ -0,0 +1,164 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// ErrRemote is returned when a responder handled a request with an error.
+var ErrRemote = errors.New("remote error")
+
+// RPCErrorHeader carries the error message of a failed request in the reply.
+const RPCErrorHeader = "Rpc-Error"
+
+// RequestHandler handles a request and returns the reply payload.
+// A returned error is sent to the requester, which receives it wrapped in ErrRemote.
+type RequestHandler func(ctx context.Context, data []byte) ([]byte, error)
+
+// Reply is a single reply collected by ScatterGather.
+type Reply struct {
+	// Data is the reply payload
+	Data []byte
+	// Err wraps ErrRemote when the responder failed
+	Err error
+}
+
+// Request sends data to subject and waits for a single reply.
+// The wait ends at the context deadline, or after DefaultRequestTimeout when ctx has none.
+// Returns an error wrapping nats.ErrNoResponders when nobody is subscribed to subject.
+func (c *SimpleNatsClient) Request(ctx context.Context, subject string, data []byte) ([]byte, error) {
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("context error: %w", err)
+	}
+
+	msg := &nats.Msg{Subject: subject, Data: data} //nolint: exhaustruct
+	end := c.config.Tracing.startPublish(ctx, clientSimple, msg)
+
+	reply, err := c.conn.RequestMsgWithContext(ctx, msg)
+	end(err)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to request %s: %w", subject, err)
+	}
+
+	if err := replyError(reply); err != nil {
+		return nil, err
+	}
+
+	return reply.Data, nil
+}
+
+// ScatterGather sends data to every responder on subject and collects up to maxReplies replies.
+// Collection stops after maxReplies replies, or at the context deadline (DefaultRequestTimeout when ctx has none),
+// returning the replies received so far; maxReplies of zero or less collects until the deadline.
+// Cancelling ctx returns the collected replies together with the context error.
+// Returns an error wrapping nats.ErrNoResponders when nobody is subscribed to subject.
+func (c *SimpleNatsClient) ScatterGather(
+	ctx context.Context,
+	subject string,
+	data []byte,
+	maxReplies int,
+) ([]Reply, error) {
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("context error: %w", err)
+	}
+
+	inbox := c.conn.NewRespInbox()
+	replies := make(chan *nats.Msg, max(maxReplies, DefaultReplyBuffer))
+
+	sub, err := c.conn.ChanSubscribe(inbox, replies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to replies: %w", err)
+	}
+	defer sub.Unsubscribe() //nolint: errcheck
+
+	msg := &nats.Msg{Subject: subject, Reply: inbox, Data: data} //nolint: exhaustruct
+	if err := c.publishMsg(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	var collected []Reply
+
+	for maxReplies <= 0 || len(collected) < maxReplies {
+		select {
+		case reply := <-replies:
+			// The server answers with an empty 503 status when nobody is subscribed
+			if len(reply.Data) == 0 && reply.Header.Get("Status") == "503" {
+				return nil, fmt.Errorf("failed to request %s: %w", subject, nats.ErrNoResponders)
+			}
+
+			collected = append(collected, Reply{Data: reply.Data, Err: replyError(reply)})
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return collected, nil
+			}
+
+			return collected, fmt.Errorf("context error: %w", ctx.Err())
+		}
+	}
+
+	return collected, nil
+}
+
+// Respond registers handler to answer requests sent to subject.
+// Messages without a reply subject are handled and the reply discarded.
+func (c *SimpleNatsClient) Respond(
+	subject string,
+	handler RequestHandler,
+	opts ...SubscriptionOption,
+) (*Subscription, error) {
+	return c.subscribe(subject, c.responder(subject, handler), opts)
+}
+
+// responder adapts handler to a nats.MsgHandler that replies to each request.
+func (c *SimpleNatsClient) responder(subject string, handler RequestHandler) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		c.config.Metrics.observeReceived(clientSimple, subject)
+
+		ctx, end := c.config.Tracing.receiveContext(msg)
+		defer end()
+
+		data, err := handler(ctx, msg.Data)
+		if msg.Reply == "" {
+			return
+		}
+
+		reply := &nats.Msg{Subject: msg.Reply, Data: data} //nolint: exhaustruct
+		if err != nil {
+			reply.Header = nats.Header{RPCErrorHeader: []string{err.Error()}}
+			reply.Data = nil
+		}
+
+		if err := msg.RespondMsg(reply); err != nil {
+			c.config.Logger.Error("failed to send reply", zap.String("subject", msg.Subject), zap.Error(err))
+		}
+	}
+}
+
+// replyError returns the responder error carried by reply, if any.
+func replyError(reply *nats.Msg) error {
+	if msg := reply.Header.Get(RPCErrorHeader); msg != "" {
+		return fmt.Errorf("%w: %s", ErrRemote, msg)
+	}
+
+	return nil
+}
+
+// requestContext bounds ctx by DefaultRequestTimeout unless it already has a deadline.
+func requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, DefaultRequestTimeout)
+}
@@ -0,0 +1,119 @@
+package nats_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRequestReply(t *testing.T) { //nolint: funlen
+	t.Parallel()
+
+	cfg := natstest.NewConfig(t)
+
+	client, err := nats.NewSimpleNatsClient(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close(context.Background()) })
+
+	_, err = client.Respond("rpc.echo", func(_ context.Context, data []byte) ([]byte, error) {
+		return append([]byte("echo: "), data...), nil
+	})
+	require.NoError(t, err)
+	_, err = client.Respond("rpc.fail", func(context.Context, []byte) ([]byte, error) {
+		return nil, errors.New("out of stock")
+	})
+	require.NoError(t, err)
+
+	// The slow responder may reply after the test ends, so it does not log to the test
+	slowCfg := *cfg
+	slowCfg.Logger = zap.NewNop()
+	slow, err := nats.NewSimpleNatsClient(&slowCfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = slow.Close(context.Background()) })
+	_, err = slow.Respond("rpc.slow", func(context.Context, []byte) ([]byte, error) {
+		time.Sleep(time.Second)
+
+		return []byte("late"), nil
+	})
+	require.NoError(t, err)
+
+	for i := range 3 {
+		_, err = client.Respond("rpc.inventory", func(context.Context, []byte) ([]byte, error) {
+			return []byte("warehouse-" + strconv.Itoa(i)), nil
+		})
+		require.NoError(t, err)
+	}
+
+	ctx := context.Background()
+
+	t.Run("Request", func(t *testing.T) {
+		t.Parallel()
+
+		reply, err := client.Request(ctx, "rpc.echo", []byte("ping"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("echo: ping"), reply)
+
+		_, err = client.Request(ctx, "rpc.fail", nil)
+		require.ErrorIs(t, err, nats.ErrRemote)
+		assert.Contains(t, err.Error(), "out of stock")
+
+		_, err = client.Request(ctx, "rpc.nobody", nil)
+		require.ErrorIs(t, err, natsgo.ErrNoResponders)
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		t.Parallel()
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := client.Request(timeoutCtx, "rpc.slow", nil)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+		_, err = client.Request(timeoutCtx, "rpc.echo", nil)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("ScatterGather", func(t *testing.T) {
+		t.Parallel()
+
+		// All responders answer before the deadline
+		timeoutCtx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+		defer cancel()
+
+		replies, err := client.ScatterGather(timeoutCtx, "rpc.inventory", nil, 0)
+		require.NoError(t, err)
+		assert.Len(t, replies, 3)
+
+		// Collection stops at maxReplies without waiting for the deadline
+		start := time.Now()
+		replies, err = client.ScatterGather(ctx, "rpc.inventory", nil, 2)
+		require.NoError(t, err)
+		assert.Len(t, replies, 2)
+		assert.Less(t, time.Since(start), time.Second)
+
+		replies, err = client.ScatterGather(ctx, "rpc.fail", nil, 1)
+		require.NoError(t, err)
+		require.Len(t, replies, 1)
+		assert.ErrorIs(t, replies[0].Err, nats.ErrRemote)
+
+		_, err = client.ScatterGather(ctx, "rpc.nobody", nil, 1)
+		require.ErrorIs(t, err, natsgo.ErrNoResponders)
+
+		canceled, cancelNow := context.WithCancel(ctx)
+		cancelNow()
+		_, err = client.ScatterGather(canceled, "rpc.inventory", nil, 1)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
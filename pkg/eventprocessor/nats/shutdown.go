@@ -0,0 +1,202 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+)
+
+// consumption is a consume context created by a JetStreamClient.
+// It counts the messages being handled so Close can wait for them to be acknowledged,
+// and cancels the context of their handlers when Close gives up waiting.
+type consumption struct {
+	jetstream.ConsumeContext
+	ctx    context.Context //nolint: containedctx
+	cancel context.CancelFunc
+	name   string
+	client *JetStreamClient
+	mu     sync.Mutex
+	active int
+	closed bool
+	idle   chan struct{}
+}
+
+// newConsumption creates the consumption of consumer name.
+func (c *JetStreamClient) newConsumption(name string) *consumption {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &consumption{
+		ConsumeContext: nil,
+		ctx:            ctx,
+		cancel:         cancel,
+		name:           name,
+		client:         c,
+		mu:             sync.Mutex{},
+		active:         0,
+		closed:         false,
+		idle:           make(chan struct{}),
+	}
+}
+
+// track registers s, once started, to be stopped by Close.
+func (c *JetStreamClient) track(s *consumption, cc jetstream.ConsumeContext) *consumption {
+	s.ConsumeContext = cc
+
+	c.mu.Lock()
+	c.consumers[s] = struct{}{}
+	c.mu.Unlock()
+
+	return s
+}
+
+// untrack removes s from the consumptions stopped by Close.
+func (c *JetStreamClient) untrack(s *consumption) {
+	c.mu.Lock()
+	delete(c.consumers, s)
+	c.mu.Unlock()
+}
+
+// Stop stops consuming, discarding buffered messages.
+func (s *consumption) Stop() {
+	s.client.untrack(s)
+	s.ConsumeContext.Stop()
+}
+
+// Drain stops consuming after buffered messages are handled.
+func (s *consumption) Drain() {
+	s.client.untrack(s)
+	s.ConsumeContext.Drain()
+}
+
+// wrap counts messages handled by next.
+// Messages arriving once shutdown started are nak'd for prompt redelivery instead of being handled.
+func (s *consumption) wrap(next jetstream.MessageHandler) jetstream.MessageHandler {
+	return func(raw jetstream.Msg) {
+		if !s.enter() {
+			if err := raw.Nak(); err != nil {
+				s.client.logger.Error("failed to nak message", zap.Error(err))
+			}
+
+			return
+		}
+		defer s.leave()
+
+		next(raw)
+	}
+}
+
+// enter records a message being handled, reporting false once shutdown started.
+func (s *consumption) enter() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	s.active++
+
+	return true
+}
+
+// leave records a handled message, signaling idle when it was the last one during shutdown.
+func (s *consumption) leave() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.active--
+	if s.closed && s.active == 0 {
+		close(s.idle)
+	}
+}
+
+// shutdown stops fetching and waits for messages being handled to be acknowledged.
+func (s *consumption) shutdown() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+
+		if s.active == 0 {
+			close(s.idle)
+		}
+	}
+	s.mu.Unlock()
+
+	s.ConsumeContext.Drain()
+	<-s.idle
+}
+
+// stopConsumers shuts down consumers concurrently, within the context deadline.
+// When ctx is done first the handlers still running have their context canceled.
+func stopConsumers(ctx context.Context, consumers []*consumption) error {
+	done := make(chan *consumption, len(consumers))
+	pending := make(map[*consumption]struct{}, len(consumers))
+
+	for _, s := range consumers {
+		pending[s] = struct{}{}
+
+		go func() {
+			s.shutdown()
+			s.cancel()
+			done <- s
+		}()
+	}
+
+	for len(pending) > 0 {
+		select {
+		case s := <-done:
+			delete(pending, s)
+		case <-ctx.Done():
+			names := make([]string, 0, len(pending))
+			for s := range pending {
+				s.cancel()
+				names = append(names, s.name)
+			}
+
+			slices.Sort(names)
+
+			return fmt.Errorf("failed to stop consumers %s: %w: %w",
+				strings.Join(names, ", "), eventprocessor.ErrShutdownIncomplete, ctx.Err())
+		}
+	}
+
+	return nil
+}
+
+// drainConn drains conn, which flushes pending publishes before closing it.
+// The connection is closed without waiting further when ctx is done.
+func drainConn(ctx context.Context, conn *nats.Conn) error {
+	if err := conn.Drain(); err != nil {
+		conn.Close()
+
+		if errors.Is(err, nats.ErrConnectionClosed) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to drain connection: %w", err)
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for !conn.IsClosed() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+
+			return fmt.Errorf("failed to drain connection: %w: %w", eventprocessor.ErrShutdownIncomplete, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
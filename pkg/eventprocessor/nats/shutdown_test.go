@@ -0,0 +1,168 @@
+package nats_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestShutdown(t *testing.T) { //nolint: funlen
+	t.Parallel()
+
+	const testTimeout = 5 * time.Second
+
+	cfg := natstest.NewConfig(t)
+
+	tests := []struct {
+		name   string
+		stream string
+		opts   []nats.ConsumerOption
+	}{
+		{name: "Inline", stream: "TEST_SHUTDOWN_1", opts: nil},
+		{name: "WorkerPool", stream: "TEST_SHUTDOWN_2", opts: []nats.ConsumerOption{
+			nats.WithWorkerPool(nats.WorkerPoolConfig{Workers: 2, MaxInFlight: 0, Key: nil}),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+			defer cancel()
+
+			client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+				Name:     tt.stream,
+				Subjects: []string{tt.stream + ".>"},
+			})
+			require.NoError(t, err)
+
+			started := make(chan struct{}, 1)
+			var finished atomic.Bool
+			_, err = client.CreateConsumer(ctx, "shutdown", func(context.Context, *nats.Message) nats.Result {
+				started <- struct{}{}
+				time.Sleep(200 * time.Millisecond)
+				finished.Store(true)
+
+				return nats.Ack()
+			}, tt.opts...)
+			require.NoError(t, err)
+
+			require.NoError(t, client.PublishToStream(ctx, tt.stream+".work", []byte("data")))
+			<-started
+
+			// Close waits for the message being handled before releasing the connection
+			require.NoError(t, client.Close(ctx))
+			assert.True(t, finished.Load())
+		})
+	}
+
+	t.Run("Deadline", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		// The stuck handler acks after the connection is closed, so it does not log to the test
+		stuckCfg := *cfg
+		stuckCfg.Logger = zap.NewNop()
+		client, err := nats.NewJetStreamClient(&stuckCfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:     "TEST_SHUTDOWN_3",
+			Subjects: []string{"test.shutdown3.>"},
+		})
+		require.NoError(t, err)
+
+		started := make(chan struct{}, 1)
+		release := make(chan struct{})
+		defer close(release)
+
+		_, err = client.CreateConsumer(ctx, "stuck", func(context.Context, *nats.Message) nats.Result {
+			started <- struct{}{}
+			<-release
+
+			return nats.Ack()
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, client.PublishToStream(ctx, "test.shutdown3.work", []byte("data")))
+		<-started
+
+		deadline, deadlineCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer deadlineCancel()
+
+		err = client.Close(deadline)
+		require.ErrorIs(t, err, eventprocessor.ErrShutdownIncomplete)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Contains(t, err.Error(), "stuck")
+	})
+
+	t.Run("CancelsHandlers", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		quietCfg := *cfg
+		quietCfg.Logger = zap.NewNop()
+		client, err := nats.NewJetStreamClient(&quietCfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:     "TEST_SHUTDOWN_5",
+			Subjects: []string{"test.shutdown5.>"},
+		})
+		require.NoError(t, err)
+
+		started := make(chan struct{}, 1)
+		canceled := make(chan struct{})
+		_, err = client.CreateConsumer(ctx, "canceled", func(handlerCtx context.Context, _ *nats.Message) nats.Result {
+			started <- struct{}{}
+			<-handlerCtx.Done()
+			close(canceled)
+
+			return nats.Nak(0, handlerCtx.Err())
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, client.PublishToStream(ctx, "test.shutdown5.work", []byte("data")))
+		<-started
+
+		// Handlers still running at the deadline have their context canceled
+		deadline, deadlineCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer deadlineCancel()
+
+		require.ErrorIs(t, client.Close(deadline), eventprocessor.ErrShutdownIncomplete)
+		select {
+		case <-canceled:
+		case <-ctx.Done():
+			t.Fatal("handler context was not canceled")
+		}
+	})
+
+	t.Run("StoppedConsumer", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:     "TEST_SHUTDOWN_4",
+			Subjects: []string{"test.shutdown4.>"},
+		})
+		require.NoError(t, err)
+
+		cc, err := client.CreateConsumer(ctx, "stopped", func(context.Context, *nats.Message) nats.Result {
+			return nats.Ack()
+		})
+		require.NoError(t, err)
+
+		// Consumers stopped by the caller are not stopped again
+		cc.Stop()
+		require.NoError(t, client.Close(ctx))
+	})
+}
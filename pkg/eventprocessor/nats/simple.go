@@ -2,15 +2,23 @@ package nats
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
 )
 
 // SimpleNatsClient implements the EventProcessor interface with basic NATS functionality.
 type SimpleNatsClient struct {
 	conn   *nats.Conn
 	config *Config
+	mu     sync.Mutex
+	subs   map[*nats.Subscription]*Subscription
 }
 
 // NewSimpleNatsClient creates a new NATS client with the provided configuration.
@@ -20,24 +28,21 @@ func NewSimpleNatsClient(cfg *Config) (*SimpleNatsClient, error) {
 		return nil, ErrInvalidConfig
 	}
 
-	opts := []nats.Option{
-		nats.MaxReconnects(cfg.MaxReconnects),
-		nats.ReconnectWait(cfg.ReconnectWait),
+	client := &SimpleNatsClient{
+		conn:   nil,
+		config: cfg,
+		mu:     sync.Mutex{},
+		subs:   make(map[*nats.Subscription]*Subscription),
 	}
 
-	// Use credentials file if provided, fallback to token
-	if cfg.CredsFile != "" {
-		opts = append(opts, nats.UserCredentials(cfg.CredsFile))
-	} else if cfg.Token != "" {
-		opts = append(opts, nats.Token(cfg.Token))
-	}
-
-	nc, err := nats.Connect(cfg.URL, opts...)
+	nc, err := connect(cfg, clientSimple, nats.ErrorHandler(client.asyncError))
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+		return nil, err
 	}
 
-	return &SimpleNatsClient{conn: nc, config: cfg}, nil
+	client.conn = nc
+
+	return client, nil
 }
 
 // PublishToStream implements the EventProcessor interface.
@@ -47,7 +52,52 @@ func (c *SimpleNatsClient) PublishToStream(ctx context.Context, subject string,
 		return fmt.Errorf("context error: %w", err)
 	}
 
-	if err := c.conn.Publish(subject, data); err != nil {
+	return c.publishMsg(ctx, &nats.Msg{Subject: subject, Data: data}) //nolint: exhaustruct
+}
+
+// PublishEvent publishes event to subject, carrying its attributes in message headers.
+func (c *SimpleNatsClient) PublishEvent(ctx context.Context, subject string, event Event) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context error: %w", err)
+	}
+
+	msg, err := eventMsg(subject, event)
+	if err != nil {
+		return err
+	}
+
+	return c.publishMsg(ctx, msg)
+}
+
+// PublishCloudEvent publishes event to subject as a CloudEvents 1.0 event in mode.
+func (c *SimpleNatsClient) PublishCloudEvent(
+	ctx context.Context,
+	subject string,
+	event Event,
+	mode CloudEventsMode,
+) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context error: %w", err)
+	}
+
+	msg, err := cloudEventMsg(subject, event, mode)
+	if err != nil {
+		return err
+	}
+
+	return c.publishMsg(ctx, msg)
+}
+
+// publishMsg publishes msg, recording traces and metrics.
+func (c *SimpleNatsClient) publishMsg(ctx context.Context, msg *nats.Msg) error {
+	end := c.config.Tracing.startPublish(ctx, clientSimple, msg)
+
+	start := time.Now()
+	err := c.conn.PublishMsg(msg)
+	c.config.Metrics.observePublish(clientSimple, start, err)
+	end(err)
+
+	if err != nil {
 		return fmt.Errorf("failed to publish message: %w", err)
 	}
 
@@ -55,24 +105,122 @@ func (c *SimpleNatsClient) PublishToStream(ctx context.Context, subject string,
 }
 
 // Close implements the EventProcessor interface.
-// It gracefully closes the NATS connection.
+// It drains all subscriptions, waiting for pending messages to be handled within the context deadline,
+// then drains the NATS connection, flushing pending publishes before closing it.
+// Work left unfinished at the deadline is reported with an error wrapping ErrShutdownIncomplete.
 func (c *SimpleNatsClient) Close(ctx context.Context) error {
 	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("context error: %w", err)
 	}
 
-	c.conn.Close()
+	c.mu.Lock()
+	subs := make([]*nats.Subscription, 0, len(c.subs))
+	for sub := range c.subs {
+		subs = append(subs, sub)
+	}
+	clear(c.subs)
+	c.mu.Unlock()
+
+	for _, sub := range subs {
+		if err := sub.Drain(); err != nil && !errors.Is(err, nats.ErrBadSubscription) {
+			c.config.Logger.Error("failed to drain subscription", zap.String("subject", sub.Subject), zap.Error(err))
+		}
+	}
+
+	return errors.Join(waitDrained(ctx, subs...), drainConn(ctx, c.conn))
+}
+
+// Flush waits until the server has processed all published messages, within the context deadline.
+func (c *SimpleNatsClient) Flush(ctx context.Context) error {
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+
+	if err := c.conn.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("failed to flush: %w", err)
+	}
 
 	return nil
 }
 
-func (c *SimpleNatsClient) Subscribe(subject string, handler func([]byte)) error {
-	_, err := c.conn.Subscribe(subject, func(msg *nats.Msg) {
-		handler(msg.Data)
-	})
+// JetStream returns a JetStream context on the client connection,
+// for managing streams and buckets, for example with the topology package.
+func (c *SimpleNatsClient) JetStream() (jetstream.JetStream, error) { //nolint: ireturn
+	js, err := jetstream.New(c.conn)
 	if err != nil {
-		return fmt.Errorf("failed to subscribe: %w", err)
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
 	}
 
-	return nil
+	return js, nil
+}
+
+// Subscribe calls handler with the payload of every message published to subject.
+// The returned Subscription is drained by Close unless it was unsubscribed or drained before.
+func (c *SimpleNatsClient) Subscribe(
+	subject string,
+	handler func([]byte),
+	opts ...SubscriptionOption,
+) (*Subscription, error) {
+	return c.subscribe(subject, func(msg *nats.Msg) {
+		c.config.Metrics.observeReceived(clientSimple, subject)
+		c.config.Tracing.receive(msg, handler)
+	}, opts)
+}
+
+// SubscribeChan delivers the payload of every message published to subject to ch.
+// Sends block while ch is full, so pending messages build up in the subscription and are
+// dropped once its pending limits are exceeded; Close and Drain wait until pending messages are received from ch.
+func (c *SimpleNatsClient) SubscribeChan(
+	subject string,
+	ch chan<- []byte,
+	opts ...SubscriptionOption,
+) (*Subscription, error) {
+	if ch == nil {
+		return nil, fmt.Errorf("channel is required: %w", ErrInvalidConfig)
+	}
+
+	return c.Subscribe(subject, func(data []byte) { ch <- data }, opts...)
+}
+
+// SubscribeEvents calls handler with every event published to subject.
+// Messages that do not carry a valid event envelope are logged and dropped.
+func (c *SimpleNatsClient) SubscribeEvents(
+	subject string,
+	handler func(Event),
+	opts ...SubscriptionOption,
+) (*Subscription, error) {
+	return c.subscribeEvents(subject, eventprocessor.DecodeEvent, handler, opts)
+}
+
+// SubscribeCloudEvents calls handler with every binary or structured mode CloudEvent published to subject.
+// Messages that are not valid CloudEvents are logged and dropped.
+func (c *SimpleNatsClient) SubscribeCloudEvents(
+	subject string,
+	handler func(Event),
+	opts ...SubscriptionOption,
+) (*Subscription, error) {
+	return c.subscribeEvents(subject, eventprocessor.DecodeCloudEvent, handler, opts)
+}
+
+// subscribeEvents subscribes to subject, calling handler with the events read by decode.
+func (c *SimpleNatsClient) subscribeEvents(
+	subject string,
+	decode func(eventprocessor.Header, []byte) (Event, error),
+	handler func(Event),
+	opts []SubscriptionOption,
+) (*Subscription, error) {
+	return c.subscribe(subject, func(msg *nats.Msg) {
+		c.config.Metrics.observeReceived(clientSimple, subject)
+
+		event, err := decode(eventprocessor.Header(msg.Header), msg.Data)
+		if err != nil {
+			c.config.Logger.Warn("dropping message without event envelope",
+				zap.String("subject", msg.Subject),
+				zap.Error(err),
+			)
+
+			return
+		}
+
+		c.config.Tracing.receive(msg, func([]byte) { handler(event) })
+	}, opts)
 }
@@ -2,34 +2,31 @@ package nats_test
 
 import (
 	"context"
-	"os"
 	"testing"
-	"time"
 
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/eventprocessortest"
 	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"go.uber.org/zap"
 )
 
+// simplePubSub adapts SimpleNatsClient to eventprocessortest.PubSub, discarding subscription handles.
+type simplePubSub struct {
+	*nats.SimpleNatsClient
+}
+
+// Subscribe subscribes handler to pattern.
+func (c simplePubSub) Subscribe(pattern string, handler func([]byte)) error {
+	_, err := c.SimpleNatsClient.Subscribe(pattern, handler)
+
+	return err
+}
+
 func TestSimpleNatsClient(t *testing.T) {
 	t.Parallel()
 
-	natsURL := os.Getenv("NATS_URL")
-	if natsURL == "" {
-		natsURL = "nats://nats:4222" // fallback for local testing
-	}
-
-	logger, _ := zap.NewProduction()
-	cfg := &nats.Config{
-		URL:           natsURL,
-		Token:         "test-token",
-		CredsFile:     "",
-		MaxReconnects: nats.DefaultMaxReconnects,
-		ReconnectWait: time.Second * nats.DefaultReconnectWaitSeconds,
-		Logger:        logger,
-	}
-
+	cfg := natstest.NewConfig(t)
 	ctx := context.Background()
 
 	t.Run("NewSimpleNatsClient", func(t *testing.T) {
@@ -65,6 +62,17 @@ func TestSimpleNatsClient(t *testing.T) {
 		assert.ErrorIs(t, err, nats.ErrInvalidConfig)
 	})
 
+	t.Run("Suite", func(t *testing.T) {
+		t.Parallel()
+		eventprocessortest.Run(t, "test.simple.suite", func(t *testing.T) eventprocessortest.PubSub {
+			t.Helper()
+			client, err := nats.NewSimpleNatsClient(cfg)
+			require.NoError(t, err)
+
+			return simplePubSub{client}
+		})
+	})
+
 	t.Run("CanceledContext", func(t *testing.T) {
 		t.Parallel()
 		client, err := nats.NewSimpleNatsClient(cfg)
@@ -0,0 +1,81 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// StreamOwnership decides whether a JetStream client creates, updates and deletes its stream.
+type StreamOwnership int
+
+const (
+	// StreamManaged creates the stream or reconciles an existing one with the configuration, and keeps it on Close.
+	// Destructive changes are refused, see ReconcileStream.
+	StreamManaged StreamOwnership = iota
+	// StreamEphemeral creates the stream and deletes it on Close, for tests and short-lived streams.
+	StreamEphemeral
+	// StreamExternal binds to an existing stream by name and never modifies or deletes it.
+	StreamExternal
+)
+
+// String returns the lowercase name of the ownership mode.
+func (o StreamOwnership) String() string {
+	switch o {
+	case StreamManaged:
+		return "managed"
+	case StreamEphemeral:
+		return "ephemeral"
+	case StreamExternal:
+		return "external"
+	default:
+		return fmt.Sprintf("ownership(%d)", int(o))
+	}
+}
+
+// JetStreamOption configures the stream handling of a JetStream client.
+type JetStreamOption func(*jetStreamOptions)
+
+// jetStreamOptions collects the stream settings of a JetStream client.
+type jetStreamOptions struct {
+	ownership StreamOwnership
+}
+
+// WithStreamOwnership sets how the client owns its stream, StreamManaged by default.
+func WithStreamOwnership(ownership StreamOwnership) JetStreamOption {
+	return func(o *jetStreamOptions) {
+		o.ownership = ownership
+	}
+}
+
+// openStream creates, reconciles or binds to the stream of streamConfig according to ownership,
+// returning the changes applied to an existing managed stream.
+// External streams only need a name and are returned with their server-side configuration.
+func openStream( //nolint: ireturn
+	ctx context.Context,
+	js jetstream.JetStream,
+	streamConfig jetstream.StreamConfig,
+	ownership StreamOwnership,
+) (jetstream.Stream, StreamDiff, error) {
+	switch ownership {
+	case StreamManaged:
+		return ReconcileStream(ctx, js, streamConfig)
+	case StreamEphemeral:
+		stream, err := js.CreateStream(ctx, streamConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create stream: %w", err)
+		}
+
+		return stream, nil, nil
+	case StreamExternal:
+		stream, err := js.Stream(ctx, streamConfig.Name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to bind to stream %s: %w", streamConfig.Name, err)
+		}
+
+		return stream, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown stream ownership %d: %w", ownership, ErrInvalidConfig)
+	}
+}
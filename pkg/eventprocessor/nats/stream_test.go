@@ -0,0 +1,108 @@
+package nats_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamOwnership(t *testing.T) { //nolint: funlen
+	t.Parallel()
+
+	cfg := natstest.NewConfig(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	nc, err := natsgo.Connect(cfg.URL)
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+
+	js, err := jetstream.New(nc)
+	require.NoError(t, err)
+
+	t.Run("Managed", func(t *testing.T) {
+		t.Parallel()
+
+		streamConfig := jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:     "TEST_OWNERSHIP_MANAGED",
+			Subjects: []string{"test.managed.>"},
+		}
+
+		client, err := nats.NewJetStreamClient(cfg, streamConfig)
+		require.NoError(t, err)
+		require.NoError(t, client.PublishToStream(ctx, "test.managed.created", []byte("data")))
+		require.NoError(t, client.Close(ctx))
+
+		// A restarted client keeps the stored messages and applies configuration changes
+		streamConfig.Description = "updated"
+		restarted, err := nats.NewJetStreamClient(cfg, streamConfig, nats.WithStreamOwnership(nats.StreamManaged))
+		require.NoError(t, err)
+		require.NoError(t, restarted.Close(ctx))
+
+		stream, err := js.Stream(ctx, streamConfig.Name)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), stream.CachedInfo().State.Msgs)
+		assert.Equal(t, "updated", stream.CachedInfo().Config.Description)
+	})
+
+	t.Run("Ephemeral", func(t *testing.T) {
+		t.Parallel()
+
+		client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:     "TEST_OWNERSHIP_EPHEMERAL",
+			Subjects: []string{"test.ephemeral.>"},
+		}, nats.WithStreamOwnership(nats.StreamEphemeral))
+		require.NoError(t, err)
+		require.NoError(t, client.Close(ctx))
+
+		_, err = js.Stream(ctx, "TEST_OWNERSHIP_EPHEMERAL")
+		require.ErrorIs(t, err, jetstream.ErrStreamNotFound)
+	})
+
+	t.Run("External", func(t *testing.T) {
+		t.Parallel()
+
+		external := nats.WithStreamOwnership(nats.StreamExternal)
+
+		_, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{Name: "TEST_OWNERSHIP_MISSING"}, external) //nolint: exhaustruct
+		require.ErrorIs(t, err, jetstream.ErrStreamNotFound)
+
+		_, err = js.CreateStream(ctx, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:        "TEST_OWNERSHIP_EXTERNAL",
+			Subjects:    []string{"test.external.>"},
+			Description: "owned elsewhere",
+		})
+		require.NoError(t, err)
+
+		// Only the name is needed, and the server-side configuration is left untouched
+		client, err := nats.NewDedupJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:        "TEST_OWNERSHIP_EXTERNAL",
+			Description: "ignored",
+		}, nats.WithJetStreamOptions(external), nats.WithMsgIDFunc(nats.RandomMsgID))
+		require.NoError(t, err)
+		require.NoError(t, client.PublishToStream(ctx, "test.external.created", []byte("data")))
+		require.NoError(t, client.Close(ctx))
+
+		stream, err := js.Stream(ctx, "TEST_OWNERSHIP_EXTERNAL")
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), stream.CachedInfo().State.Msgs)
+		assert.Equal(t, "owned elsewhere", stream.CachedInfo().Config.Description)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:     "TEST_OWNERSHIP_UNKNOWN",
+			Subjects: []string{"test.unknown.>"},
+		}, nats.WithStreamOwnership(nats.StreamOwnership(42)))
+		require.ErrorIs(t, err, nats.ErrInvalidConfig)
+	})
+}
@@ -0,0 +1,220 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// drainPollInterval is how often draining subscriptions are checked for completion.
+const drainPollInterval = 10 * time.Millisecond
+
+// SubscriptionStats reports the delivery state of a subscription.
+type SubscriptionStats struct {
+	// PendingMsgs is the number of received messages not yet handled
+	PendingMsgs int
+	// PendingBytes is the size of the received messages not yet handled
+	PendingBytes int
+	// Delivered is the number of messages handed to the handler
+	Delivered int64
+	// Dropped is the number of messages dropped because the pending limits were exceeded
+	Dropped int
+}
+
+// SubscriptionOption customizes subscriptions created by SimpleNatsClient.
+type SubscriptionOption func(*Subscription)
+
+// WithSlowConsumerHandler sets a function called when the subscription drops messages
+// because its handler cannot keep up. It runs on the connection's error goroutine.
+func WithSlowConsumerHandler(fn func(sub *Subscription, err error)) SubscriptionOption {
+	return func(s *Subscription) {
+		s.onSlow = fn
+	}
+}
+
+// WithPendingLimits bounds how many messages and bytes are buffered before messages are dropped.
+// Negative values disable the corresponding limit.
+func WithPendingLimits(msgs, bytes int) SubscriptionOption {
+	return func(s *Subscription) {
+		s.pendingMsgs, s.pendingBytes = msgs, bytes
+	}
+}
+
+// WithQueueGroup joins the subscription to queue group name.
+// Each message is delivered to only one member of the group, so replicas subscribing
+// with the same group share the work instead of all receiving every message.
+func WithQueueGroup(name string) SubscriptionOption {
+	return func(s *Subscription) {
+		s.queue = name
+	}
+}
+
+// Subscription is a handle to a core NATS subscription created by SimpleNatsClient.
+type Subscription struct {
+	sub          *nats.Subscription
+	client       *SimpleNatsClient
+	queue        string
+	onSlow       func(*Subscription, error)
+	pendingMsgs  int
+	pendingBytes int
+}
+
+// Subject returns the subject the subscription listens on.
+func (s *Subscription) Subject() string {
+	return s.sub.Subject
+}
+
+// Queue returns the queue group of the subscription, empty when it receives every message.
+func (s *Subscription) Queue() string {
+	return s.queue
+}
+
+// IsValid reports whether the subscription is still active.
+func (s *Subscription) IsValid() bool {
+	return s.sub.IsValid()
+}
+
+// Stats returns the pending, delivered and dropped message counts.
+func (s *Subscription) Stats() (SubscriptionStats, error) {
+	msgs, bytes, err := s.sub.Pending()
+	if err != nil {
+		return SubscriptionStats{}, fmt.Errorf("failed to get pending messages: %w", err)
+	}
+
+	delivered, err := s.sub.Delivered()
+	if err != nil {
+		return SubscriptionStats{}, fmt.Errorf("failed to get delivered messages: %w", err)
+	}
+
+	dropped, err := s.sub.Dropped()
+	if err != nil {
+		return SubscriptionStats{}, fmt.Errorf("failed to get dropped messages: %w", err)
+	}
+
+	return SubscriptionStats{PendingMsgs: msgs, PendingBytes: bytes, Delivered: delivered, Dropped: dropped}, nil
+}
+
+// Unsubscribe stops the subscription immediately, discarding pending messages.
+func (s *Subscription) Unsubscribe() error {
+	s.client.untrack(s)
+
+	if err := s.sub.Unsubscribe(); err != nil {
+		return fmt.Errorf("failed to unsubscribe: %w", err)
+	}
+
+	return nil
+}
+
+// Drain stops receiving new messages and waits until pending messages are handled, or until ctx is done.
+// In the latter case it returns an error wrapping eventprocessor.ErrShutdownIncomplete while NATS keeps
+// draining in the background, so pending messages are still handled; Drain may be called again to wait
+// for them, or Unsubscribe to discard them. Either way the client no longer tracks the subscription.
+func (s *Subscription) Drain(ctx context.Context) error {
+	defer s.client.untrack(s)
+
+	if err := s.sub.Drain(); err != nil && !errors.Is(err, nats.ErrBadSubscription) {
+		return fmt.Errorf("failed to drain subscription: %w", err)
+	}
+
+	return waitDrained(ctx, s.sub)
+}
+
+// subscribe creates a tracked subscription on subject delivering messages to handler.
+func (c *SimpleNatsClient) subscribe(
+	subject string,
+	handler nats.MsgHandler,
+	opts []SubscriptionOption,
+) (*Subscription, error) {
+	s := &Subscription{
+		sub:          nil,
+		client:       c,
+		queue:        "",
+		onSlow:       nil,
+		pendingMsgs:  nats.DefaultSubPendingMsgsLimit,
+		pendingBytes: nats.DefaultSubPendingBytesLimit,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sub, err := c.conn.QueueSubscribe(subject, s.queue, handler)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	if err := sub.SetPendingLimits(s.pendingMsgs, s.pendingBytes); err != nil {
+		_ = sub.Unsubscribe()
+
+		return nil, fmt.Errorf("failed to set pending limits: %w", err)
+	}
+
+	s.sub = sub
+	c.subs[sub] = s
+
+	return s, nil
+}
+
+// untrack stops tracking s so Close no longer drains it.
+func (c *SimpleNatsClient) untrack(s *Subscription) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.subs, s.sub)
+}
+
+// asyncError handles asynchronous connection errors, notifying slow consumers.
+func (c *SimpleNatsClient) asyncError(_ *nats.Conn, sub *nats.Subscription, err error) {
+	c.mu.Lock()
+	s := c.subs[sub]
+	c.mu.Unlock()
+
+	if s == nil || !errors.Is(err, nats.ErrSlowConsumer) {
+		c.config.Logger.Error("asynchronous NATS error", zap.Error(err))
+
+		return
+	}
+
+	c.config.Logger.Warn("slow consumer dropping messages", zap.String("subject", s.Subject()), zap.Error(err))
+
+	if s.onSlow != nil {
+		s.onSlow(s, err)
+	}
+}
+
+// waitDrained waits until every sub has finished draining or ctx is done.
+// The error reports the subjects of subscriptions still draining at the deadline.
+func waitDrained(ctx context.Context, subs ...*nats.Subscription) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		var draining []string
+
+		for _, sub := range subs {
+			if sub.IsValid() {
+				draining = append(draining, sub.Subject)
+			}
+		}
+
+		if len(draining) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to drain subscriptions %s: %w: %w",
+				strings.Join(draining, ", "), eventprocessor.ErrShutdownIncomplete, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
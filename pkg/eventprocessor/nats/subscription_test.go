@@ -0,0 +1,173 @@
+package nats_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscription(t *testing.T) { //nolint: funlen
+	t.Parallel()
+
+	const testTimeout = 5 * time.Second
+
+	cfg := natstest.NewConfig(t)
+	ctx := context.Background()
+
+	t.Run("Unsubscribe", func(t *testing.T) {
+		t.Parallel()
+
+		client, err := nats.NewSimpleNatsClient(cfg)
+		require.NoError(t, err)
+		defer client.Close(ctx)
+
+		var received atomic.Int64
+		sub, err := client.Subscribe("test.sub.unsubscribe", func([]byte) { received.Add(1) })
+		require.NoError(t, err)
+		assert.Equal(t, "test.sub.unsubscribe", sub.Subject())
+
+		require.NoError(t, client.PublishToStream(ctx, "test.sub.unsubscribe", []byte("1")))
+		require.Eventually(t, func() bool { return received.Load() == 1 }, testTimeout, 10*time.Millisecond)
+
+		stats, err := sub.Stats()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), stats.Delivered)
+
+		require.NoError(t, sub.Unsubscribe())
+		assert.False(t, sub.IsValid())
+
+		require.NoError(t, client.PublishToStream(ctx, "test.sub.unsubscribe", []byte("2")))
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, int64(1), received.Load())
+
+		_, err = sub.Stats()
+		assert.Error(t, err)
+	})
+
+	t.Run("Drain", func(t *testing.T) {
+		t.Parallel()
+
+		client, err := nats.NewSimpleNatsClient(cfg)
+		require.NoError(t, err)
+		defer client.Close(ctx)
+
+		release := make(chan struct{})
+		var released sync.Once
+		defer released.Do(func() { close(release) })
+
+		var handled atomic.Int64
+		sub, err := client.Subscribe("test.sub.drain", func([]byte) {
+			<-release
+			handled.Add(1)
+		})
+		require.NoError(t, err)
+
+		for range 5 {
+			require.NoError(t, client.PublishToStream(ctx, "test.sub.drain", []byte("data")))
+		}
+		require.NoError(t, client.Flush(ctx))
+
+		// Pending messages are reported while the handler is blocked
+		require.Eventually(t, func() bool {
+			stats, err := sub.Stats()
+
+			return err == nil && stats.PendingMsgs >= 4
+		}, testTimeout, 10*time.Millisecond)
+
+		// Draining with an expired deadline gives up waiting
+		expired, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, sub.Drain(expired), context.DeadlineExceeded)
+
+		released.Do(func() { close(release) })
+		require.NoError(t, sub.Drain(ctx))
+		assert.Equal(t, int64(5), handled.Load())
+		assert.False(t, sub.IsValid())
+	})
+
+	t.Run("SlowConsumer", func(t *testing.T) {
+		t.Parallel()
+
+		client, err := nats.NewSimpleNatsClient(cfg)
+		require.NoError(t, err)
+
+		release := make(chan struct{})
+		slow := make(chan error, 1)
+		sub, err := client.Subscribe("test.sub.slow", func([]byte) { <-release },
+			nats.WithPendingLimits(2, -1),
+			nats.WithSlowConsumerHandler(func(_ *nats.Subscription, err error) {
+				select {
+				case slow <- err:
+				default:
+				}
+			}),
+		)
+		require.NoError(t, err)
+
+		for range 10 {
+			require.NoError(t, client.PublishToStream(ctx, "test.sub.slow", []byte("data")))
+		}
+
+		select {
+		case err := <-slow:
+			assert.Error(t, err)
+		case <-time.After(testTimeout):
+			t.Fatal("slow consumer was not reported")
+		}
+
+		stats, err := sub.Stats()
+		require.NoError(t, err)
+		assert.Positive(t, stats.Dropped)
+
+		close(release)
+		require.NoError(t, client.Close(ctx))
+	})
+
+	t.Run("CloseDrains", func(t *testing.T) {
+		t.Parallel()
+
+		client, err := nats.NewSimpleNatsClient(cfg)
+		require.NoError(t, err)
+
+		var handled atomic.Int64
+		_, err = client.Subscribe("test.sub.close", func([]byte) {
+			time.Sleep(20 * time.Millisecond)
+			handled.Add(1)
+		})
+		require.NoError(t, err)
+
+		for range 5 {
+			require.NoError(t, client.PublishToStream(ctx, "test.sub.close", []byte("data")))
+		}
+		require.NoError(t, client.Flush(ctx))
+
+		require.NoError(t, client.Close(ctx))
+		assert.Equal(t, int64(5), handled.Load())
+		assert.Error(t, client.PublishToStream(ctx, "test.sub.close", []byte("late")))
+	})
+
+	t.Run("CloseDeadline", func(t *testing.T) {
+		t.Parallel()
+
+		client, err := nats.NewSimpleNatsClient(cfg)
+		require.NoError(t, err)
+
+		release := make(chan struct{})
+		defer close(release)
+		_, err = client.Subscribe("test.sub.deadline", func([]byte) { <-release })
+		require.NoError(t, err)
+		require.NoError(t, client.PublishToStream(ctx, "test.sub.deadline", []byte("data")))
+		require.NoError(t, client.Flush(ctx))
+
+		deadline, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, client.Close(deadline), context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,168 @@
+package nats
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation name of the spans created by the NATS clients.
+const TracerName = "github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+
+// Tracing creates OpenTelemetry spans for the NATS clients and propagates W3C trace context
+// through message headers. Set it on Config.Tracing to enable tracing; a nil Tracing records nothing.
+type Tracing struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// NewTracing creates a Tracing that records spans with provider and propagates the
+// W3C traceparent and tracestate headers.
+func NewTracing(provider trace.TracerProvider) *Tracing {
+	return &Tracing{
+		tracer:     provider.Tracer(TracerName),
+		propagator: propagation.TraceContext{},
+	}
+}
+
+// headerCarrier adapts nats.Header to propagation.TextMapCarrier.
+type headerCarrier nats.Header
+
+// Get returns the first value of key.
+func (h headerCarrier) Get(key string) string {
+	return nats.Header(h).Get(key)
+}
+
+// Set replaces the values of key with value.
+func (h headerCarrier) Set(key, value string) {
+	nats.Header(h).Set(key, value)
+}
+
+// Keys returns the header names.
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// startPublish starts a producer span for a publish to subject and injects its context into msg.
+// The returned function ends the span, recording err.
+func (t *Tracing) startPublish(ctx context.Context, client string, msg *nats.Msg) func(err error) {
+	if t == nil {
+		return func(error) {}
+	}
+
+	ctx, span := t.tracer.Start(ctx, msg.Subject+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.operation.type", "publish"),
+			attribute.String("messaging.destination.name", msg.Subject),
+			attribute.String("messaging.client.id", client),
+		),
+	)
+
+	if msg.Header == nil {
+		msg.Header = nats.Header{}
+	}
+
+	t.propagator.Inject(ctx, headerCarrier(msg.Header))
+
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		span.End()
+	}
+}
+
+// startReceive starts a consumer span continuing the trace carried in header.
+func (t *Tracing) startReceive( //nolint: ireturn
+	ctx context.Context,
+	subject string,
+	header nats.Header,
+	attrs ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+	ctx = t.propagator.Extract(ctx, headerCarrier(header))
+
+	return t.tracer.Start(ctx, subject+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.operation.type", "process"),
+			attribute.String("messaging.destination.name", subject),
+		),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// receive runs handler for a core NATS message inside a consumer span.
+func (t *Tracing) receive(msg *nats.Msg, handler func([]byte)) {
+	_, end := t.receiveContext(msg)
+	defer end()
+
+	handler(msg.Data)
+}
+
+// receiveContext starts a consumer span for a core NATS message.
+// The returned context carries the span, which is ended by the returned function.
+func (t *Tracing) receiveContext(msg *nats.Msg) (context.Context, func()) {
+	if t == nil {
+		return context.Background(), func() {}
+	}
+
+	ctx, span := t.startReceive(context.Background(), msg.Subject, msg.Header)
+
+	return ctx, func() { span.End() }
+}
+
+// trace wraps handler so each message is handled inside a child span of the publisher's span.
+// The span context is passed to handler through ctx and the span records the handler result.
+func (t *Tracing) trace(consumer string, handler Handler) Handler {
+	if t == nil {
+		return handler
+	}
+
+	return func(ctx context.Context, msg *Message) Result {
+		ctx, end := t.startConsume(ctx, consumer, msg)
+		res := handler(ctx, msg)
+		end(res)
+
+		return res
+	}
+}
+
+// startConsume starts a consumer span for msg delivered to consumer, continuing the publisher's span.
+// The returned context carries the span, which is ended with the handling result by the returned function.
+func (t *Tracing) startConsume(ctx context.Context, consumer string, msg *Message) (context.Context, func(Result)) {
+	if t == nil {
+		return ctx, func(Result) {}
+	}
+
+	ctx, span := t.startReceive(ctx, msg.Subject, nats.Header(msg.Headers),
+		attribute.String("messaging.consumer.group.name", consumer),
+		attribute.String("messaging.message.id", strconv.FormatUint(msg.Metadata.Sequence.Stream, 10)),
+		attribute.Int64("messaging.nats.delivery_count", int64(msg.Metadata.NumDelivered)), //nolint: gosec
+	)
+
+	return ctx, func(res Result) {
+		span.SetAttributes(attribute.String("messaging.nats.ack", res.Action.String()))
+
+		if res.Err != nil {
+			span.RecordError(res.Err)
+			span.SetStatus(codes.Error, res.Err.Error())
+		}
+
+		span.End()
+	}
+}
@@ -0,0 +1,160 @@
+package nats_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanNamed returns the recorded span called name.
+func spanNamed(t *testing.T, exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	t.Helper()
+
+	for _, span := range exporter.GetSpans() {
+		if span.Name == name {
+			return span
+		}
+	}
+
+	t.Fatalf("span %q was not recorded", name)
+
+	return tracetest.SpanStub{} //nolint: exhaustruct
+}
+
+func TestTracing(t *testing.T) {
+	t.Parallel()
+
+	const testTimeout = 5 * time.Second
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	cfg := natstest.NewConfig(t)
+	cfg.Tracing = nats.NewTracing(provider)
+
+	t.Run("JetStream", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:     "TEST_TRACING",
+			Subjects: []string{"test.tracing.>"},
+		})
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		parentCtx, parent := provider.Tracer("test").Start(ctx, "request")
+		require.NoError(t, client.PublishToStream(parentCtx, "test.tracing.orders", []byte("data")))
+		parent.End()
+
+		handled := make(chan trace.SpanContext, 1)
+		cc, err := client.CreateConsumer(ctx, "test-tracing", func(ctx context.Context, msg *nats.Message) nats.Result {
+			assert.NotEmpty(t, msg.Headers.Get("traceparent"))
+			handled <- trace.SpanContextFromContext(ctx)
+
+			return nats.Ack()
+		})
+		require.NoError(t, err)
+		defer cc.Stop()
+
+		var handlerSpan trace.SpanContext
+		select {
+		case handlerSpan = <-handled:
+		case <-ctx.Done():
+			t.Fatal("message was not consumed")
+		}
+
+		// Producer and consumer spans continue the caller's trace
+		publish := spanNamed(t, exporter, "test.tracing.orders publish")
+		assert.Equal(t, trace.SpanKindProducer, publish.SpanKind)
+		assert.Equal(t, parent.SpanContext().SpanID(), publish.Parent.SpanID())
+		assert.Equal(t, parent.SpanContext().TraceID(), handlerSpan.TraceID())
+
+		require.Eventually(t, func() bool {
+			return len(exporter.GetSpans()) >= 3
+		}, testTimeout, 10*time.Millisecond)
+
+		process := spanNamed(t, exporter, "test.tracing.orders process")
+		assert.Equal(t, trace.SpanKindConsumer, process.SpanKind)
+		assert.Equal(t, publish.SpanContext.SpanID(), process.Parent.SpanID())
+		assert.Equal(t, handlerSpan.SpanID(), process.SpanContext.SpanID())
+	})
+
+	t.Run("Pull", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:     "TEST_TRACING_PULL",
+			Subjects: []string{"test.tracingpull.>"},
+		})
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		parentCtx, parent := provider.Tracer("test").Start(ctx, "pull request")
+		require.NoError(t, client.PublishToStream(parentCtx, "test.tracingpull.jobs", []byte("data")))
+		parent.End()
+
+		consumer, err := client.CreatePullConsumer(ctx, "test-tracing-pull")
+		require.NoError(t, err)
+
+		for msg, err := range consumer.Messages(ctx) {
+			require.NoError(t, err)
+			require.NoError(t, msg.Respond(nats.Term(errors.New("bad job"))))
+
+			break
+		}
+
+		// The consumer span ends when the message is responded to
+		process := spanNamed(t, exporter, "test.tracingpull.jobs process")
+		assert.Equal(t, trace.SpanKindConsumer, process.SpanKind)
+		assert.Equal(t, parent.SpanContext().TraceID(), process.SpanContext.TraceID())
+		assert.Equal(t, codes.Error, process.Status.Code)
+	})
+
+	t.Run("Simple", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		client, err := nats.NewSimpleNatsClient(cfg)
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		received := make(chan struct{})
+		_, err = client.Subscribe("test.tracing.simple", func([]byte) { close(received) })
+		require.NoError(t, err)
+
+		parentCtx, parent := provider.Tracer("test").Start(ctx, "simple request")
+		require.NoError(t, client.PublishToStream(parentCtx, "test.tracing.simple", []byte("data")))
+		parent.End()
+		<-received
+
+		require.Eventually(t, func() bool {
+			for _, span := range exporter.GetSpans() {
+				if span.Name == "test.tracing.simple process" {
+					return span.SpanContext.TraceID() == parent.SpanContext().TraceID()
+				}
+			}
+
+			return false
+		}, testTimeout, 10*time.Millisecond)
+	})
+}
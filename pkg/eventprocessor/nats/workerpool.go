@@ -0,0 +1,226 @@
+package nats
+
+import (
+	"context"
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+)
+
+// KeyFunc returns the ordering key of a message.
+// Messages sharing a non-empty key are handled one at a time in delivery order.
+type KeyFunc func(msg *Message) string
+
+// SubjectTokenKey returns a KeyFunc keying messages by the subject token at index,
+// counting from the end when index is negative. Subjects without that token get no key.
+func SubjectTokenKey(index int) KeyFunc {
+	return func(msg *Message) string {
+		tokens := strings.Split(msg.Subject, ".")
+		if index < 0 {
+			index += len(tokens)
+		}
+
+		if index < 0 || index >= len(tokens) {
+			return ""
+		}
+
+		return tokens[index]
+	}
+}
+
+// HeaderKey returns a KeyFunc keying messages by the value of header name.
+func HeaderKey(name string) KeyFunc {
+	return func(msg *Message) string {
+		return msg.Headers.Get(name)
+	}
+}
+
+// WorkerPoolConfig configures concurrent message processing for a consumer.
+type WorkerPoolConfig struct {
+	// Workers is the number of goroutines running the handler
+	Workers int
+	// MaxInFlight bounds received but not yet acknowledged messages and is set as the consumer MaxAckPending,
+	// zero defaults to Workers * DefaultMaxInFlightPerWorker. A differing WithMaxAckPending is rejected
+	MaxInFlight int
+	// Key orders messages sharing a key, nil lets any worker handle any message
+	Key KeyFunc
+}
+
+// WithWorkerPool handles messages on a pool of workers instead of the consume callback.
+// Stopping the returned ConsumeContext waits for queued messages to be handled,
+// so it must not be called from within the handler.
+func WithWorkerPool(cfg WorkerPoolConfig) ConsumerOption {
+	cfg.Workers = max(cfg.Workers, 1)
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = cfg.Workers * DefaultMaxInFlightPerWorker
+	}
+
+	return func(o *consumerOptions) {
+		o.pool = &cfg
+
+		// An explicit WithMaxAckPending is kept so validate can reject it when it differs
+		if o.config.MaxAckPending == 0 {
+			o.config.MaxAckPending = cfg.MaxInFlight
+		}
+	}
+}
+
+// workerPool runs a Handler on a fixed set of goroutines.
+// Keyed messages go to the worker owning the key, unkeyed ones to whichever worker is free.
+type workerPool struct {
+	ctx      context.Context //nolint: containedctx
+	logger   *zap.Logger
+	handler  Handler
+	key      KeyFunc
+	shared   chan *Message
+	queues   []chan *Message
+	inFlight chan struct{}
+	mu       sync.RWMutex
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// newWorkerPool starts the workers of a pool running handler with ctx.
+func newWorkerPool(ctx context.Context, logger *zap.Logger, cfg WorkerPoolConfig, handler Handler) *workerPool {
+	pool := &workerPool{
+		ctx:      ctx,
+		logger:   logger,
+		handler:  handler,
+		key:      cfg.Key,
+		shared:   make(chan *Message, cfg.MaxInFlight),
+		queues:   make([]chan *Message, cfg.Workers),
+		inFlight: make(chan struct{}, cfg.MaxInFlight),
+		mu:       sync.RWMutex{},
+		closed:   false,
+		wg:       sync.WaitGroup{},
+	}
+
+	pool.wg.Add(cfg.Workers)
+
+	for i := range pool.queues {
+		pool.queues[i] = make(chan *Message, cfg.MaxInFlight)
+
+		go pool.work(pool.queues[i])
+	}
+
+	return pool
+}
+
+// submit queues a raw message, blocking while MaxInFlight messages are being handled.
+// Messages arriving after the pool was closed or its context was canceled are nak'd for prompt redelivery.
+func (p *workerPool) submit(raw jetstream.Msg) {
+	msg, ok := readMessage(p.logger, raw)
+	if !ok {
+		return
+	}
+
+	// The slot is taken without the lock so close is not held up; workers keep freeing slots until they exit
+	select {
+	case p.inFlight <- struct{}{}:
+	case <-p.ctx.Done():
+		p.nak(raw)
+
+		return
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		<-p.inFlight
+		p.nak(raw)
+
+		return
+	}
+
+	// Queues hold MaxInFlight messages, so with a slot taken the send does not block
+	queue := p.shared
+
+	if p.key != nil {
+		if key := p.key(msg); key != "" {
+			h := fnv.New32a()
+			h.Write([]byte(key))
+			queue = p.queues[h.Sum32()%uint32(len(p.queues))]
+		}
+	}
+
+	queue <- msg
+}
+
+// nak naks a message the pool does not handle.
+func (p *workerPool) nak(raw jetstream.Msg) {
+	if err := raw.Nak(); err != nil {
+		p.logger.Error("failed to nak message", zap.Error(err))
+	}
+}
+
+// work handles messages from the worker's own queue and the shared queue until both are closed.
+func (p *workerPool) work(own chan *Message) {
+	defer p.wg.Done()
+
+	shared := p.shared
+
+	for own != nil || shared != nil {
+		var (
+			msg *Message
+			ok  bool
+		)
+
+		select {
+		case msg, ok = <-own:
+			if !ok {
+				own = nil
+
+				continue
+			}
+		case msg, ok = <-shared:
+			if !ok {
+				shared = nil
+
+				continue
+			}
+		}
+
+		process(p.ctx, p.logger, p.handler, msg)
+		<-p.inFlight
+	}
+}
+
+// close stops accepting messages and waits for queued ones to be handled.
+func (p *workerPool) close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+
+		close(p.shared)
+
+		for _, queue := range p.queues {
+			close(queue)
+		}
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+// pooledConsumeContext stops the worker pool together with the consume context.
+type pooledConsumeContext struct {
+	jetstream.ConsumeContext
+	pool *workerPool
+}
+
+// Stop stops consuming and waits for queued messages to be handled.
+func (c *pooledConsumeContext) Stop() {
+	c.ConsumeContext.Stop()
+	c.pool.close()
+}
+
+// Drain stops consuming and waits for queued messages to be handled.
+// Buffered messages delivered after the pool was closed are nak'd for redelivery.
+func (c *pooledConsumeContext) Drain() {
+	c.ConsumeContext.Drain()
+	c.pool.close()
+}
@@ -0,0 +1,201 @@
+package nats_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyFuncs(t *testing.T) {
+	t.Parallel()
+
+	msg := &nats.Message{ //nolint: exhaustruct
+		Subject: "orders.eu.42.created",
+		Headers: eventprocessor.Header{"Entity-Id": []string{"order-42"}},
+	}
+
+	tests := []struct {
+		name string
+		key  nats.KeyFunc
+		want string
+	}{
+		{name: "first token", key: nats.SubjectTokenKey(0), want: "orders"},
+		{name: "third token", key: nats.SubjectTokenKey(2), want: "42"},
+		{name: "last token", key: nats.SubjectTokenKey(-1), want: "created"},
+		{name: "out of range", key: nats.SubjectTokenKey(10), want: ""},
+		{name: "header", key: nats.HeaderKey("Entity-Id"), want: "order-42"},
+		{name: "missing header", key: nats.HeaderKey("Missing"), want: ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.key(msg), tt.name)
+	}
+}
+
+func TestWorkerPool(t *testing.T) { //nolint: funlen
+	t.Parallel()
+
+	cfg := natstest.NewConfig(t)
+
+	t.Run("ConcurrentWorkers", func(t *testing.T) {
+		t.Parallel()
+		const (
+			workers  = 4
+			messages = 20
+		)
+
+		client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:     "TEST_POOL_1",
+			Subjects: []string{"test.pool1.>"},
+		})
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		for i := range messages {
+			require.NoError(t, client.PublishToStream(ctx, "test.pool1."+strconv.Itoa(i), []byte("data")))
+		}
+
+		var active, peak, handled atomic.Int64
+		cc, err := client.CreateConsumer(ctx, "test-pool", func(context.Context, *nats.Message) nats.Result {
+			now := active.Add(1)
+			for {
+				old := peak.Load()
+				if now <= old || peak.CompareAndSwap(old, now) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			active.Add(-1)
+			handled.Add(1)
+
+			return nats.Ack()
+		}, nats.WithWorkerPool(nats.WorkerPoolConfig{Workers: workers, MaxInFlight: 0, Key: nil}))
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			return handled.Load() == messages
+		}, testTimeout, 10*time.Millisecond)
+		cc.Stop()
+
+		assert.Greater(t, peak.Load(), int64(1))
+		assert.LessOrEqual(t, peak.Load(), int64(workers))
+	})
+
+	t.Run("PerKeyOrdering", func(t *testing.T) {
+		t.Parallel()
+		const (
+			keys     = 5
+			perKey   = 20
+			messages = keys * perKey
+		)
+
+		client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:     "TEST_POOL_2",
+			Subjects: []string{"test.pool2.>"},
+		})
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		for i := range perKey {
+			for k := range keys {
+				subject := fmt.Sprintf("test.pool2.key%d", k)
+				require.NoError(t, client.PublishToStream(ctx, subject, []byte(strconv.Itoa(i))))
+			}
+		}
+
+		var (
+			mu     sync.Mutex
+			seen   = map[string][]int{}
+			total  int
+			byKey  = nats.SubjectTokenKey(-1)
+			poolOn = nats.WithWorkerPool(nats.WorkerPoolConfig{Workers: 3, MaxInFlight: 10, Key: byKey})
+		)
+		cc, err := client.CreateConsumer(ctx, "test-ordered", func(_ context.Context, msg *nats.Message) nats.Result {
+			n, err := strconv.Atoi(string(msg.Data))
+			if err != nil {
+				return nats.Term(err)
+			}
+			time.Sleep(time.Duration(n%3) * time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			seen[byKey(msg)] = append(seen[byKey(msg)], n)
+			total++
+
+			return nats.Ack()
+		}, poolOn)
+		require.NoError(t, err)
+		defer cc.Stop()
+
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return total == messages
+		}, testTimeout, 10*time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, seen, keys)
+		for key, values := range seen {
+			for i, v := range values {
+				assert.Equal(t, i, v, "key %s out of order", key)
+			}
+		}
+	})
+	t.Run("MaxAckPendingConflict", func(t *testing.T) {
+		t.Parallel()
+
+		client, err := nats.NewJetStreamClient(cfg, jetstream.StreamConfig{ //nolint: exhaustruct
+			Name:     "TEST_POOL_3",
+			Subjects: []string{"test.pool3.>"},
+		})
+		require.NoError(t, err)
+		defer client.Close(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		pool := nats.WithWorkerPool(nats.WorkerPoolConfig{Workers: 2, MaxInFlight: 10, Key: nil})
+		ack := func(context.Context, *nats.Message) nats.Result { return nats.Ack() }
+
+		tests := []struct {
+			name  string
+			opts  []nats.ConsumerOption
+			valid bool
+		}{
+			{name: "pool only", opts: []nats.ConsumerOption{pool}, valid: true},
+			{name: "matching", opts: []nats.ConsumerOption{pool, nats.WithMaxAckPending(10)}, valid: true},
+			{name: "lower after", opts: []nats.ConsumerOption{pool, nats.WithMaxAckPending(5)}, valid: false},
+			{name: "lower before", opts: []nats.ConsumerOption{nats.WithMaxAckPending(5), pool}, valid: false},
+			{name: "unlimited", opts: []nats.ConsumerOption{pool, nats.WithMaxAckPending(-1)}, valid: false},
+		}
+		for i, tt := range tests {
+			cc, err := client.CreateConsumer(ctx, "test-conflict-"+strconv.Itoa(i), ack, tt.opts...)
+			if !tt.valid {
+				require.ErrorIs(t, err, nats.ErrInvalidConfig, tt.name)
+
+				continue
+			}
+
+			require.NoError(t, err, tt.name)
+			cc.Stop()
+		}
+	})
+}
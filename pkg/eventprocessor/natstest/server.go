@@ -0,0 +1,68 @@
+// Package natstest starts embedded NATS servers so client tests run without external infrastructure.
+package natstest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/nats-io/nats-server/v2/server"
+	"go.uber.org/zap/zaptest"
+)
+
+// readyTimeout bounds how long to wait for the embedded server to accept connections.
+const readyTimeout = 10 * time.Second
+
+// NewServer starts an in-process nats-server with JetStream enabled on a random local port.
+// JetStream data is stored in a temporary directory; the server is shut down when the test ends.
+func NewServer(tb testing.TB) *server.Server {
+	tb.Helper()
+
+	opts := &server.Options{ //nolint: exhaustruct
+		ServerName: "natstest",
+		Host:       "127.0.0.1",
+		Port:       server.RANDOM_PORT,
+		JetStream:  true,
+		StoreDir:   tb.TempDir(),
+		NoLog:      true,
+		NoSigs:     true,
+	}
+
+	srv, err := server.NewServer(opts)
+	if err != nil {
+		tb.Fatalf("failed to create NATS server: %v", err)
+	}
+
+	go srv.Start()
+
+	if !srv.ReadyForConnections(readyTimeout) {
+		srv.Shutdown()
+		tb.Fatal("NATS server did not become ready")
+	}
+
+	tb.Cleanup(func() {
+		srv.Shutdown()
+		srv.WaitForShutdown()
+	})
+
+	return srv
+}
+
+// NewConfig starts an embedded server with NewServer and returns a client configuration pointing at it.
+// The configuration uses a test logger and short reconnect settings.
+func NewConfig(tb testing.TB) *nats.Config {
+	tb.Helper()
+
+	srv := NewServer(tb)
+
+	return &nats.Config{
+		URL:           srv.ClientURL(),
+		Token:         "",
+		CredsFile:     "",
+		MaxReconnects: nats.DefaultMaxReconnects,
+		ReconnectWait: time.Second * nats.DefaultReconnectWaitSeconds,
+		Logger:        zaptest.NewLogger(tb),
+		Metrics:       nil,
+		Tracing:       nil,
+	}
+}
@@ -0,0 +1,150 @@
+// Package ratelimit throttles publishing and consumption with token buckets configured per subject pattern.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"golang.org/x/time/rate"
+)
+
+// ErrInvalidRule is returned when a rule has no pattern, a non-positive rate or burst.
+var ErrInvalidRule = errors.New("invalid rate limit rule")
+
+// Rule limits the events on subjects matching a pattern.
+type Rule struct {
+	// Pattern is a NATS subject pattern, the first matching rule applies
+	Pattern string
+	// Rate is the sustained number of events per second
+	Rate float64
+	// Burst is the number of events allowed at once
+	Burst int
+}
+
+// Stats are counters for a single rule.
+type Stats struct {
+	// Pattern is the rule pattern
+	Pattern string
+	// Allowed is the number of events let through, including delayed ones
+	Allowed uint64
+	// Delayed is the number of events that had to wait for a token
+	Delayed uint64
+	// Waited is the total time events waited for tokens
+	Waited time.Duration
+}
+
+// Limiter applies token buckets to subjects, one bucket per rule.
+// Subjects matching no rule are not limited.
+type Limiter struct {
+	buckets []*bucket
+}
+
+// bucket is the token bucket and counters of a rule.
+type bucket struct {
+	rule    Rule
+	limiter *rate.Limiter
+	mu      sync.Mutex
+	stats   Stats
+}
+
+// NewLimiter creates a limiter from rules, evaluated in order.
+func NewLimiter(rules ...Rule) (*Limiter, error) {
+	buckets := make([]*bucket, 0, len(rules))
+
+	for _, rule := range rules {
+		if rule.Pattern == "" || rule.Rate <= 0 || rule.Burst < 1 {
+			return nil, fmt.Errorf("%w: %+v", ErrInvalidRule, rule)
+		}
+
+		buckets = append(buckets, &bucket{
+			rule:    rule,
+			limiter: rate.NewLimiter(rate.Limit(rule.Rate), rule.Burst),
+			mu:      sync.Mutex{},
+			stats:   Stats{Pattern: rule.Pattern, Allowed: 0, Delayed: 0, Waited: 0},
+		})
+	}
+
+	return &Limiter{buckets: buckets}, nil
+}
+
+// Wait blocks until an event on subject is allowed or ctx is done.
+func (l *Limiter) Wait(ctx context.Context, subject string) error {
+	b := l.match(subject)
+	if b == nil {
+		return nil
+	}
+
+	reservation := b.limiter.Reserve()
+
+	delay := reservation.Delay()
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			reservation.Cancel()
+
+			return fmt.Errorf("rate limit wait for %s: %w", subject, ctx.Err())
+		}
+	}
+
+	b.record(delay)
+
+	return nil
+}
+
+// Stats returns the counters of every rule, in rule order.
+func (l *Limiter) Stats() []Stats {
+	out := make([]Stats, 0, len(l.buckets))
+
+	for _, b := range l.buckets {
+		b.mu.Lock()
+		out = append(out, b.stats)
+		b.mu.Unlock()
+	}
+
+	return out
+}
+
+// MinBurst returns the smallest burst among the rules, or zero when there are none.
+func (l *Limiter) MinBurst() int {
+	smallest := 0
+
+	for _, b := range l.buckets {
+		if smallest == 0 || b.rule.Burst < smallest {
+			smallest = b.rule.Burst
+		}
+	}
+
+	return smallest
+}
+
+// match returns the bucket of the first rule matching subject.
+func (l *Limiter) match(subject string) *bucket {
+	for _, b := range l.buckets {
+		if eventprocessor.MatchSubject(b.rule.Pattern, subject) {
+			return b
+		}
+	}
+
+	return nil
+}
+
+// record updates the counters after an event was allowed.
+func (b *bucket) record(delay time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stats.Allowed++
+
+	if delay > 0 {
+		b.stats.Delayed++
+		b.stats.Waited += delay
+	}
+}
@@ -0,0 +1,93 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/memory"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLimiter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		rule  ratelimit.Rule
+		valid bool
+	}{
+		{name: "valid", rule: ratelimit.Rule{Pattern: "orders.>", Rate: 10, Burst: 1}, valid: true},
+		{name: "no pattern", rule: ratelimit.Rule{Pattern: "", Rate: 10, Burst: 1}, valid: false},
+		{name: "zero rate", rule: ratelimit.Rule{Pattern: "orders.>", Rate: 0, Burst: 1}, valid: false},
+		{name: "zero burst", rule: ratelimit.Rule{Pattern: "orders.>", Rate: 10, Burst: 0}, valid: false},
+	}
+	for _, tt := range tests {
+		_, err := ratelimit.NewLimiter(tt.rule)
+		if tt.valid {
+			assert.NoError(t, err, tt.name)
+		} else {
+			assert.ErrorIs(t, err, ratelimit.ErrInvalidRule, tt.name)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	t.Parallel()
+
+	limiter, err := ratelimit.NewLimiter(
+		ratelimit.Rule{Pattern: "orders.slow.>", Rate: 20, Burst: 1},
+		ratelimit.Rule{Pattern: "orders.>", Rate: 1000, Burst: 100},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 1, limiter.MinBurst())
+
+	ctx := context.Background()
+	start := time.Now()
+
+	for range 3 {
+		require.NoError(t, limiter.Wait(ctx, "orders.slow.eu"))
+		require.NoError(t, limiter.Wait(ctx, "orders.fast"))
+		require.NoError(t, limiter.Wait(ctx, "payments.created"))
+	}
+
+	// Two slow events had to wait 50ms each for a token
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+
+	stats := limiter.Stats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, "orders.slow.>", stats[0].Pattern)
+	assert.Equal(t, uint64(3), stats[0].Allowed)
+	assert.Equal(t, uint64(2), stats[0].Delayed)
+	assert.Positive(t, stats[0].Waited)
+	assert.Equal(t, uint64(3), stats[1].Allowed)
+	assert.Equal(t, uint64(0), stats[1].Delayed)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	require.NoError(t, limiter.Wait(canceled, "payments.created"))
+	assert.Error(t, limiter.Wait(canceled, "orders.slow.eu"))
+}
+
+func TestPublisher(t *testing.T) {
+	t.Parallel()
+
+	limiter, err := ratelimit.NewLimiter(ratelimit.Rule{Pattern: "events.*", Rate: 50, Burst: 2})
+	require.NoError(t, err)
+
+	broker := memory.NewBroker()
+	publisher := ratelimit.NewPublisher(broker, limiter)
+	defer publisher.Close(context.Background())
+
+	start := time.Now()
+	for range 5 {
+		require.NoError(t, publisher.PublishToStream(context.Background(), "events.tick", []byte("tick")))
+	}
+
+	// The burst covers two publishes, the other three wait 20ms each
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Len(t, broker.Messages("events.>"), 5)
+	assert.Equal(t, uint64(3), publisher.Stats()[0].Delayed)
+}
@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"context"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+)
+
+// Compile-time check that Publisher implements EventProcessor.
+var _ eventprocessor.EventProcessor = (*Publisher)(nil)
+
+// Publisher wraps an EventProcessor, delaying publishes that exceed the limiter rules.
+// Callers are blocked rather than rejected, which pushes back on bursty producers.
+type Publisher struct {
+	next    eventprocessor.EventProcessor
+	limiter *Limiter
+}
+
+// NewPublisher wraps next with limiter.
+func NewPublisher(next eventprocessor.EventProcessor, limiter *Limiter) *Publisher {
+	return &Publisher{next: next, limiter: limiter}
+}
+
+// PublishToStream implements the EventProcessor interface.
+// It waits for the rule matching topic and publishes through the wrapped processor.
+func (p *Publisher) PublishToStream(ctx context.Context, topic string, data []byte) error {
+	if err := p.limiter.Wait(ctx, topic); err != nil {
+		return err
+	}
+
+	return p.next.PublishToStream(ctx, topic, data) //nolint: wrapcheck
+}
+
+// Close implements the EventProcessor interface by closing the wrapped processor.
+func (p *Publisher) Close(ctx context.Context) error {
+	return p.next.Close(ctx) //nolint: wrapcheck
+}
+
+// Stats returns the limiter counters.
+func (p *Publisher) Stats() []Stats {
+	return p.limiter.Stats()
+}
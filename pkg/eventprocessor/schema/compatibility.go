@@ -0,0 +1,162 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Compatibility describes which direction a new schema version must stay compatible in.
+type Compatibility int
+
+const (
+	// CompatibilityNone performs no checks.
+	CompatibilityNone Compatibility = iota
+	// CompatibilityBackward requires consumers using the new schema to accept data written with the old one.
+	CompatibilityBackward
+	// CompatibilityForward requires consumers using the old schema to accept data written with the new one.
+	CompatibilityForward
+	// CompatibilityFull requires both backward and forward compatibility.
+	CompatibilityFull
+)
+
+// String returns the lowercase name of the mode.
+func (c Compatibility) String() string {
+	switch c {
+	case CompatibilityNone:
+		return "none"
+	case CompatibilityBackward:
+		return "backward"
+	case CompatibilityForward:
+		return "forward"
+	case CompatibilityFull:
+		return "full"
+	default:
+		return fmt.Sprintf("compatibility(%d)", int(c))
+	}
+}
+
+// CheckCompatibility checks next against previous in mode.
+// The check is structural: it compares object properties, required properties, property types
+// and closed objects (additionalProperties false), recursing into nested objects.
+// Returns an error wrapping ErrIncompatible listing every violation.
+func CheckCompatibility(previous, next *Schema, mode Compatibility) error {
+	var issues []string
+
+	if mode == CompatibilityBackward || mode == CompatibilityFull {
+		issues = append(issues, readable(next.doc, previous.doc, "")...)
+	}
+
+	if mode == CompatibilityForward || mode == CompatibilityFull {
+		issues = append(issues, readable(previous.doc, next.doc, "")...)
+	}
+
+	if len(issues) > 0 {
+		return fmt.Errorf("%s version %s is not %s compatible with version %s: %w: %s",
+			next.Type, next.Version, mode, previous.Version, ErrIncompatible, strings.Join(issues, "; "))
+	}
+
+	return nil
+}
+
+// readable lists the reasons data valid against writer may be rejected by reader.
+func readable(reader, writer map[string]any, path string) []string {
+	var issues []string
+
+	readerTypes, writerTypes := types(reader), types(writer)
+	if len(readerTypes) > 0 {
+		if len(writerTypes) == 0 {
+			return []string{fmt.Sprintf("%s is no longer untyped", location(path))}
+		}
+
+		for _, t := range writerTypes {
+			if !slices.Contains(readerTypes, t) && (t != "integer" || !slices.Contains(readerTypes, "number")) {
+				issues = append(issues, fmt.Sprintf("%s no longer accepts %s", location(path), t))
+			}
+		}
+	}
+
+	readerProps, writerProps := properties(reader), properties(writer)
+	writerRequired := required(writer)
+
+	for _, name := range required(reader) {
+		if !slices.Contains(writerRequired, name) {
+			issues = append(issues, fmt.Sprintf("%s is required but may be missing", location(path+"."+name)))
+		}
+	}
+
+	if closed, ok := reader["additionalProperties"].(bool); ok && !closed {
+		for name := range writerProps {
+			if _, ok := readerProps[name]; !ok {
+				issues = append(issues, fmt.Sprintf("%s is not allowed", location(path+"."+name)))
+			}
+		}
+	}
+
+	for name, readerProp := range readerProps {
+		if writerProp, ok := writerProps[name]; ok {
+			issues = append(issues, readable(readerProp, writerProp, path+"."+name)...)
+		}
+	}
+
+	slices.Sort(issues)
+
+	return issues
+}
+
+// types returns the JSON types accepted by schema, empty when unconstrained.
+func types(schema map[string]any) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []any:
+		var out []string
+
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				out = append(out, s)
+			}
+		}
+
+		return out
+	default:
+		return nil
+	}
+}
+
+// properties returns the object property schemas declared by schema.
+func properties(schema map[string]any) map[string]map[string]any {
+	props, _ := schema["properties"].(map[string]any)
+	out := make(map[string]map[string]any, len(props))
+
+	for name, prop := range props {
+		if m, ok := prop.(map[string]any); ok {
+			out[name] = m
+		}
+	}
+
+	return out
+}
+
+// required returns the required property names of schema.
+func required(schema map[string]any) []string {
+	names, _ := schema["required"].([]any)
+	out := make([]string, 0, len(names))
+
+	for _, name := range names {
+		if s, ok := name.(string); ok {
+			out = append(out, s)
+		}
+	}
+
+	return out
+}
+
+// location formats a property path for messages.
+func location(path string) string {
+	if path == "" {
+		return "root"
+	}
+
+	return strings.TrimPrefix(path, ".")
+}
@@ -0,0 +1,256 @@
+// Package schema provides a local JSON Schema registry for validating event payloads on publish and consume.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+var (
+	// ErrSchemaNotFound is returned when no schema is registered for an event type and version.
+	ErrSchemaNotFound = errors.New("schema not found")
+	// ErrInvalidSchema is returned when a schema document cannot be compiled.
+	ErrInvalidSchema = errors.New("invalid schema")
+	// ErrInvalidPayload is returned when a payload does not conform to its schema.
+	ErrInvalidPayload = errors.New("invalid payload")
+	// ErrIncompatible is returned when a schema version breaks compatibility with the previous one.
+	ErrIncompatible = errors.New("incompatible schema")
+)
+
+// schemaExt is the extension of schema files loaded by LoadDir.
+const schemaExt = ".json"
+
+// Schema is a compiled JSON Schema for one version of an event type.
+type Schema struct {
+	// Type is the event type the schema describes
+	Type string
+	// Version is the schema version
+	Version string
+
+	doc      map[string]any
+	compiled *jsonschema.Schema
+}
+
+// Validate checks that data is JSON conforming to the schema.
+func (s *Schema) Validate(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("%s version %s: %w: %w", s.Type, s.Version, ErrInvalidPayload, err)
+	}
+
+	if err := s.compiled.Validate(v); err != nil {
+		return fmt.Errorf("%s version %s: %w: %w", s.Type, s.Version, ErrInvalidPayload, err)
+	}
+
+	return nil
+}
+
+// Registry holds JSON Schemas keyed by event type and version.
+// It is safe for concurrent use.
+type Registry struct {
+	mu            sync.RWMutex
+	schemas       map[string]map[string]*Schema
+	bindings      []binding
+	compatibility Compatibility
+	strict        bool
+}
+
+// RegistryOption configures a Registry.
+type RegistryOption func(*Registry)
+
+// WithCompatibility makes Register reject versions that are not compatible with every registered version in mode.
+func WithCompatibility(mode Compatibility) RegistryOption {
+	return func(r *Registry) {
+		r.compatibility = mode
+	}
+}
+
+// WithStrict makes Handler terminate events whose type has no registered schema,
+// which it passes through by default.
+func WithStrict() RegistryOption {
+	return func(r *Registry) {
+		r.strict = true
+	}
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry(opts ...RegistryOption) *Registry {
+	r := &Registry{
+		mu:            sync.RWMutex{},
+		schemas:       make(map[string]map[string]*Schema),
+		bindings:      nil,
+		compatibility: CompatibilityNone,
+		strict:        false,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// LoadDir registers every schema file in dir, laid out as <event type>/<version>.json.
+// Versions of each type are registered in ascending order.
+func (r *Registry) LoadDir(dir string) error {
+	types, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read schema directory: %w", err)
+	}
+
+	for _, typeDir := range types {
+		if !typeDir.IsDir() {
+			continue
+		}
+
+		files, err := os.ReadDir(filepath.Join(dir, typeDir.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read schema directory: %w", err)
+		}
+
+		var versions []string
+
+		for _, file := range files {
+			if !file.IsDir() && filepath.Ext(file.Name()) == schemaExt {
+				versions = append(versions, strings.TrimSuffix(file.Name(), schemaExt))
+			}
+		}
+
+		slices.SortFunc(versions, compareVersions)
+
+		for _, version := range versions {
+			doc, err := os.ReadFile(filepath.Join(dir, typeDir.Name(), version+schemaExt))
+			if err != nil {
+				return fmt.Errorf("failed to read schema: %w", err)
+			}
+
+			if err := r.Register(typeDir.Name(), version, doc); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Register compiles doc and registers it for eventType and version.
+// With a compatibility mode set, the schema is checked against every registered version of eventType:
+// older versions as the previous and newer ones as the next schema.
+func (r *Registry) Register(eventType, version string, doc []byte) error {
+	if eventType == "" || version == "" {
+		return fmt.Errorf("event type and version are required: %w", ErrInvalidSchema)
+	}
+
+	var parsed map[string]any
+	if err := json.Unmarshal(doc, &parsed); err != nil {
+		return fmt.Errorf("%s version %s: %w: %w", eventType, version, ErrInvalidSchema, err)
+	}
+
+	url := "mem:///" + eventType + "/" + version + schemaExt
+	compiler := jsonschema.NewCompiler()
+
+	if err := compiler.AddResource(url, bytes.NewReader(doc)); err != nil {
+		return fmt.Errorf("%s version %s: %w: %w", eventType, version, ErrInvalidSchema, err)
+	}
+
+	compiled, err := compiler.Compile(url)
+	if err != nil {
+		return fmt.Errorf("%s version %s: %w: %w", eventType, version, ErrInvalidSchema, err)
+	}
+
+	schema := &Schema{Type: eventType, Version: version, doc: parsed, compiled: compiled}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, v := range r.versions(eventType) {
+		previous, next := r.schemas[eventType][v], schema
+		if compareVersions(v, version) > 0 {
+			previous, next = next, previous
+		}
+
+		if err := CheckCompatibility(previous, next, r.compatibility); err != nil {
+			return err
+		}
+	}
+
+	if r.schemas[eventType] == nil {
+		r.schemas[eventType] = make(map[string]*Schema)
+	}
+
+	r.schemas[eventType][version] = schema
+
+	return nil
+}
+
+// Lookup returns the schema for eventType and version, or the latest version when version is empty.
+func (r *Registry) Lookup(eventType, version string) (*Schema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if version == "" {
+		versions := r.versions(eventType)
+		if len(versions) > 0 {
+			version = versions[len(versions)-1]
+		}
+	}
+
+	schema, ok := r.schemas[eventType][version]
+	if !ok {
+		return nil, fmt.Errorf("%s version %q: %w", eventType, version, ErrSchemaNotFound)
+	}
+
+	return schema, nil
+}
+
+// Versions returns the registered versions of eventType in ascending order.
+func (r *Registry) Versions(eventType string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.versions(eventType)
+}
+
+// versions returns the sorted versions of eventType, the caller must hold the lock.
+func (r *Registry) versions(eventType string) []string {
+	versions := make([]string, 0, len(r.schemas[eventType]))
+	for version := range r.schemas[eventType] {
+		versions = append(versions, version)
+	}
+
+	slices.SortFunc(versions, compareVersions)
+
+	return versions
+}
+
+// Validate checks data against the schema for eventType and version, the latest when version is empty.
+func (r *Registry) Validate(eventType, version string, data []byte) error {
+	schema, err := r.Lookup(eventType, version)
+	if err != nil {
+		return err
+	}
+
+	return schema.Validate(data)
+}
+
+// compareVersions orders numeric versions numerically and other versions lexically.
+func compareVersions(a, b string) int {
+	an, aErr := strconv.Atoi(strings.TrimPrefix(a, "v"))
+	bn, bErr := strconv.Atoi(strings.TrimPrefix(b, "v"))
+
+	if aErr == nil && bErr == nil {
+		return an - bn
+	}
+
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,144 @@
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	registry := schema.NewRegistry(schema.WithCompatibility(schema.CompatibilityFull))
+	require.NoError(t, registry.LoadDir("testdata/schemas"))
+
+	assert.Equal(t, []string{"1", "2"}, registry.Versions("orders.created"))
+
+	latest, err := registry.Lookup("orders.created", "")
+	require.NoError(t, err)
+	assert.Equal(t, "2", latest.Version)
+
+	_, err = registry.Lookup("orders.created", "3")
+	require.ErrorIs(t, err, schema.ErrSchemaNotFound)
+	_, err = registry.Lookup("orders.deleted", "")
+	require.ErrorIs(t, err, schema.ErrSchemaNotFound)
+
+	tests := []struct {
+		name    string
+		version string
+		data    string
+		valid   bool
+	}{
+		{name: "valid", version: "1", data: `{"id":"o-1","total":5}`, valid: true},
+		{name: "valid latest", version: "", data: `{"id":"o-1","total":5,"currency":"CZK"}`, valid: true},
+		{name: "missing required", version: "1", data: `{"id":"o-1"}`, valid: false},
+		{name: "wrong type", version: "2", data: `{"id":1,"total":5}`, valid: false},
+		{name: "below minimum", version: "2", data: `{"id":"o-1","total":-1}`, valid: false},
+		{name: "not JSON", version: "1", data: `id=o-1`, valid: false},
+	}
+	for _, tt := range tests {
+		err := registry.Validate("orders.created", tt.version, []byte(tt.data))
+		if tt.valid {
+			assert.NoError(t, err, tt.name)
+		} else {
+			assert.ErrorIs(t, err, schema.ErrInvalidPayload, tt.name)
+		}
+	}
+
+	require.ErrorIs(t, registry.Register("orders.created", "4", []byte(`{"type": 5}`)), schema.ErrInvalidSchema)
+	require.ErrorIs(t, registry.Register("orders.created", "4", []byte(`not json`)), schema.ErrInvalidSchema)
+	require.ErrorIs(t, registry.Register("", "1", []byte(`{}`)), schema.ErrInvalidSchema)
+
+	// New required properties break older producers
+	err = registry.Register("orders.created", "3", []byte(`{
+		"type": "object",
+		"properties": {"id": {"type": "string"}, "total": {"type": "number"}, "customer": {"type": "string"}},
+		"required": ["id", "total", "customer"]
+	}`))
+	require.ErrorIs(t, err, schema.ErrIncompatible)
+	assert.Contains(t, err.Error(), "customer is required but may be missing")
+	assert.Equal(t, []string{"1", "2"}, registry.Versions("orders.created"))
+
+	// Every registered version is checked, not only the latest one
+	history := schema.NewRegistry(schema.WithCompatibility(schema.CompatibilityBackward))
+	require.NoError(t, history.Register("users.renamed", "1", []byte(`{"properties": {"age": {"type": "string"}}}`)))
+	require.NoError(t, history.Register("users.renamed", "2", []byte(`{"properties": {}}`)))
+	err = history.Register("users.renamed", "3", []byte(`{"properties": {"age": {"type": "number"}}}`))
+	require.ErrorIs(t, err, schema.ErrIncompatible)
+	assert.Contains(t, err.Error(), "with version 1")
+
+	require.Error(t, schema.NewRegistry().LoadDir("testdata/missing"))
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	t.Parallel()
+
+	const base = `{
+		"type": "object",
+		"properties": {"id": {"type": "string"}, "total": {"type": "integer"}},
+		"required": ["id"]
+	}`
+
+	tests := []struct {
+		name     string
+		next     string
+		backward bool
+		forward  bool
+	}{
+		{
+			name:     "add optional property",
+			next:     `{"type":"object","properties":{"id":{"type":"string"},"total":{"type":"integer"},"note":{"type":"string"}},"required":["id"]}`,
+			backward: true,
+			forward:  true,
+		},
+		{
+			name:     "add required property",
+			next:     `{"type":"object","properties":{"id":{"type":"string"},"total":{"type":"integer"}},"required":["id","total"]}`,
+			backward: false,
+			forward:  true,
+		},
+		{
+			name:     "widen integer to number",
+			next:     `{"type":"object","properties":{"id":{"type":"string"},"total":{"type":"number"}},"required":["id"]}`,
+			backward: true,
+			forward:  false,
+		},
+		{
+			name:     "change property type",
+			next:     `{"type":"object","properties":{"id":{"type":"integer"},"total":{"type":"integer"}},"required":["id"]}`,
+			backward: false,
+			forward:  false,
+		},
+		{
+			name:     "close object and drop property",
+			next:     `{"type":"object","properties":{"id":{"type":"string"}},"required":["id"],"additionalProperties":false}`,
+			backward: false,
+			forward:  true,
+		},
+	}
+	for _, tt := range tests {
+		registry := schema.NewRegistry()
+		require.NoError(t, registry.Register("orders", "1", []byte(base)), tt.name)
+		require.NoError(t, registry.Register("orders", "2", []byte(tt.next)), tt.name)
+
+		previous, err := registry.Lookup("orders", "1")
+		require.NoError(t, err)
+		next, err := registry.Lookup("orders", "2")
+		require.NoError(t, err)
+
+		check := func(mode schema.Compatibility, ok bool) {
+			err := schema.CheckCompatibility(previous, next, mode)
+			if ok {
+				assert.NoError(t, err, tt.name, mode)
+			} else {
+				assert.ErrorIs(t, err, schema.ErrIncompatible, tt.name, mode)
+			}
+		}
+		check(schema.CompatibilityBackward, tt.backward)
+		check(schema.CompatibilityForward, tt.forward)
+		check(schema.CompatibilityFull, tt.backward && tt.forward)
+		check(schema.CompatibilityNone, true)
+	}
+}
@@ -0,0 +1,135 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+)
+
+// ErrEventsUnsupported is returned by Publisher.PublishEvent when the wrapped processor does not publish events.
+var ErrEventsUnsupported = errors.New("events are not supported")
+
+// binding associates subjects matching pattern with an event type.
+type binding struct {
+	pattern   string
+	eventType string
+	version   string
+}
+
+// Compile-time checks that Publisher can replace the processor it wraps.
+var (
+	_ eventprocessor.EventProcessor = (*Publisher)(nil)
+	_ eventprocessor.EventPublisher = (*Publisher)(nil)
+)
+
+// Publisher validates payloads against a Registry before publishing them with the wrapped EventProcessor.
+type Publisher struct {
+	next     eventprocessor.EventProcessor
+	registry *Registry
+}
+
+// NewPublisher creates a Publisher validating with registry and publishing with next.
+func NewPublisher(next eventprocessor.EventProcessor, registry *Registry) *Publisher {
+	return &Publisher{next: next, registry: registry}
+}
+
+// PublishToStream validates data against the schema bound to topic with Registry.Bind and publishes it.
+// Topics without a binding are published unvalidated.
+func (p *Publisher) PublishToStream(ctx context.Context, topic string, data []byte) error {
+	if eventType, version, ok := p.registry.bound(topic); ok {
+		if err := p.registry.Validate(eventType, version, data); err != nil {
+			return err
+		}
+	}
+
+	return p.next.PublishToStream(ctx, topic, data) //nolint: wrapcheck
+}
+
+// PublishEvent validates the event payload against the schema of its type and schema version and publishes it.
+// The wrapped EventProcessor must implement eventprocessor.EventPublisher.
+func (p *Publisher) PublishEvent(ctx context.Context, topic string, event eventprocessor.Event) error {
+	publisher, ok := p.next.(eventprocessor.EventPublisher)
+	if !ok {
+		return fmt.Errorf("%T: %w", p.next, ErrEventsUnsupported)
+	}
+
+	if err := p.registry.Validate(event.Type, event.SchemaVersion, event.Data); err != nil {
+		return err
+	}
+
+	return publisher.PublishEvent(ctx, topic, event) //nolint: wrapcheck
+}
+
+// Close closes the wrapped EventProcessor.
+func (p *Publisher) Close(ctx context.Context) error {
+	return p.next.Close(ctx) //nolint: wrapcheck
+}
+
+// Handler wraps handler so consumed payloads are validated before handling.
+// The schema is chosen from the Event or CloudEvent envelope, falling back to subject bindings;
+// messages with neither are passed through, and so are events of types without any registered schema
+// unless the registry was created WithStrict. Invalid messages and messages whose schema version is unknown
+// are terminated with a permanent error, so a DeadLetterQueue wrapping the result dead-letters them.
+func (r *Registry) Handler(handler nats.Handler) nats.Handler {
+	return func(ctx context.Context, msg *nats.Message) nats.Result {
+		eventType, version, payload, ok := r.messageSchema(msg)
+		if !ok || (!r.strict && !r.registered(eventType)) {
+			return handler(ctx, msg)
+		}
+
+		if err := r.Validate(eventType, version, payload); err != nil {
+			return nats.Term(nats.Permanent(err))
+		}
+
+		return handler(ctx, msg)
+	}
+}
+
+// messageSchema returns the event type, schema version and payload of msg.
+func (r *Registry) messageSchema(msg *nats.Message) (string, string, []byte, bool) {
+	if event, err := msg.Event(); err == nil {
+		return event.Type, event.SchemaVersion, event.Data, true
+	}
+
+	if event, err := msg.CloudEvent(); err == nil {
+		return event.Type, event.SchemaVersion, event.Data, true
+	}
+
+	eventType, version, ok := r.bound(msg.Subject)
+
+	return eventType, version, msg.Data, ok
+}
+
+// registered reports whether any version of eventType is registered.
+func (r *Registry) registered(eventType string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.schemas[eventType]) > 0
+}
+
+// Bind validates raw payloads published to subjects matching pattern against eventType and version.
+// An empty version always uses the latest registered version.
+func (r *Registry) Bind(pattern, eventType, version string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.bindings = append(r.bindings, binding{pattern: pattern, eventType: eventType, version: version})
+}
+
+// bound returns the event type and version bound to subject by the first matching Bind.
+func (r *Registry) bound(subject string) (string, string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, b := range r.bindings {
+		if eventprocessor.MatchSubject(b.pattern, subject) {
+			return b.eventType, b.version, true
+		}
+	}
+
+	return "", "", false
+}
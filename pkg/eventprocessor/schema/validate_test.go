@@ -0,0 +1,140 @@
+package schema_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/memory"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRegistry loads the test schemas and binds the payments subjects.
+func newRegistry(t *testing.T) *schema.Registry {
+	t.Helper()
+
+	registry := schema.NewRegistry()
+	require.NoError(t, registry.LoadDir("testdata/schemas"))
+	registry.Bind("payments.*.settled", "payments.settled", "1")
+
+	return registry
+}
+
+func TestPublisher(t *testing.T) {
+	t.Parallel()
+
+	broker := memory.NewBroker()
+	publisher := schema.NewPublisher(broker, newRegistry(t))
+	defer publisher.Close(context.Background())
+
+	ctx := context.Background()
+	require.NoError(t, publisher.PublishToStream(ctx, "payments.eu.settled", []byte(`{"order_id":"o-1"}`)))
+	require.ErrorIs(t, publisher.PublishToStream(ctx, "payments.eu.settled", []byte(`{}`)), schema.ErrInvalidPayload)
+	require.NoError(t, publisher.PublishToStream(ctx, "audit.log", []byte(`unbound`)))
+	assert.Len(t, broker.Messages(">"), 2)
+
+	event := nats.Event{ID: "1", Type: "orders.created", Source: "test", Data: []byte(`{"id":"o-1","total":1}`)} //nolint: exhaustruct
+	require.NoError(t, publisher.PublishEvent(ctx, "orders.created", event))
+
+	event.Data = []byte(`{"id":"o-1"}`)
+	require.ErrorIs(t, publisher.PublishEvent(ctx, "orders.created", event), schema.ErrInvalidPayload)
+	assert.Len(t, broker.Messages("orders.>"), 1)
+
+	// Processors without PublishEvent cannot publish events
+	raw := schema.NewPublisher(struct{ eventprocessor.EventProcessor }{broker}, newRegistry(t))
+	require.ErrorIs(t, raw.PublishEvent(ctx, "orders.created", event), schema.ErrEventsUnsupported)
+}
+
+func TestRegistryHandler(t *testing.T) {
+	t.Parallel()
+
+	ack := func(context.Context, *nats.Message) nats.Result {
+		return nats.Ack()
+	}
+	handler := newRegistry(t).Handler(ack)
+
+	strict := schema.NewRegistry(schema.WithStrict())
+	require.NoError(t, strict.LoadDir("testdata/schemas"))
+	strictHandler := strict.Handler(ack)
+
+	eventHeader := func(eventType, version string) eventprocessor.Header {
+		return eventprocessor.Header{
+			nats.EventIDHeader:            []string{"1"},
+			nats.EventTypeHeader:          []string{eventType},
+			nats.EventSourceHeader:        []string{"test"},
+			nats.EventSchemaVersionHeader: []string{version},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		subject string
+		header  eventprocessor.Header
+		data    string
+		action  nats.Action
+	}{
+		{
+			name:    "valid event",
+			subject: "orders",
+			header:  eventHeader("orders.created", "1"),
+			data:    `{"id":"o-1","total":1}`,
+			action:  nats.ActionAck,
+		},
+		{
+			name:    "invalid event",
+			subject: "orders",
+			header:  eventHeader("orders.created", "2"),
+			data:    `{"id":"o-1"}`,
+			action:  nats.ActionTerm,
+		},
+		{
+			name:    "unknown schema version",
+			subject: "orders",
+			header:  eventHeader("orders.created", "9"),
+			data:    `{"id":"o-1","total":1}`,
+			action:  nats.ActionTerm,
+		},
+		{
+			name:    "unregistered event type",
+			subject: "shipments",
+			header:  eventHeader("shipments.sent", "1"),
+			data:    `anything`,
+			action:  nats.ActionAck,
+		},
+		{
+			name:    "structured CloudEvent",
+			subject: "orders",
+			header:  eventprocessor.Header{"content-type": []string{nats.CloudEventsContentType}},
+			data: `{"specversion":"1.0","id":"1","source":"test","type":"orders.created",` +
+				`"datacontenttype":"application/json","data":{"id":"o-1","total":1}}`,
+			action: nats.ActionAck,
+		},
+		{name: "bound subject", subject: "payments.us.settled", header: nil, data: `{"order_id":"o-1"}`, action: nats.ActionAck},
+		{name: "invalid bound subject", subject: "payments.us.settled", header: nil, data: `{}`, action: nats.ActionTerm},
+		{name: "unbound subject", subject: "audit.log", header: nil, data: `anything`, action: nats.ActionAck},
+	}
+	for _, tt := range tests {
+		res := handler(context.Background(), &nats.Message{ //nolint: exhaustruct
+			Subject: tt.subject,
+			Headers: tt.header,
+			Data:    []byte(tt.data),
+		})
+		assert.Equal(t, tt.action, res.Action, tt.name)
+
+		if tt.action == nats.ActionTerm {
+			assert.ErrorIs(t, res.Err, nats.ErrPermanent, tt.name)
+		}
+	}
+
+	// Strict registries reject event types without a schema
+	res := strictHandler(context.Background(), &nats.Message{ //nolint: exhaustruct
+		Subject: "shipments",
+		Headers: eventHeader("shipments.sent", "1"),
+		Data:    []byte(`anything`),
+	})
+	assert.Equal(t, nats.ActionTerm, res.Action)
+	require.ErrorIs(t, res.Err, schema.ErrSchemaNotFound)
+}
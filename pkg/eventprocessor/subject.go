@@ -0,0 +1,47 @@
+package eventprocessor
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalidSubject is returned when a subject is empty or malformed.
+var ErrInvalidSubject = errors.New("invalid subject")
+
+// MatchSubject reports whether subject matches pattern using NATS wildcard rules.
+// Tokens are separated by '.', '*' matches exactly one token and a trailing '>' matches one or more tokens.
+func MatchSubject(pattern, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+
+	for i, token := range patternTokens {
+		if token == ">" {
+			return i == len(patternTokens)-1 && len(subjectTokens) > i
+		}
+
+		if i >= len(subjectTokens) {
+			return false
+		}
+
+		if token != "*" && token != subjectTokens[i] {
+			return false
+		}
+	}
+
+	return len(patternTokens) == len(subjectTokens)
+}
+
+// ValidateSubject checks that subject is a literal publish subject without empty tokens or wildcards.
+func ValidateSubject(subject string) error {
+	if subject == "" {
+		return ErrInvalidSubject
+	}
+
+	for _, token := range strings.Split(subject, ".") {
+		if token == "" || token == "*" || token == ">" || strings.ContainsAny(token, " \t\r\n") {
+			return ErrInvalidSubject
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package eventprocessor_test
+
+import (
+	"testing"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchSubject(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		subject string
+		want    bool
+	}{
+		{pattern: "orders.created", subject: "orders.created", want: true},
+		{pattern: "orders.created", subject: "orders.deleted", want: false},
+		{pattern: "orders.*", subject: "orders.created", want: true},
+		{pattern: "orders.*", subject: "orders.created.eu", want: false},
+		{pattern: "*.created", subject: "orders.created", want: true},
+		{pattern: "orders.>", subject: "orders.created", want: true},
+		{pattern: "orders.>", subject: "orders.created.eu", want: true},
+		{pattern: "orders.>", subject: "orders", want: false},
+		{pattern: ">", subject: "orders", want: true},
+		{pattern: "orders.>.eu", subject: "orders.created.eu", want: false},
+		{pattern: "orders", subject: "orders.created", want: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, eventprocessor.MatchSubject(tt.pattern, tt.subject), "%s ~ %s", tt.pattern, tt.subject)
+	}
+}
+
+func TestValidateSubject(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		subject string
+		valid   bool
+	}{
+		{subject: "orders.created", valid: true},
+		{subject: "orders", valid: true},
+		{subject: "", valid: false},
+		{subject: "orders..created", valid: false},
+		{subject: "orders.*", valid: false},
+		{subject: "orders.>", valid: false},
+		{subject: "orders created", valid: false},
+	}
+	for _, tt := range tests {
+		err := eventprocessor.ValidateSubject(tt.subject)
+		if tt.valid {
+			assert.NoError(t, err, tt.subject)
+		} else {
+			assert.ErrorIs(t, err, eventprocessor.ErrInvalidSubject, tt.subject)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package topology
+
+import (
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// kvStreamPrefix is the prefix of the stream backing a key-value bucket.
+const kvStreamPrefix = "KV_"
+
+// defaultDuplicateWindow is the duplicate window the client library applies to key-value buckets.
+const defaultDuplicateWindow = 2 * time.Minute
+
+var (
+	retentionPolicies = map[string]jetstream.RetentionPolicy{
+		"":          jetstream.LimitsPolicy,
+		"limits":    jetstream.LimitsPolicy,
+		"interest":  jetstream.InterestPolicy,
+		"workqueue": jetstream.WorkQueuePolicy,
+	}
+	storageTypes = map[string]jetstream.StorageType{
+		"":       jetstream.FileStorage,
+		"file":   jetstream.FileStorage,
+		"memory": jetstream.MemoryStorage,
+	}
+	discardPolicies = map[string]jetstream.DiscardPolicy{
+		"":    jetstream.DiscardOld,
+		"old": jetstream.DiscardOld,
+		"new": jetstream.DiscardNew,
+	}
+	deliverPolicies = map[string]jetstream.DeliverPolicy{
+		"":                 jetstream.DeliverAllPolicy,
+		"all":              jetstream.DeliverAllPolicy,
+		"last":             jetstream.DeliverLastPolicy,
+		"new":              jetstream.DeliverNewPolicy,
+		"last_per_subject": jetstream.DeliverLastPerSubjectPolicy,
+	}
+)
+
+// StreamConfig returns the JetStream configuration of the stream.
+func (s *Stream) StreamConfig() jetstream.StreamConfig {
+	return jetstream.StreamConfig{ //nolint: exhaustruct
+		Name:        s.Name,
+		Description: s.Description,
+		Subjects:    s.Subjects,
+		Retention:   retentionPolicies[s.Retention],
+		Storage:     storageTypes[s.Storage],
+		Discard:     discardPolicies[s.Discard],
+		MaxAge:      s.MaxAge,
+		MaxMsgs:     s.MaxMsgs,
+		MaxBytes:    s.MaxBytes,
+		Replicas:    s.Replicas,
+		Duplicates:  s.DuplicateWindow,
+	}
+}
+
+// ConsumerConfig returns the JetStream configuration of the durable consumer.
+func (c *Consumer) ConsumerConfig() jetstream.ConsumerConfig {
+	cfg := jetstream.ConsumerConfig{ //nolint: exhaustruct
+		Name:          c.Name,
+		Durable:       c.Name,
+		Description:   c.Description,
+		DeliverPolicy: deliverPolicies[c.DeliverPolicy],
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       c.AckWait,
+		MaxDeliver:    c.MaxDeliver,
+		MaxAckPending: c.MaxAckPending,
+	}
+
+	// A single filter is sent as FilterSubject, which older servers also understand
+	if len(c.FilterSubjects) == 1 {
+		cfg.FilterSubject = c.FilterSubjects[0]
+	} else {
+		cfg.FilterSubjects = c.FilterSubjects
+	}
+
+	return cfg
+}
+
+// KeyValueConfig returns the JetStream configuration of the bucket.
+func (kv *KeyValue) KeyValueConfig() jetstream.KeyValueConfig {
+	return jetstream.KeyValueConfig{ //nolint: exhaustruct
+		Bucket:      kv.Bucket,
+		Description: kv.Description,
+		History:     kv.History,
+		TTL:         kv.TTL,
+		MaxBytes:    kv.MaxBytes,
+		Storage:     storageTypes[kv.Storage],
+		Replicas:    kv.Replicas,
+	}
+}
+
+// streamName returns the name of the stream backing the bucket.
+func (kv *KeyValue) streamName() string {
+	return kvStreamPrefix + kv.Bucket
+}
+
+// backingConfig returns current, the configuration of the stream backing the bucket,
+// with the settings controlled by the bucket configuration applied the way the client library maps them.
+func (kv *KeyValue) backingConfig(current jetstream.StreamConfig) jetstream.StreamConfig {
+	desired := current
+	desired.Description = kv.Description
+	desired.MaxMsgsPerSubject = max(int64(kv.History), 1)
+	desired.MaxAge = kv.TTL
+	desired.MaxBytes = kv.MaxBytes
+	desired.Storage = storageTypes[kv.Storage]
+	desired.Replicas = kv.Replicas
+	desired.Duplicates = defaultDuplicateWindow
+
+	if kv.TTL > 0 && kv.TTL < defaultDuplicateWindow {
+		desired.Duplicates = kv.TTL
+	}
+
+	if desired.MaxBytes == 0 {
+		desired.MaxBytes = -1
+	}
+
+	return desired
+}
+
+// consumerFilters returns the filter subjects of cfg whether set as FilterSubject or FilterSubjects.
+func consumerFilters(cfg jetstream.ConsumerConfig) []string {
+	if cfg.FilterSubject != "" {
+		return []string{cfg.FilterSubject}
+	}
+
+	return cfg.FilterSubjects
+}
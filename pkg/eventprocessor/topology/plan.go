@@ -0,0 +1,271 @@
+package topology
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Server defaults of consumer settings left unset.
+const (
+	defaultAckWait       = 30 * time.Second
+	defaultMaxAckPending = 1000
+)
+
+// Action is what applying a topology does to a resource.
+type Action int
+
+const (
+	// ActionNone leaves a resource that already matches the topology unchanged.
+	ActionNone Action = iota
+	// ActionCreate creates a missing resource.
+	ActionCreate
+	// ActionUpdate applies safe changes to an existing resource.
+	ActionUpdate
+	// ActionRefuse marks a resource whose changes would lose data or cannot be applied in place.
+	ActionRefuse
+)
+
+// String returns the lowercase name of the action.
+func (a Action) String() string {
+	switch a {
+	case ActionNone:
+		return "none"
+	case ActionCreate:
+		return "create"
+	case ActionUpdate:
+		return "update"
+	case ActionRefuse:
+		return "refuse"
+	default:
+		return fmt.Sprintf("action(%d)", int(a))
+	}
+}
+
+// symbols prefix the resources of a formatted plan by action.
+var symbols = map[Action]string{ActionNone: "=", ActionCreate: "+", ActionUpdate: "~", ActionRefuse: "!"}
+
+// Change is the planned action for one resource.
+type Change struct {
+	// Kind is stream, consumer or kv
+	Kind string
+	// Name is the resource name, consumers are named stream/consumer
+	Name string
+	// Action is what applying the topology does
+	Action Action
+	// Diff lists the changed settings of an updated or refused resource
+	Diff nats.StreamDiff
+}
+
+// String formats the change as a plan line such as "~ stream ORDERS: MaxAge: 1h0m0s -> 24h0m0s".
+func (c Change) String() string {
+	s := fmt.Sprintf("%s %s %s", symbols[c.Action], c.Kind, c.Name)
+	if len(c.Diff) > 0 {
+		s += ": " + c.Diff.String()
+	}
+
+	return s
+}
+
+// Plan lists the changes applying a topology makes: each stream followed by its consumers, then the buckets.
+type Plan []Change
+
+// String formats the plan one change per line.
+func (p Plan) String() string {
+	lines := make([]string, len(p))
+	for i, change := range p {
+		lines[i] = change.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Pending reports whether applying the plan would change anything.
+func (p Plan) Pending() bool {
+	return slices.ContainsFunc(p, func(c Change) bool { return c.Action != ActionNone })
+}
+
+// Refused returns the changes that cannot be applied.
+func (p Plan) Refused() Plan {
+	var refused Plan
+
+	for _, change := range p {
+		if change.Action == ActionRefuse {
+			refused = append(refused, change)
+		}
+	}
+
+	return refused
+}
+
+// Plan compares the topology with the server without changing anything, as a dry run of Apply.
+func (t *Topology) Plan(ctx context.Context, js jetstream.JetStream) (Plan, error) {
+	var plan Plan
+
+	for _, s := range t.Streams {
+		changes, err := planStream(ctx, js, s)
+		if err != nil {
+			return nil, err
+		}
+
+		plan = append(plan, changes...)
+	}
+
+	for _, kv := range t.KeyValue {
+		change, err := planKeyValue(ctx, js, kv)
+		if err != nil {
+			return nil, err
+		}
+
+		plan = append(plan, change)
+	}
+
+	return plan, nil
+}
+
+// Apply creates and updates the resources of the topology, returning the plan it carried out.
+// Applying is idempotent. When any change is refused nothing is applied and the error wraps
+// nats.ErrDestructiveChange.
+func (t *Topology) Apply(ctx context.Context, js jetstream.JetStream) (Plan, error) {
+	plan, err := t.Plan(ctx, js)
+	if err != nil {
+		return nil, err
+	}
+
+	if refused := plan.Refused(); len(refused) > 0 {
+		return plan, fmt.Errorf("failed to apply topology: %w: %s",
+			nats.ErrDestructiveChange, strings.ReplaceAll(refused.String(), "\n", "; "))
+	}
+
+	for _, s := range t.Streams {
+		if _, _, err := nats.ReconcileStream(ctx, js, s.StreamConfig()); err != nil {
+			return plan, err
+		}
+
+		for _, c := range s.Consumers {
+			if _, err := js.CreateOrUpdateConsumer(ctx, s.Name, c.ConsumerConfig()); err != nil {
+				return plan, fmt.Errorf("failed to create or update consumer %s/%s: %w", s.Name, c.Name, err)
+			}
+		}
+	}
+
+	for _, kv := range t.KeyValue {
+		if _, err := js.CreateOrUpdateKeyValue(ctx, kv.KeyValueConfig()); err != nil {
+			return plan, fmt.Errorf("failed to create or update kv %s: %w", kv.Bucket, err)
+		}
+	}
+
+	return plan, nil
+}
+
+// planStream plans the stream and its consumers.
+func planStream(ctx context.Context, js jetstream.JetStream, s Stream) (Plan, error) {
+	stream, err := js.Stream(ctx, s.Name)
+	if errors.Is(err, jetstream.ErrStreamNotFound) {
+		plan := Plan{{Kind: "stream", Name: s.Name, Action: ActionCreate, Diff: nil}}
+		for _, c := range s.Consumers {
+			plan = append(plan, Change{Kind: "consumer", Name: s.Name + "/" + c.Name, Action: ActionCreate, Diff: nil})
+		}
+
+		return plan, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up stream %s: %w", s.Name, err)
+	}
+
+	plan := Plan{change("stream", s.Name, nats.DiffStreamConfig(stream.CachedInfo().Config, s.StreamConfig()))}
+
+	for _, c := range s.Consumers {
+		name := s.Name + "/" + c.Name
+
+		consumer, err := stream.Consumer(ctx, c.Name)
+		if errors.Is(err, jetstream.ErrConsumerNotFound) {
+			plan = append(plan, Change{Kind: "consumer", Name: name, Action: ActionCreate, Diff: nil})
+
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up consumer %s: %w", name, err)
+		}
+
+		plan = append(plan, change("consumer", name, diffConsumer(consumer.CachedInfo().Config, c.ConsumerConfig())))
+	}
+
+	return plan, nil
+}
+
+// planKeyValue plans the bucket by comparing its backing stream.
+func planKeyValue(ctx context.Context, js jetstream.JetStream, kv KeyValue) (Change, error) {
+	stream, err := js.Stream(ctx, kv.streamName())
+	if errors.Is(err, jetstream.ErrStreamNotFound) {
+		return Change{Kind: "kv", Name: kv.Bucket, Action: ActionCreate, Diff: nil}, nil
+	}
+
+	if err != nil {
+		return Change{}, fmt.Errorf("failed to look up kv %s: %w", kv.Bucket, err)
+	}
+
+	current := stream.CachedInfo().Config
+
+	return change("kv", kv.Bucket, nats.DiffStreamConfig(current, kv.backingConfig(current))), nil
+}
+
+// change plans an existing resource from its diff.
+func change(kind, name string, diff nats.StreamDiff) Change {
+	action := ActionNone
+
+	switch {
+	case len(diff.Destructive()) > 0:
+		action = ActionRefuse
+	case len(diff) > 0:
+		action = ActionUpdate
+	}
+
+	return Change{Kind: kind, Name: name, Action: action, Diff: diff}
+}
+
+// diffConsumer compares the settings a topology controls, with unset ones compared as the server defaults.
+// The deliver policy cannot be changed in place, so changing it is marked destructive.
+func diffConsumer(current, desired jetstream.ConsumerConfig) nats.StreamDiff {
+	if desired.AckWait == 0 {
+		desired.AckWait = defaultAckWait
+	}
+
+	if desired.MaxDeliver == 0 {
+		desired.MaxDeliver = -1
+	}
+
+	if desired.MaxAckPending == 0 {
+		desired.MaxAckPending = defaultMaxAckPending
+	}
+
+	var diff nats.StreamDiff
+
+	add := func(field string, from, to any, destructive bool) {
+		if from != to {
+			diff = append(diff, nats.StreamChange{
+				Field:       field,
+				From:        fmt.Sprint(from),
+				To:          fmt.Sprint(to),
+				Destructive: destructive,
+			})
+		}
+	}
+
+	add("Description", current.Description, desired.Description, false)
+	add("FilterSubjects", fmt.Sprint(consumerFilters(current)), fmt.Sprint(consumerFilters(desired)), false)
+	add("DeliverPolicy", current.DeliverPolicy, desired.DeliverPolicy, true)
+	add("AckWait", current.AckWait, desired.AckWait, false)
+	add("MaxDeliver", current.MaxDeliver, desired.MaxDeliver, false)
+	add("MaxAckPending", current.MaxAckPending, desired.MaxAckPending, false)
+
+	return diff
+}
@@ -0,0 +1,92 @@
+package topology_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/natstest"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/topology"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApply(t *testing.T) { //nolint: funlen
+	t.Parallel()
+
+	cfg := natstest.NewConfig(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	nc, err := natsgo.Connect(cfg.URL)
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+
+	js, err := jetstream.New(nc)
+	require.NoError(t, err)
+
+	topo, err := topology.Load("testdata/topology.yaml")
+	require.NoError(t, err)
+
+	// The dry run plans every resource for creation without touching the server
+	plan, err := topo.Plan(ctx, js)
+	require.NoError(t, err)
+	assert.Equal(t, "+ stream ORDERS\n"+
+		"+ consumer ORDERS/fulfilment\n"+
+		"+ consumer ORDERS/audit\n"+
+		"+ stream PAYMENTS\n"+
+		"+ kv sessions", plan.String())
+
+	_, err = js.Stream(ctx, "ORDERS")
+	require.ErrorIs(t, err, jetstream.ErrStreamNotFound)
+
+	applied, err := topo.Apply(ctx, js)
+	require.NoError(t, err)
+	assert.Equal(t, plan, applied)
+
+	// Applying again finds nothing to change
+	plan, err = topo.Apply(ctx, js)
+	require.NoError(t, err)
+	assert.False(t, plan.Pending(), plan.String())
+
+	consumer, err := js.Consumer(ctx, "ORDERS", "fulfilment")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, consumer.CachedInfo().Config.AckWait)
+
+	kv, err := js.KeyValue(ctx, "sessions")
+	require.NoError(t, err)
+	status, err := kv.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), status.History())
+
+	// Safe changes are planned as updates and applied
+	topo.Streams[0].MaxAge = 48 * time.Hour
+	topo.Streams[0].Consumers[0].MaxDeliver = 10
+	topo.KeyValue[0].TTL = 2 * time.Hour
+
+	plan, err = topo.Apply(ctx, js)
+	require.NoError(t, err)
+	assert.Equal(t, "~ stream ORDERS: MaxAge: 24h0m0s -> 48h0m0s\n"+
+		"~ consumer ORDERS/fulfilment: MaxDeliver: 5 -> 10\n"+
+		"= consumer ORDERS/audit\n"+
+		"= stream PAYMENTS\n"+
+		"~ kv sessions: MaxAge: 1h0m0s -> 2h0m0s", plan.String())
+
+	// Destructive changes are refused and nothing is applied
+	topo.Streams[0].Description = "changed"
+	topo.Streams[1].Retention = "limits"
+	topo.KeyValue[0].History = 1
+
+	plan, err = topo.Apply(ctx, js)
+	require.ErrorIs(t, err, nats.ErrDestructiveChange)
+	assert.Contains(t, err.Error(), "! stream PAYMENTS: Retention: WorkQueue -> Limits (destructive)")
+	assert.Contains(t, err.Error(), "! kv sessions: MaxMsgsPerSubject: 5 -> 1 (destructive)")
+	assert.Len(t, plan.Refused(), 2)
+
+	stream, err := js.Stream(ctx, "ORDERS")
+	require.NoError(t, err)
+	assert.Equal(t, "Order lifecycle events", stream.CachedInfo().Config.Description)
+}
@@ -0,0 +1,247 @@
+// Package topology declares JetStream streams, consumers and key-value buckets in a YAML or JSON file
+// and applies them idempotently, with a dry-run plan of the changes.
+package topology
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/nats-io/nats.go/jetstream"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidTopology is returned when a topology file cannot be parsed or fails validation.
+var ErrInvalidTopology = errors.New("invalid topology")
+
+// Topology describes the JetStream resources an application needs.
+type Topology struct {
+	// Streams are the streams with their consumers
+	Streams []Stream `yaml:"streams"`
+	// KeyValue are the key-value buckets
+	KeyValue []KeyValue `yaml:"kv"`
+}
+
+// Stream describes a stream. Unset limits use the server defaults.
+type Stream struct {
+	// Name is the stream name
+	Name string `yaml:"name"`
+	// Description is an optional description
+	Description string `yaml:"description"`
+	// Subjects are the subjects stored by the stream, wildcards allowed
+	Subjects []string `yaml:"subjects"`
+	// Retention is one of limits (default), interest or workqueue
+	Retention string `yaml:"retention"`
+	// Storage is one of file (default) or memory
+	Storage string `yaml:"storage"`
+	// Discard is one of old (default) or new
+	Discard string `yaml:"discard"`
+	// MaxAge is the maximum age of stored messages, zero keeps them forever
+	MaxAge time.Duration `yaml:"max_age"`
+	// MaxMsgs is the maximum number of stored messages
+	MaxMsgs int64 `yaml:"max_msgs"`
+	// MaxBytes is the maximum size of the stream in bytes
+	MaxBytes int64 `yaml:"max_bytes"`
+	// Replicas is the number of replicas in a cluster
+	Replicas int `yaml:"replicas"`
+	// DuplicateWindow is the window in which published message IDs are deduplicated
+	DuplicateWindow time.Duration `yaml:"duplicate_window"`
+	// Consumers are the durable consumers of the stream
+	Consumers []Consumer `yaml:"consumers"`
+}
+
+// Consumer describes a durable pull consumer with explicit acks.
+type Consumer struct {
+	// Name is the durable consumer name
+	Name string `yaml:"name"`
+	// Description is an optional description
+	Description string `yaml:"description"`
+	// FilterSubjects restrict the consumer to subjects of the stream, empty for all
+	FilterSubjects []string `yaml:"filter_subjects"`
+	// DeliverPolicy is one of all (default), last, new or last_per_subject
+	DeliverPolicy string `yaml:"deliver_policy"`
+	// AckWait is how long the server waits for an ack before redelivering
+	AckWait time.Duration `yaml:"ack_wait"`
+	// MaxDeliver is the maximum number of deliveries of a message
+	MaxDeliver int `yaml:"max_deliver"`
+	// MaxAckPending is the maximum number of unacknowledged messages
+	MaxAckPending int `yaml:"max_ack_pending"`
+}
+
+// KeyValue describes a key-value bucket.
+type KeyValue struct {
+	// Bucket is the bucket name
+	Bucket string `yaml:"bucket"`
+	// Description is an optional description
+	Description string `yaml:"description"`
+	// History is the number of values kept per key, 1 by default
+	History uint8 `yaml:"history"`
+	// TTL is how long values are kept, zero keeps them forever
+	TTL time.Duration `yaml:"ttl"`
+	// MaxBytes is the maximum size of the bucket in bytes
+	MaxBytes int64 `yaml:"max_bytes"`
+	// Storage is one of file (default) or memory
+	Storage string `yaml:"storage"`
+	// Replicas is the number of replicas in a cluster
+	Replicas int `yaml:"replicas"`
+}
+
+// Load reads and validates a topology file.
+func Load(path string) (*Topology, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read topology: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// Parse decodes and validates a topology in YAML or JSON. Unknown fields are rejected.
+// Durations are written as strings such as "90s" or "24h".
+func Parse(data []byte) (*Topology, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	var t Topology
+	if err := dec.Decode(&t); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTopology, err)
+	}
+
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// Validate checks names, subjects and policies, returning an error wrapping ErrInvalidTopology
+// that lists every problem.
+func (t *Topology) Validate() error {
+	var issues []string
+
+	streams := make(map[string]bool, len(t.Streams))
+
+	for i, s := range t.Streams {
+		name := fmt.Sprintf("stream %q", s.Name)
+		if !validName(s.Name) {
+			name = fmt.Sprintf("stream %d", i)
+			issues = append(issues, name+": name must be non-empty without spaces, '.', '*' or '>'")
+		}
+
+		if streams[s.Name] {
+			issues = append(issues, name+": defined more than once")
+		}
+
+		streams[s.Name] = true
+
+		issues = append(issues, s.validate(name)...)
+	}
+
+	buckets := make(map[string]bool, len(t.KeyValue))
+
+	for _, kv := range t.KeyValue {
+		name := fmt.Sprintf("kv %q", kv.Bucket)
+		if !validName(kv.Bucket) {
+			issues = append(issues, name+": bucket must be non-empty without spaces, '.', '*' or '>'")
+		}
+
+		if buckets[kv.Bucket] {
+			issues = append(issues, name+": defined more than once")
+		}
+
+		buckets[kv.Bucket] = true
+
+		if _, ok := storageTypes[kv.Storage]; !ok {
+			issues = append(issues, fmt.Sprintf("%s: unknown storage %q", name, kv.Storage))
+		}
+
+		if kv.History > jetstream.KeyValueMaxHistory {
+			issues = append(issues, fmt.Sprintf("%s: history exceeds %d", name, jetstream.KeyValueMaxHistory))
+		}
+	}
+
+	if len(issues) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidTopology, strings.Join(issues, "; "))
+	}
+
+	return nil
+}
+
+// validate lists the problems of a stream and its consumers, prefixed with name.
+func (s *Stream) validate(name string) []string {
+	var issues []string
+
+	if len(s.Subjects) == 0 {
+		issues = append(issues, name+": at least one subject is required")
+	}
+
+	for _, subject := range s.Subjects {
+		if !validPattern(subject) {
+			issues = append(issues, fmt.Sprintf("%s: invalid subject %q", name, subject))
+		}
+	}
+
+	if _, ok := retentionPolicies[s.Retention]; !ok {
+		issues = append(issues, fmt.Sprintf("%s: unknown retention %q", name, s.Retention))
+	}
+
+	if _, ok := storageTypes[s.Storage]; !ok {
+		issues = append(issues, fmt.Sprintf("%s: unknown storage %q", name, s.Storage))
+	}
+
+	if _, ok := discardPolicies[s.Discard]; !ok {
+		issues = append(issues, fmt.Sprintf("%s: unknown discard %q", name, s.Discard))
+	}
+
+	consumers := make(map[string]bool, len(s.Consumers))
+
+	for _, c := range s.Consumers {
+		consumer := fmt.Sprintf("%s consumer %q", name, c.Name)
+		if !validName(c.Name) {
+			issues = append(issues, consumer+": name must be non-empty without spaces, '.', '*' or '>'")
+		}
+
+		if consumers[c.Name] {
+			issues = append(issues, consumer+": defined more than once")
+		}
+
+		consumers[c.Name] = true
+
+		if _, ok := deliverPolicies[c.DeliverPolicy]; !ok {
+			issues = append(issues, fmt.Sprintf("%s: unknown deliver policy %q", consumer, c.DeliverPolicy))
+		}
+
+		for _, filter := range c.FilterSubjects {
+			if !validPattern(filter) || !slices.ContainsFunc(s.Subjects, func(subject string) bool {
+				return eventprocessor.MatchSubject(subject, filter)
+			}) {
+				issues = append(issues, fmt.Sprintf("%s: filter subject %q is not a subject of the stream", consumer, filter))
+			}
+		}
+	}
+
+	return issues
+}
+
+// validName reports whether name can be used for a stream, consumer or bucket.
+func validName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\r\n.*>")
+}
+
+// validPattern reports whether subject is a subject or wildcard pattern without empty tokens.
+func validPattern(subject string) bool {
+	tokens := strings.Split(subject, ".")
+	for i, token := range tokens {
+		if token == "" || strings.ContainsAny(token, " \t\r\n") || (token == ">" && i != len(tokens)-1) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,86 @@
+package topology_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/topology"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	fromYAML, err := topology.Load("testdata/topology.yaml")
+	require.NoError(t, err)
+
+	fromJSON, err := topology.Load("testdata/topology.json")
+	require.NoError(t, err)
+	assert.Equal(t, fromYAML, fromJSON)
+
+	require.Len(t, fromYAML.Streams, 2)
+	orders := fromYAML.Streams[0].StreamConfig()
+	assert.Equal(t, 24*time.Hour, orders.MaxAge)
+	assert.Equal(t, time.Minute, orders.Duplicates)
+	assert.Equal(t, jetstream.WorkQueuePolicy, fromYAML.Streams[1].StreamConfig().Retention)
+
+	fulfilment := fromYAML.Streams[0].Consumers[0].ConsumerConfig()
+	assert.Equal(t, "orders.created", fulfilment.FilterSubject)
+	assert.Equal(t, jetstream.AckExplicitPolicy, fulfilment.AckPolicy)
+	assert.Equal(t, 5, fulfilment.MaxDeliver)
+
+	sessions := fromYAML.KeyValue[0].KeyValueConfig()
+	assert.Equal(t, jetstream.MemoryStorage, sessions.Storage)
+	assert.Equal(t, uint8(5), sessions.History)
+
+	_, err = topology.Load("testdata/missing.yaml")
+	require.Error(t, err)
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		data  string
+		issue string
+	}{
+		{name: "empty", data: "", issue: ""},
+		{name: "unknown field", data: "streams:\n  - name: A\n    subject: [a]\n", issue: "field subject not found"},
+		{name: "bad duration", data: "streams:\n  - name: A\n    subjects: [a]\n    max_age: forever\n", issue: "forever"},
+		{name: "invalid name", data: "streams:\n  - name: a.b\n    subjects: [a]\n", issue: "stream 0: name"},
+		{name: "no subjects", data: "streams:\n  - name: A\n", issue: "at least one subject"},
+		{name: "invalid subject", data: "streams:\n  - name: A\n    subjects: [a.>.b]\n", issue: `invalid subject "a.>.b"`},
+		{
+			name:  "duplicate stream",
+			data:  "streams:\n  - name: A\n    subjects: [a]\n  - name: A\n    subjects: [b]\n",
+			issue: `stream "A": defined more than once`,
+		},
+		{name: "unknown retention", data: "streams:\n  - name: A\n    subjects: [a]\n    retention: forever\n", issue: "retention"},
+		{name: "unknown storage", data: "kv:\n  - bucket: b\n    storage: disk\n", issue: `kv "b": unknown storage "disk"`},
+		{
+			name:  "unknown deliver policy",
+			data:  "streams:\n  - name: A\n    subjects: [a]\n    consumers:\n      - name: c\n        deliver_policy: first\n",
+			issue: `consumer "c": unknown deliver policy`,
+		},
+		{
+			name:  "filter outside stream",
+			data:  "streams:\n  - name: A\n    subjects: [a.>]\n    consumers:\n      - name: c\n        filter_subjects: [b.x]\n",
+			issue: `filter subject "b.x" is not a subject of the stream`,
+		},
+		{name: "history", data: "kv:\n  - bucket: b\n    history: 65\n", issue: "history exceeds 64"},
+	}
+	for _, tt := range tests {
+		_, err := topology.Parse([]byte(tt.data))
+		if tt.issue == "" {
+			assert.NoError(t, err, tt.name)
+
+			continue
+		}
+
+		require.ErrorIs(t, err, topology.ErrInvalidTopology, tt.name)
+		assert.Contains(t, err.Error(), tt.issue, tt.name)
+	}
+}
@@ -0,0 +1,139 @@
+// Package typed provides generic publishers and subscribers that encode Go values with pluggable codecs.
+package typed
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	// ErrDecode is returned when a payload cannot be decoded into the subscriber's type.
+	ErrDecode = errors.New("failed to decode payload")
+	// ErrUnsupportedType is returned when a codec cannot handle the value's type.
+	ErrUnsupportedType = errors.New("unsupported type")
+)
+
+// Codec encodes values to payloads and decodes payloads back into values.
+type Codec interface {
+	// ContentType returns the media type of encoded payloads
+	ContentType() string
+	// Marshal encodes v
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes data into the value pointed to by v
+	Unmarshal(data []byte, v any) error
+}
+
+// Compile-time checks that the codecs implement Codec.
+var (
+	_ Codec = JSON{}
+	_ Codec = Protobuf{}
+	_ Codec = MessagePack{}
+)
+
+// JSON encodes values with encoding/json.
+type JSON struct{}
+
+// ContentType returns application/json.
+func (JSON) ContentType() string {
+	return "application/json"
+}
+
+// Marshal encodes v as JSON.
+func (JSON) Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSON: %w", err)
+	}
+
+	return data, nil
+}
+
+// Unmarshal decodes JSON data into v.
+func (JSON) Unmarshal(data []byte, v any) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode JSON: %w", err)
+	}
+
+	return nil
+}
+
+// Protobuf encodes generated protobuf messages in the binary wire format.
+// Use it with pointer message types, for example Publisher[*pb.Order].
+type Protobuf struct{}
+
+// ContentType returns application/protobuf.
+func (Protobuf) ContentType() string {
+	return "application/protobuf"
+}
+
+// Marshal encodes v, which must be a proto.Message.
+func (Protobuf) Marshal(v any) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a proto.Message: %w", v, ErrUnsupportedType)
+	}
+
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode protobuf: %w", err)
+	}
+
+	return data, nil
+}
+
+// Unmarshal decodes data into v, a proto.Message or a pointer to a nil message pointer which is allocated.
+func (Protobuf) Unmarshal(data []byte, v any) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Pointer && !rv.IsNil() && rv.Elem().Kind() == reflect.Pointer {
+			if rv.Elem().IsNil() {
+				rv.Elem().Set(reflect.New(rv.Elem().Type().Elem()))
+			}
+
+			msg, ok = rv.Elem().Interface().(proto.Message)
+		}
+	}
+
+	if !ok {
+		return fmt.Errorf("%T does not point to a proto.Message: %w", v, ErrUnsupportedType)
+	}
+
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return fmt.Errorf("failed to decode protobuf: %w", err)
+	}
+
+	return nil
+}
+
+// MessagePack encodes values in the MessagePack format.
+type MessagePack struct{}
+
+// ContentType returns application/msgpack.
+func (MessagePack) ContentType() string {
+	return "application/msgpack"
+}
+
+// Marshal encodes v as MessagePack.
+func (MessagePack) Marshal(v any) ([]byte, error) {
+	data, err := msgpack.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode MessagePack: %w", err)
+	}
+
+	return data, nil
+}
+
+// Unmarshal decodes MessagePack data into v.
+func (MessagePack) Unmarshal(data []byte, v any) error {
+	if err := msgpack.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode MessagePack: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package typed_test
+
+import (
+	"testing"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/typed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// order is the payload type used by the tests.
+type order struct {
+	ID    string  `json:"id"    msgpack:"id"`
+	Total float64 `json:"total" msgpack:"total"`
+}
+
+func TestCodecs(t *testing.T) {
+	t.Parallel()
+
+	want := order{ID: "order-1", Total: 12.5}
+
+	tests := []struct {
+		name        string
+		codec       typed.Codec
+		contentType string
+	}{
+		{name: "json", codec: typed.JSON{}, contentType: "application/json"},
+		{name: "msgpack", codec: typed.MessagePack{}, contentType: "application/msgpack"},
+	}
+	for _, tt := range tests {
+		data, err := tt.codec.Marshal(want)
+		require.NoError(t, err, tt.name)
+
+		var got order
+		require.NoError(t, tt.codec.Unmarshal(data, &got), tt.name)
+		assert.Equal(t, want, got, tt.name)
+		assert.Equal(t, tt.contentType, tt.codec.ContentType(), tt.name)
+		assert.Error(t, tt.codec.Unmarshal([]byte{0xc1}, &got), tt.name)
+	}
+}
+
+func TestProtobufCodec(t *testing.T) {
+	t.Parallel()
+
+	codec := typed.Protobuf{}
+
+	data, err := codec.Marshal(wrapperspb.String("order-1"))
+	require.NoError(t, err)
+
+	// Pointer message types are allocated when decoding into a nil pointer
+	var got *wrapperspb.StringValue
+	require.NoError(t, codec.Unmarshal(data, &got))
+	assert.True(t, proto.Equal(wrapperspb.String("order-1"), got))
+
+	into := &wrapperspb.StringValue{} //nolint: exhaustruct
+	require.NoError(t, codec.Unmarshal(data, into))
+	assert.Equal(t, "order-1", into.GetValue())
+
+	_, err = codec.Marshal(order{}) //nolint: exhaustruct
+	require.ErrorIs(t, err, typed.ErrUnsupportedType)
+
+	var wrong order
+	require.ErrorIs(t, codec.Unmarshal(data, &wrong), typed.ErrUnsupportedType)
+	assert.Error(t, codec.Unmarshal([]byte{0xff}, &got))
+}
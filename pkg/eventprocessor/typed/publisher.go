@@ -0,0 +1,53 @@
+package typed
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+)
+
+// Publisher publishes values of type T through an EventProcessor, encoding them with a Codec.
+type Publisher[T any] struct {
+	next  eventprocessor.EventProcessor
+	codec Codec
+}
+
+// NewPublisher creates a Publisher encoding values with codec and publishing them with next.
+func NewPublisher[T any](next eventprocessor.EventProcessor, codec Codec) *Publisher[T] {
+	return &Publisher[T]{next: next, codec: codec}
+}
+
+// Publish encodes v and publishes it to topic.
+func (p *Publisher[T]) Publish(ctx context.Context, topic string, v T) error {
+	data, err := p.codec.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %T: %w", v, err)
+	}
+
+	return p.next.PublishToStream(ctx, topic, data) //nolint: wrapcheck
+}
+
+// PublishEvent encodes v as the payload of event, sets its content type and publishes it to topic.
+// next must implement eventprocessor.EventPublisher.
+func (p *Publisher[T]) PublishEvent(ctx context.Context, topic string, event eventprocessor.Event, v T) error {
+	publisher, ok := p.next.(eventprocessor.EventPublisher)
+	if !ok {
+		return fmt.Errorf("%T does not publish events: %w", p.next, ErrUnsupportedType)
+	}
+
+	data, err := p.codec.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %T: %w", v, err)
+	}
+
+	event.ContentType = p.codec.ContentType()
+	event.Data = data
+
+	return publisher.PublishEvent(ctx, topic, event) //nolint: wrapcheck
+}
+
+// Close closes the underlying EventProcessor.
+func (p *Publisher[T]) Close(ctx context.Context) error {
+	return p.next.Close(ctx) //nolint: wrapcheck
+}
@@ -0,0 +1,56 @@
+package typed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Subscriber decodes payloads into values of type T before passing them to handlers.
+// Payloads that cannot be decoded are reported as errors wrapping ErrDecode; package typednats
+// adapts handlers to JetStream consumers, terminating such messages rather than redelivering them.
+type Subscriber[T any] struct {
+	codec  Codec
+	logger *zap.Logger
+}
+
+// NewSubscriber creates a Subscriber decoding payloads with codec.
+// logger reports failures of handlers adapted with Callback, which cannot be redelivered; nil discards them.
+func NewSubscriber[T any](codec Codec, logger *zap.Logger) *Subscriber[T] {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &Subscriber[T]{codec: codec, logger: logger}
+}
+
+// Decode decodes data into a T, returning an error wrapping ErrDecode on failure.
+func (s *Subscriber[T]) Decode(data []byte) (T, error) {
+	var v T
+	if err := s.codec.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("%w into %T: %w", ErrDecode, v, err)
+	}
+
+	return v, nil
+}
+
+// Callback adapts handler to a core subscription callback, for example for
+// nats.SimpleNatsClient.Subscribe or memory.Broker.Subscribe.
+// Decode and handler errors are logged since core subscriptions cannot redeliver messages.
+func (s *Subscriber[T]) Callback(handler func(ctx context.Context, v T) error) func([]byte) {
+	return func(data []byte) {
+		v, err := s.Decode(data)
+		if err == nil {
+			err = handler(context.Background(), v)
+		}
+
+		if err != nil {
+			s.logger.Error("failed to handle message",
+				zap.Bool("undecodable", errors.Is(err, ErrDecode)),
+				zap.Error(err),
+			)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package typed_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/memory"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/typed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestPublisherSubscriber(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zap.ErrorLevel)
+	broker := memory.NewBroker()
+	publisher := typed.NewPublisher[order](broker, typed.MessagePack{})
+	defer publisher.Close(context.Background())
+
+	received := make(chan order, 1)
+	subscriber := typed.NewSubscriber[order](typed.MessagePack{}, zap.New(core))
+	require.NoError(t, broker.Subscribe("orders.>", subscriber.Callback(func(_ context.Context, v order) error {
+		received <- v
+
+		return nil
+	})))
+
+	ctx := context.Background()
+	require.NoError(t, broker.PublishToStream(ctx, "orders.garbage", []byte{0xc1}))
+	require.NoError(t, publisher.Publish(ctx, "orders.created", order{ID: "order-1", Total: 3}))
+
+	select {
+	case got := <-received:
+		assert.Equal(t, order{ID: "order-1", Total: 3}, got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("order was not received")
+	}
+
+	// The undecodable payload was reported as such
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, true, logs.All()[0].ContextMap()["undecodable"])
+
+	// Events carry the codec content type
+	event := eventprocessor.NewEvent("t", "s", nil)
+	require.NoError(t, publisher.PublishEvent(ctx, "events.created", event, order{ID: "order-2", Total: 1}))
+
+	stored := broker.Messages("events.>")
+	require.Len(t, stored, 1)
+	assert.Equal(t, typed.MessagePack{}.ContentType(), stored[0].Headers.Get(eventprocessor.EventContentTypeHeader))
+
+	// Processors without PublishEvent do not publish events
+	raw := typed.NewPublisher[order](struct{ eventprocessor.EventProcessor }{broker}, typed.MessagePack{})
+	err := raw.PublishEvent(ctx, "events.created", event, order{}) //nolint: exhaustruct
+	assert.ErrorIs(t, err, typed.ErrUnsupportedType)
+}
+
+func TestSubscriberDecode(t *testing.T) {
+	t.Parallel()
+
+	// A nil logger discards callback failures
+	subscriber := typed.NewSubscriber[order](typed.JSON{}, nil)
+	subscriber.Callback(func(context.Context, order) error { return nil })([]byte("not json"))
+
+	_, err := subscriber.Decode([]byte("not json"))
+	require.ErrorIs(t, err, typed.ErrDecode)
+
+	got, err := subscriber.Decode([]byte(`{"id":"order-1","total":3}`))
+	require.NoError(t, err)
+	assert.Equal(t, order{ID: "order-1", Total: 3}, got)
+}
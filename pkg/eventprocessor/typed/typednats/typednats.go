@@ -0,0 +1,57 @@
+// Package typednats adapts typed subscribers to NATS JetStream consumers.
+// It is kept apart from package typed so the generic codecs do not depend on NATS.
+package typednats
+
+import (
+	"context"
+	"errors"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/typed"
+)
+
+// Decode decodes the payload of msg with s, returning a permanent error wrapping typed.ErrDecode on failure.
+func Decode[T any](s *typed.Subscriber[T], msg *nats.Message) (T, error) {
+	v, err := s.Decode(msg.Data)
+	if err != nil {
+		return v, nats.Permanent(err)
+	}
+
+	return v, nil
+}
+
+// ErrorHandler adapts handler to a nats.ErrorHandler for use with nats.RetryPolicy.Handler.
+// Decode failures are returned as permanent errors so the policy terminates the message.
+func ErrorHandler[T any](
+	s *typed.Subscriber[T],
+	handler func(ctx context.Context, msg *nats.Message, v T) error,
+) nats.ErrorHandler {
+	return func(ctx context.Context, msg *nats.Message) error {
+		v, err := Decode(s, msg)
+		if err != nil {
+			return err
+		}
+
+		return handler(ctx, msg, v)
+	}
+}
+
+// Handler adapts handler to a nats.Handler for JetStream consumers without a retry policy.
+// Messages are acked on success, terminated on decode failures and permanent errors,
+// and nak'd for immediate redelivery on other errors.
+func Handler[T any](s *typed.Subscriber[T], handler func(ctx context.Context, msg *nats.Message, v T) error) nats.Handler {
+	decoded := ErrorHandler(s, handler)
+
+	return func(ctx context.Context, msg *nats.Message) nats.Result {
+		err := decoded(ctx, msg)
+
+		switch {
+		case err == nil:
+			return nats.Ack()
+		case errors.Is(err, nats.ErrPermanent):
+			return nats.Term(err)
+		default:
+			return nats.Nak(0, err)
+		}
+	}
+}
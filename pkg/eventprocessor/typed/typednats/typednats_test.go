@@ -0,0 +1,65 @@
+package typednats_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/nats"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/typed"
+	"github.com/drmf-cz/event-processor/pkg/eventprocessor/typed/typednats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type order struct {
+	ID string `json:"id"`
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	subscriber := typed.NewSubscriber[order](typed.JSON{}, nil)
+	errRetry := errors.New("try again")
+	handler := typednats.Handler(subscriber, func(_ context.Context, _ *nats.Message, v order) error {
+		switch v.ID {
+		case "retry":
+			return errRetry
+		case "invalid":
+			return nats.Permanent(errors.New("invalid order"))
+		default:
+			return nil
+		}
+	})
+
+	tests := []struct {
+		name   string
+		data   string
+		action nats.Action
+	}{
+		{name: "decoded", data: `{"id":"ok"}`, action: nats.ActionAck},
+		{name: "transient error", data: `{"id":"retry"}`, action: nats.ActionNak},
+		{name: "permanent error", data: `{"id":"invalid"}`, action: nats.ActionTerm},
+		{name: "undecodable", data: `{"id":`, action: nats.ActionTerm},
+	}
+	for _, tt := range tests {
+		res := handler(context.Background(), &nats.Message{Data: []byte(tt.data)}) //nolint: exhaustruct
+		assert.Equal(t, tt.action, res.Action, tt.name)
+	}
+
+	_, err := typednats.Decode(subscriber, &nats.Message{Data: []byte("not json")}) //nolint: exhaustruct
+	require.ErrorIs(t, err, typed.ErrDecode)
+	require.ErrorIs(t, err, nats.ErrPermanent)
+
+	// Retry policies terminate decode failures instead of redelivering them
+	policy := nats.FixedRetry(time.Second, 5)
+	res := policy.Handler(typednats.ErrorHandler(subscriber, func(context.Context, *nats.Message, order) error {
+		return nil
+	}))(context.Background(), &nats.Message{ //nolint: exhaustruct
+		Data:     []byte("not json"),
+		Metadata: &eventprocessor.Metadata{NumDelivered: 1}, //nolint: exhaustruct
+	})
+	assert.Equal(t, nats.ActionTerm, res.Action)
+}